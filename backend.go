@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/NatsuiroGinga/mydocker/client"
+	"github.com/NatsuiroGinga/mydocker/daemon"
+	"github.com/sirupsen/logrus"
+)
+
+// newBackend 返回命令行使用的 Backend
+/*
+daemon 正在运行时通过 unix socket 把操作交给 daemon 执行，命令行只是 daemon 的客户端；
+否则和以前一样直接在当前进程中操作容器。
+*/
+func newBackend() daemon.Backend {
+	daemonClient := daemon.NewClient(daemon.DefaultSocket)
+	if err := daemonClient.Ping(); err != nil {
+		logrus.Debugf("daemon is not available, fall back to local client: %v", err)
+		return client.New(client.SelfExe)
+	}
+	return daemonClient
+}
@@ -1,6 +1,10 @@
 package cgroups
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+
 	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
 	"github.com/sirupsen/logrus"
 )
@@ -15,14 +19,98 @@ type CgroupManager interface {
 
 	// Destroy 释放cgroup
 	Destroy() error
+
+	// OOMEvents 返回cgroup中 OOM 的次数和被 OOM killer 杀死的进程数，需要在 Destroy 之前调用
+	OOMEvents() (*resource.OOMEvents, error)
+
+	// NotifyOOM 监听cgroup中的 OOM 事件，收到通知后通过 OOMEvents 读取计数，
+	// done 关闭或者cgroup被删除后返回的 channel 会被关闭
+	NotifyOOM(done <-chan struct{}) (<-chan struct{}, error)
+
+	// MemoryUsage 返回cgroup当前使用的内存，单位是字节
+	MemoryUsage() (uint64, error)
+
+	// Stats 返回cgroup的 CPU、内存、进程数和块设备 IO 的使用情况
+	Stats() (*resource.Stats, error)
+
+	// Freeze 冻结cgroup中的全部进程，直到进程全部冻结才返回
+	Freeze() error
+
+	// Thaw 恢复cgroup中被冻结的进程
+	Thaw() error
+
+	// Paths 返回cgroup在宿主机上的绝对路径，key 为 subsystem 名称，cgroup v2 只有一个 unified
+	Paths() map[string]string
+}
+
+// collectStats 从实现了 resource.StatsGetter 的 subsystem 中读取资源使用情况
+//
+// 宿主机没有挂载或者没有开启某个 subsystem 时对应的文件不存在，跳过这部分统计
+func collectStats(path string, subsystems []resource.Subsystem) (*resource.Stats, error) {
+	stats := &resource.Stats{}
+	var errs []error
+	for _, sys := range subsystems {
+		getter, ok := sys.(resource.StatsGetter)
+		if !ok {
+			continue
+		}
+		if err := getter.GetStats(path, stats); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("get %s stats: %w", sys.Name(), err))
+		}
+	}
+	return stats, errors.Join(errs...)
+}
+
+// cgroup 驱动，cgroupfs 直接读写 /sys/fs/cgroup，systemd 通过 D-Bus 由 systemd 创建 scope
+const (
+	DriverCgroupfs = "cgroupfs"
+	DriverSystemd  = "systemd"
+)
+
+// ValidateDriver 检查 cgroup 驱动，为空时使用 cgroupfs
+func ValidateDriver(driver string) error {
+	switch driver {
+	case "", DriverCgroupfs, DriverSystemd:
+		return nil
+	default:
+		return fmt.Errorf("invalid cgroup driver %s, must be %s or %s", driver, DriverCgroupfs, DriverSystemd)
+	}
+}
+
+// NewManager 根据 cgroup 驱动创建 CgroupManager，driver 为空时使用 cgroupfs
+func NewManager(driver, path string) CgroupManager {
+	if driver == DriverSystemd {
+		logrus.Debugf("use systemd cgroup driver")
+		return NewSystemdManager(path)
+	}
+	return NewCgroupManager(path)
 }
 
 // path是cgroup在hierarchy中的路径 相当于创建的cgroup目录相对于root cgroup目录的路径
 func NewCgroupManager(path string) CgroupManager {
 	if IsCgroup2UnifiedMode() {
-		logrus.Infof("use cgroup v2")
+		logrus.Debugf("use cgroup v2")
 		return NewCgroupManagerV2(path)
 	}
-	logrus.Infof("use cgroup v1")
+	logrus.Debugf("use cgroup v1")
 	return NewCgroupManagerV1(path)
 }
+
+// CgroupPaths 返回 path 对应的 cgroup 在宿主机上的绝对路径，只计算路径，不会创建cgroup
+func CgroupPaths(path string) map[string]string {
+	if IsCgroup2UnifiedMode() {
+		return NewCgroupManagerV2(path).Paths()
+	}
+	return NewCgroupManagerV1(path).Paths()
+}
+
+// DestroyCgroup 删除 path 对应的 cgroup，cgroup 中还有进程时失败，不存在时直接返回
+func DestroyCgroup(driver, path string) error {
+	if driver == DriverSystemd {
+		return NewSystemdManager(path).Destroy()
+	}
+	if IsCgroup2UnifiedMode() {
+		return NewCgroupManagerV2(path).Destroy()
+	}
+	return NewCgroupManagerV1(path).Destroy()
+}
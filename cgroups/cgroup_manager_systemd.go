@@ -0,0 +1,364 @@
+package cgroups
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/cgroups/systemd"
+	"github.com/sirupsen/logrus"
+)
+
+// scopeWaitTimeout 等待 systemd 把进程移动到 scope 中的最长时间
+const scopeWaitTimeout = 5 * time.Second
+
+// SystemdManager 通过 systemd 的 transient scope unit 管理 cgroup
+/*
+宿主机由 systemd 管理 cgroup 树时，直接在 /sys/fs/cgroup 下创建目录、写入资源限制会和 systemd 冲突，
+例如 systemd 重新加载配置时会覆盖掉写入的限制。因此改为通过 D-Bus 请求 systemd 创建 scope：
+
+1）Apply 时调用 StartTransientUnit 创建 <slice>/<unit>.scope，由 systemd 把进程移动到 scope 中，
+资源限制作为 unit 的属性一起传递
+
+2）之后的 Set 通过 SetUnitProperties 修改属性
+
+3）冻结、读取 OOM 计数等 systemd 没有接管的操作仍然直接读写 cgroup 文件，scope 设置了 Delegate，允许这样做
+
+cgroup v1 下 systemd 不管理 cpuset 和 freezer，这两个 subsystem 仍然通过 cgroupfs 设置
+*/
+type SystemdManager struct {
+	// cgroup 相对于 cgroup 根目录的路径，例如 system.slice/mydocker-<id>.scope
+	Path  string
+	unit  string
+	slice string
+	res   *resource.ResourceConfig
+	fs    CgroupManager // 同一路径的 cgroupfs manager
+}
+
+// NewSystemdManager 创建 systemd manager，path 的最后一级为 scope 名，上一级为 slice 名
+func NewSystemdManager(cgroupPath string) *SystemdManager {
+	slice := path.Base(path.Dir(cgroupPath))
+	if slice == "." || slice == "/" {
+		slice = "-.slice"
+	}
+	return &SystemdManager{
+		Path:  cgroupPath,
+		unit:  path.Base(cgroupPath),
+		slice: slice,
+		fs:    NewCgroupManager(cgroupPath),
+	}
+}
+
+// Apply 创建 scope unit 并把进程 pid 放入其中，等待 systemd 完成移动后才返回
+func (manager *SystemdManager) Apply(pid int) error {
+	properties := []systemd.Property{
+		systemd.NewProperty("Description", "s", "mydocker container "+manager.unit),
+		systemd.NewProperty("Slice", "s", manager.slice),
+		systemd.NewProperty("PIDs", "au", []uint32{uint32(pid)}),
+		systemd.NewProperty("Delegate", "b", true),
+		systemd.NewProperty("DefaultDependencies", "b", false),
+	}
+	if manager.res != nil {
+		resProperties, err := resourceProperties(manager.res, IsCgroup2UnifiedMode())
+		if err != nil {
+			return err
+		}
+		properties = append(properties, resProperties...)
+	}
+
+	conn, err := systemd.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err = conn.StartTransientUnit(manager.unit, "replace", properties); err != nil {
+		return errors.Join(err, fmt.Errorf("start transient unit %s failed", manager.unit))
+	}
+	if err = waitScope(pid, manager.Path); err != nil {
+		return err
+	}
+
+	if !IsCgroup2UnifiedMode() {
+		return manager.applyV1Fallback(pid)
+	}
+	return nil
+}
+
+// Set 设置资源限制，scope 还没有创建时只记录下来，在 Apply 时一起传给 systemd
+func (manager *SystemdManager) Set(res *resource.ResourceConfig) error {
+	manager.res = res
+	if !manager.exists() {
+		return nil
+	}
+	properties, err := resourceProperties(res, IsCgroup2UnifiedMode())
+	if err != nil {
+		return err
+	}
+	if len(properties) > 0 {
+		conn, err := systemd.Dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err = conn.SetUnitProperties(manager.unit, true, properties); err != nil {
+			return errors.Join(err, fmt.Errorf("set properties of unit %s failed", manager.unit))
+		}
+	}
+	if !IsCgroup2UnifiedMode() {
+		return manager.fs.Set(v1FallbackResources(res))
+	}
+	return nil
+}
+
+// Destroy 停止 scope unit 并清除 failed 状态，unit 已经不存在时直接返回
+func (manager *SystemdManager) Destroy() error {
+	conn, err := systemd.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = conn.StopUnit(manager.unit, "replace"); err != nil && !systemd.IsNoSuchUnit(err) {
+		return errors.Join(err, fmt.Errorf("stop unit %s failed", manager.unit))
+	}
+	if err = conn.ResetFailedUnit(manager.unit); err != nil && !systemd.IsNoSuchUnit(err) {
+		logrus.Warnf("reset failed unit %s error %v", manager.unit, err)
+	}
+	// cgroup v1 下通过 cgroupfs 创建的 cpuset、freezer 目录 systemd 不会删除
+	if !IsCgroup2UnifiedMode() {
+		return manager.fs.Destroy()
+	}
+	return nil
+}
+
+// OOMEvents 从 scope 的 cgroup 中读取 OOM 计数
+func (manager *SystemdManager) OOMEvents() (*resource.OOMEvents, error) {
+	return manager.fs.OOMEvents()
+}
+
+// NotifyOOM 直接监听 scope 的 cgroup 中的 OOM 事件
+func (manager *SystemdManager) NotifyOOM(done <-chan struct{}) (<-chan struct{}, error) {
+	return manager.fs.NotifyOOM(done)
+}
+
+// MemoryUsage 从 scope 的 cgroup 中读取当前使用的内存
+func (manager *SystemdManager) MemoryUsage() (uint64, error) {
+	return manager.fs.MemoryUsage()
+}
+
+// Stats 从 scope 的 cgroup 中读取资源使用情况
+func (manager *SystemdManager) Stats() (*resource.Stats, error) {
+	return manager.fs.Stats()
+}
+
+// Freeze 冻结 scope 中的全部进程
+func (manager *SystemdManager) Freeze() error {
+	return manager.fs.Freeze()
+}
+
+// Thaw 恢复 scope 中被冻结的进程
+func (manager *SystemdManager) Thaw() error {
+	return manager.fs.Thaw()
+}
+
+// Paths 返回 scope 在宿主机上的绝对路径
+func (manager *SystemdManager) Paths() map[string]string {
+	return manager.fs.Paths()
+}
+
+// exists 判断 scope 对应的 cgroup 是否已经存在
+func (manager *SystemdManager) exists() bool {
+	for _, p := range manager.Paths() {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// applyV1Fallback cgroup v1 下 systemd 不管理的 cpuset、freezer 以及 systemd 没有对应属性的资源限制通过 cgroupfs 设置
+func (manager *SystemdManager) applyV1Fallback(pid int) error {
+	if err := manager.fs.Set(v1FallbackResources(manager.res)); err != nil {
+		return err
+	}
+	return manager.fs.Apply(pid)
+}
+
+// waitScope 等待 systemd 执行完 StartTransientUnit 的 job，即进程 pid 出现在 cgroupPath 中
+func waitScope(pid int, cgroupPath string) error {
+	deadline := time.Now().Add(scopeWaitTimeout)
+	suffix := "/" + strings.TrimPrefix(cgroupPath, "/")
+	for {
+		content, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			// 形如 0::/system.slice/mydocker-<id>.scope 或者 4:memory:/system.slice/mydocker-<id>.scope
+			if strings.HasSuffix(line, ":"+suffix) {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("process %d was not moved to %s within %s", pid, cgroupPath, scopeWaitTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// resourceProperties 将资源限制转换为 systemd unit 的属性，unified 表示宿主机使用 cgroup v2
+/*
+cgroup v2 使用 MemoryMax、CPUWeight、IOWeight、AllowedCPUs 等属性，cgroup v1 使用 MemoryLimit、CPUShares、BlockIOWeight，
+v1 下 systemd 没有对应属性的 swap、软限制、cpuset 和 IO 限速由 cgroupfs 设置，见 v1FallbackResources。
+CPU 配额换算为每秒可以使用的 CPU 时间
+*/
+func resourceProperties(res *resource.ResourceConfig, unified bool) ([]systemd.Property, error) {
+	var properties []systemd.Property
+	memory := func(name, value string) error {
+		n, err := resource.ParseMemory(value)
+		if err != nil {
+			return err
+		}
+		limit := uint64(math.MaxUint64)
+		if n != resource.MemoryUnlimited {
+			limit = uint64(n)
+		}
+		properties = append(properties, systemd.NewProperty(name, "t", limit))
+		return nil
+	}
+
+	var limit int64
+	if res.MemoryLimit != "" {
+		name := "MemoryLimit"
+		if unified {
+			name = "MemoryMax"
+		}
+		if err := memory(name, res.MemoryLimit); err != nil {
+			return nil, err
+		}
+		limit, _ = resource.ParseMemory(res.MemoryLimit)
+	}
+	if unified && res.MemorySwap != "" {
+		// MemorySwapMax 只限制 swap，不包含内存
+		swap, err := resource.ParseMemory(res.MemorySwap)
+		if err != nil {
+			return nil, err
+		}
+		if swap != resource.MemoryUnlimited {
+			swap -= limit
+		}
+		if err = memory("MemorySwapMax", strconv.FormatInt(swap, 10)); err != nil {
+			return nil, err
+		}
+	}
+	if unified && res.MemoryReservation != "" {
+		if err := memory("MemoryLow", res.MemoryReservation); err != nil {
+			return nil, err
+		}
+	}
+
+	if quota := res.CpuQuota(int64(time.Second / time.Microsecond)); quota > 0 {
+		properties = append(properties, systemd.NewProperty("CPUQuotaPerSecUSec", "t", uint64(quota)))
+	}
+	if res.CpuShare != "" {
+		shares, err := strconv.ParseUint(res.CpuShare, 10, 64)
+		if err != nil || shares < 2 || shares > 262144 {
+			return nil, fmt.Errorf("invalid cpu shares %s, must be between 2 and 262144", res.CpuShare)
+		}
+		if unified {
+			properties = append(properties, systemd.NewProperty("CPUWeight", "t", resource.CpuSharesToWeight(shares)))
+		} else {
+			properties = append(properties, systemd.NewProperty("CPUShares", "t", shares))
+		}
+	}
+	if unified {
+		for _, cpuset := range []struct{ name, list string }{
+			{"AllowedCPUs", res.CpuSet},
+			{"AllowedMemoryNodes", res.CpuSetMems},
+		} {
+			if cpuset.list == "" {
+				continue
+			}
+			mask, err := cpusetMask(cpuset.list)
+			if err != nil {
+				return nil, err
+			}
+			properties = append(properties, systemd.NewProperty(cpuset.name, "ay", mask))
+		}
+	}
+
+	if res.PidsLimit != 0 {
+		tasks := uint64(math.MaxUint64)
+		if res.PidsLimit > 0 {
+			tasks = uint64(res.PidsLimit)
+		}
+		properties = append(properties, systemd.NewProperty("TasksMax", "t", tasks))
+	}
+	if res.BlkioWeight != 0 {
+		if unified {
+			properties = append(properties, systemd.NewProperty("IOWeight", "t", resource.BlkioWeightToIOWeight(res.BlkioWeight)))
+		} else {
+			properties = append(properties, systemd.NewProperty("BlockIOWeight", "t", uint64(res.BlkioWeight)))
+		}
+	}
+	if unified {
+		for _, throttle := range []struct {
+			name    string
+			devices []resource.ThrottleDevice
+		}{
+			{"IOReadBandwidthMax", res.BlkioDeviceReadBps},
+			{"IOWriteBandwidthMax", res.BlkioDeviceWriteBps},
+			{"IOReadIOPSMax", res.BlkioDeviceReadIOps},
+			{"IOWriteIOPSMax", res.BlkioDeviceWriteIOps},
+		} {
+			if len(throttle.devices) == 0 {
+				continue
+			}
+			// 签名为 a(st)，设备用 /dev/block/<major>:<minor> 表示
+			devices := make([]any, 0, len(throttle.devices))
+			for _, d := range throttle.devices {
+				devices = append(devices, []any{fmt.Sprintf("/dev/block/%d:%d", d.Major, d.Minor), d.Rate})
+			}
+			properties = append(properties, systemd.NewProperty(throttle.name, "a(st)", devices))
+		}
+	}
+	return properties, nil
+}
+
+// v1FallbackResources 返回 cgroup v1 下 systemd 无法设置、需要由 cgroupfs 设置的资源限制
+func v1FallbackResources(res *resource.ResourceConfig) *resource.ResourceConfig {
+	if res == nil {
+		return &resource.ResourceConfig{}
+	}
+	return &resource.ResourceConfig{
+		MemorySwap:           res.MemorySwap,
+		MemoryReservation:    res.MemoryReservation,
+		CpuSet:               res.CpuSet,
+		CpuSetMems:           res.CpuSetMems,
+		BlkioDeviceReadBps:   res.BlkioDeviceReadBps,
+		BlkioDeviceWriteBps:  res.BlkioDeviceWriteBps,
+		BlkioDeviceReadIOps:  res.BlkioDeviceReadIOps,
+		BlkioDeviceWriteIOps: res.BlkioDeviceWriteIOps,
+	}
+}
+
+// cpusetMask 将 0-2,4 这样的 cpu 列表转换为 systemd AllowedCPUs 使用的位图，第 i 个 cpu 对应第 i/8 个字节的第 i%8 位
+func cpusetMask(cpus string) ([]byte, error) {
+	ids, err := resource.ParseCpuList(cpus)
+	if err != nil {
+		return nil, err
+	}
+	var mask []byte
+	for _, id := range ids {
+		for len(mask) <= id/8 {
+			mask = append(mask, 0)
+		}
+		mask[id/8] |= 1 << (id % 8)
+	}
+	return mask, nil
+}
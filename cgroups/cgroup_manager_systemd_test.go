@@ -0,0 +1,64 @@
+package cgroups
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/cgroups/systemd"
+)
+
+func TestResourceProperties(t *testing.T) {
+	res := &resource.ResourceConfig{
+		MemoryLimit:        "100m",
+		MemorySwap:         "300m",
+		MemoryReservation:  "50m",
+		Cpus:               0.5,
+		CpuShare:           "1024",
+		CpuSet:             "0-2,9",
+		PidsLimit:          -1,
+		BlkioWeight:        500,
+		BlkioDeviceReadBps: []resource.ThrottleDevice{{Major: 8, Minor: 0, Rate: 1 << 20}},
+	}
+
+	got, err := resourceProperties(res, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []systemd.Property{
+		systemd.NewProperty("MemoryMax", "t", uint64(100<<20)),
+		systemd.NewProperty("MemorySwapMax", "t", uint64(200<<20)),
+		systemd.NewProperty("MemoryLow", "t", uint64(50<<20)),
+		systemd.NewProperty("CPUQuotaPerSecUSec", "t", uint64(500000)),
+		systemd.NewProperty("CPUWeight", "t", uint64(39)),
+		systemd.NewProperty("AllowedCPUs", "ay", []byte{0x07, 0x02}),
+		systemd.NewProperty("TasksMax", "t", uint64(math.MaxUint64)),
+		systemd.NewProperty("IOWeight", "t", uint64(4950)),
+		systemd.NewProperty("IOReadBandwidthMax", "a(st)", []any{[]any{"/dev/block/8:0", uint64(1 << 20)}}),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unified properties = %v, want %v", got, want)
+	}
+
+	got, err = resourceProperties(res, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []systemd.Property{
+		systemd.NewProperty("MemoryLimit", "t", uint64(100<<20)),
+		systemd.NewProperty("CPUQuotaPerSecUSec", "t", uint64(500000)),
+		systemd.NewProperty("CPUShares", "t", uint64(1024)),
+		systemd.NewProperty("TasksMax", "t", uint64(math.MaxUint64)),
+		systemd.NewProperty("BlockIOWeight", "t", uint64(500)),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("v1 properties = %v, want %v", got, want)
+	}
+
+	for _, res := range []*resource.ResourceConfig{{MemoryLimit: "100x"}, {CpuShare: "1"}, {CpuSet: "3-1"}, {CpuSet: "100000"}} {
+		if _, err = resourceProperties(res, true); err == nil {
+			t.Errorf("expected error for %+v", res)
+		}
+	}
+}
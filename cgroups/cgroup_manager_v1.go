@@ -1,6 +1,9 @@
 package cgroups
 
 import (
+	"errors"
+	"sync/atomic"
+
 	"github.com/NatsuiroGinga/mydocker/cgroups/fs"
 	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
 	"github.com/sirupsen/logrus"
@@ -12,6 +15,8 @@ type CgroupManagerV1 struct {
 	// 资源配置
 	res        *resource.ResourceConfig
 	Subsystems []resource.Subsystem
+	// NotifyOOM 收到的 OOM 通知数
+	oomCount atomic.Uint64
 }
 
 func NewCgroupManagerV1(path string) *CgroupManagerV1 {
@@ -31,14 +36,17 @@ func (manager *CgroupManagerV1) Apply(pid int) error {
 	return nil
 }
 
+// Set 在每个 subsystem 中设置资源限制，某个 subsystem 失败时继续设置其它 subsystem，返回全部错误
 func (manager *CgroupManagerV1) Set(res *resource.ResourceConfig) error {
+	var errs []error
 	for _, sys := range manager.Subsystems {
 		err := sys.Set(manager.Path, res)
 		if err != nil {
 			logrus.Errorf("apply subsystem:%s err:%s", sys.Name(), err)
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 func (manager *CgroupManagerV1) Destroy() error {
@@ -50,3 +58,87 @@ func (manager *CgroupManagerV1) Destroy() error {
 	}
 	return nil
 }
+
+// OOMEvents 从 memory subsystem 的 memory.oom_control 中读取 oom_kill 计数
+//
+// v1 没有记录 OOM 的次数，使用 NotifyOOM 收到的通知数，没有调用过 NotifyOOM 时至少等于 oom_kill 计数
+func (manager *CgroupManagerV1) OOMEvents() (*resource.OOMEvents, error) {
+	memory, err := manager.memory()
+	if err != nil {
+		return nil, err
+	}
+	events, err := memory.OOMEvents(manager.Path)
+	if err != nil {
+		return nil, err
+	}
+	events.OOM = max(manager.oomCount.Load(), events.OOMKill)
+	return events, nil
+}
+
+// NotifyOOM 通过 cgroup.event_control 注册 memory.oom_control 的 eventfd 监听 OOM 事件
+func (manager *CgroupManagerV1) NotifyOOM(done <-chan struct{}) (<-chan struct{}, error) {
+	memory, err := manager.memory()
+	if err != nil {
+		return nil, err
+	}
+	return notifyOOMV1(memory, manager.Path, done, func() { manager.oomCount.Add(1) })
+}
+
+// MemoryUsage 从 memory subsystem 的 memory.usage_in_bytes 中读取当前使用的内存
+func (manager *CgroupManagerV1) MemoryUsage() (uint64, error) {
+	memory, err := manager.memory()
+	if err != nil {
+		return 0, err
+	}
+	return memory.Usage(manager.Path)
+}
+
+func (manager *CgroupManagerV1) memory() (*fs.MemorySubSystem, error) {
+	for _, sys := range manager.Subsystems {
+		if memory, ok := sys.(*fs.MemorySubSystem); ok {
+			return memory, nil
+		}
+	}
+	return nil, errors.New("memory subsystem not found")
+}
+
+// Stats 从各个 subsystem 的 hierarchy 中读取资源使用情况
+func (manager *CgroupManagerV1) Stats() (*resource.Stats, error) {
+	return collectStats(manager.Path, manager.Subsystems)
+}
+
+// Freeze 通过 freezer subsystem 冻结cgroup中的全部进程
+func (manager *CgroupManagerV1) Freeze() error {
+	freezer, err := manager.freezer()
+	if err != nil {
+		return err
+	}
+	return freezer.Freeze(manager.Path)
+}
+
+// Thaw 通过 freezer subsystem 恢复cgroup中被冻结的进程
+func (manager *CgroupManagerV1) Thaw() error {
+	freezer, err := manager.freezer()
+	if err != nil {
+		return err
+	}
+	return freezer.Thaw(manager.Path)
+}
+
+func (manager *CgroupManagerV1) freezer() (*fs.FreezerSubSystem, error) {
+	for _, sys := range manager.Subsystems {
+		if freezer, ok := sys.(*fs.FreezerSubSystem); ok {
+			return freezer, nil
+		}
+	}
+	return nil, errors.New("freezer subsystem not found")
+}
+
+// Paths 返回cgroup在各个 subsystem 的 hierarchy 中的绝对路径
+func (manager *CgroupManagerV1) Paths() map[string]string {
+	paths := make(map[string]string, len(manager.Subsystems))
+	for _, sys := range manager.Subsystems {
+		paths[sys.Name()] = fs.CgroupPath(sys.Name(), manager.Path)
+	}
+	return paths
+}
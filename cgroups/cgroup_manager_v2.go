@@ -2,6 +2,8 @@ package cgroups
 
 import (
 	"errors"
+	"fmt"
+	"os"
 
 	"github.com/NatsuiroGinga/mydocker/cgroups/fs2"
 	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
@@ -31,22 +33,72 @@ func (manager *CgroupManagerV2) Apply(pid int) error {
 	return nil
 }
 
-// Set 设置cgroup资源限制
+// Set 设置cgroup资源限制，某个 subsystem 失败时继续设置其它 subsystem，返回全部错误
 func (manager *CgroupManagerV2) Set(res *resource.ResourceConfig) error {
+	var errs []error
 	for _, subSysIns := range manager.Subsystems {
 		err := subSysIns.Set(manager.Path, res)
 		if err != nil {
 			logrus.Errorf("apply subsystem:%s err:%s", subSysIns.Name(), err)
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // Destroy 释放cgroup
 func (manager *CgroupManagerV2) Destroy() error {
-	if len(manager.Subsystems) > 0 {
-		manager.Subsystems[0].Remove(manager.Path)
-		logrus.Infof("remove cgroup [%s] success", manager.Path)
+	if len(manager.Subsystems) == 0 {
+		return nil
+	}
+	if err := manager.Subsystems[0].Remove(manager.Path); err != nil && !os.IsNotExist(err) {
+		return errors.Join(err, fmt.Errorf("fail to destroy cgroup [%s]", manager.Path))
+	}
+	logrus.Infof("remove cgroup [%s] success", manager.Path)
+	return nil
+}
+
+// OOMEvents 从 memory.events 中读取 oom 和 oom_kill 计数
+func (manager *CgroupManagerV2) OOMEvents() (*resource.OOMEvents, error) {
+	for _, sys := range manager.Subsystems {
+		if memory, ok := sys.(*fs2.MemorySubSystem); ok {
+			return memory.OOMEvents(manager.Path)
+		}
+	}
+	return nil, errors.New("memory subsystem not found")
+}
+
+// NotifyOOM 通过 inotify 监听 memory.events 的修改
+func (manager *CgroupManagerV2) NotifyOOM(done <-chan struct{}) (<-chan struct{}, error) {
+	return notifyOOMV2(manager.Path, done)
+}
+
+// MemoryUsage 从 memory.current 中读取当前使用的内存
+func (manager *CgroupManagerV2) MemoryUsage() (uint64, error) {
+	for _, sys := range manager.Subsystems {
+		if memory, ok := sys.(*fs2.MemorySubSystem); ok {
+			return memory.Usage(manager.Path)
+		}
 	}
-	return errors.New("fail to destroy cgroup")
+	return 0, errors.New("memory subsystem not found")
+}
+
+// Stats 从 cgroup 目录中的 cpu.stat、memory.current、pids.current、io.stat 等文件读取资源使用情况
+func (manager *CgroupManagerV2) Stats() (*resource.Stats, error) {
+	return collectStats(manager.Path, manager.Subsystems)
+}
+
+// Freeze 通过 cgroup.freeze 冻结cgroup中的全部进程
+func (manager *CgroupManagerV2) Freeze() error {
+	return fs2.Freeze(manager.Path)
+}
+
+// Thaw 通过 cgroup.freeze 恢复cgroup中被冻结的进程
+func (manager *CgroupManagerV2) Thaw() error {
+	return fs2.Thaw(manager.Path)
+}
+
+// Paths 返回cgroup的绝对路径，cgroup v2 下所有 subsystem 共用一个 hierarchy
+func (manager *CgroupManagerV2) Paths() map[string]string {
+	return map[string]string{"unified": fs2.CgroupPath(manager.Path)}
 }
@@ -0,0 +1,127 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+)
+
+// BlkioSubSystem 设置块设备 IO 的权重和限速
+type BlkioSubSystem struct {
+}
+
+// Name 返回cgroup名字
+func (s *BlkioSubSystem) Name() string {
+	return "blkio"
+}
+
+// Set 设置 IO 权重和每个设备的读写限速，限速文件每次写入一个设备，格式为 <major>:<minor> <rate>
+/*
+blkio.weight 只在 CFQ 调度器下存在，使用 BFQ 调度器的内核改为 blkio.bfq.weight
+*/
+func (s *BlkioSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
+	throttles := []struct {
+		file    string
+		devices []resource.ThrottleDevice
+	}{
+		{"blkio.throttle.read_bps_device", res.BlkioDeviceReadBps},
+		{"blkio.throttle.write_bps_device", res.BlkioDeviceWriteBps},
+		{"blkio.throttle.read_iops_device", res.BlkioDeviceReadIOps},
+		{"blkio.throttle.write_iops_device", res.BlkioDeviceWriteIOps},
+	}
+	empty := res.BlkioWeight == 0
+	for _, t := range throttles {
+		empty = empty && len(t.devices) == 0
+	}
+	if empty {
+		return nil
+	}
+
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if res.BlkioWeight != 0 {
+		weight := strconv.FormatUint(uint64(res.BlkioWeight), 10)
+		file := "blkio.weight"
+		if _, err = os.Stat(path.Join(subsysCgroupPath, file)); err != nil {
+			file = "blkio.bfq.weight"
+		}
+		if err = writeCgroupFile(subsysCgroupPath, file, weight); err != nil {
+			return err
+		}
+	}
+	for _, t := range throttles {
+		for _, device := range t.devices {
+			value := fmt.Sprintf("%d:%d %d", device.Major, device.Minor, device.Rate)
+			if err = writeCgroupFile(subsysCgroupPath, t.file, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Apply 将pid加入到cgroupPath对应的cgroup中，cgroup 不存在时先创建
+func (s *BlkioSubSystem) Apply(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("get cgroup %s", cgroupPath))
+	}
+	return writeCgroupFile(subsysCgroupPath, "tasks", strconv.Itoa(pid))
+}
+
+// Remove 删除cgroupPath对应的cgroup
+func (s *BlkioSubSystem) Remove(cgroupPath string) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsysCgroupPath)
+}
+
+// GetStats 从 blkio.throttle.io_service_bytes 和 blkio.throttle.io_serviced 中读取所有设备的读写总量
+/*
+文件中每行形如 8:0 Read 4096，最后一行为 Total，这里按 Read、Write 累加全部设备。
+throttle 开头的统计在没有设置限速时也会更新，并且不依赖 IO 调度器
+*/
+func (s *BlkioSubSystem) GetStats(cgroupPath string, stats *resource.Stats) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		file        string
+		read, write *uint64
+	}{
+		{"blkio.throttle.io_service_bytes", &stats.IO.ReadBytes, &stats.IO.WriteBytes},
+		{"blkio.throttle.io_serviced", &stats.IO.ReadIOs, &stats.IO.WriteIOs},
+	} {
+		content, err := os.ReadFile(path.Join(subsysCgroupPath, f.file))
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			value, err := strconv.ParseUint(fields[2], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch fields[1] {
+			case "Read":
+				*f.read += value
+			case "Write":
+				*f.write += value
+			}
+		}
+	}
+	return nil
+}
@@ -32,23 +32,27 @@ func (sys *CPUSubsystem) Set(cgroupPath string, res *resource.ResourceConfig) er
 		return err
 	}
 	// cpu.cfs_period_us & cpu.cfs_quota_us 控制的是CPU使用时间，单位是微秒，比如每1秒钟，这个进程只能使用200ms，相当于只能用20%的CPU
-	if res.CpuCfsQuota != 0 {
+	if quota := res.CpuQuota(PeriodDefault); quota != 0 {
 		// cpu.cfs_period_us 默认为100000，即100ms
 		if err := os.WriteFile(path.Join(subsysCgroupPath, "cpu.cfs_period_us"), []byte(strconv.Itoa(PeriodDefault)), constant.Perm0644); err != nil {
 			return fmt.Errorf("set cgroup cpu share fail %v", err)
 		}
-		// cpu.cfs_quota_us 则根据用户传递的参数来控制，比如参数为20，就是限制为20%CPU，所以把cpu.cfs_quota_us设置为cpu.cfs_period_us的20%就行
-		// 这里只是简单的计算了下，并没有处理一些特殊情况，比如负数什么的
-		if err = os.WriteFile(path.Join(subsysCgroupPath, "cpu.cfs_quota_us"), []byte(strconv.Itoa(PeriodDefault/Percent*res.CpuCfsQuota)), constant.Perm0644); err != nil {
+		// cpu.cfs_quota_us 则根据用户传递的参数来控制，比如参数为20，就是限制为20%CPU，所以把cpu.cfs_quota_us设置为cpu.cfs_period_us的20%，
+		// --cpus 1.5 则设置为 cpu.cfs_period_us 的 1.5 倍
+		if err = os.WriteFile(path.Join(subsysCgroupPath, "cpu.cfs_quota_us"), []byte(strconv.FormatInt(quota, 10)), constant.Perm0644); err != nil {
 			return fmt.Errorf("set cgroup cpu share fail %v", err)
 		}
 	}
+	// cpu.shares 是 CPU 紧张时按比例分配 CPU 时间的权重
+	if res.CpuShare != "" {
+		return writeCgroupFile(subsysCgroupPath, "cpu.shares", res.CpuShare)
+	}
 
 	return nil
 }
 
 func (s *CPUSubsystem) Apply(cgroupPath string, pid int) error {
-	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
 	if err != nil {
 		return errors.Join(err, fmt.Errorf("get cgroup %s", cgroupPath))
 	}
@@ -80,3 +84,19 @@ func (s *CPUSubsystem) Remove(cgroupPath string) error {
 	}
 	return os.RemoveAll(subsysCgroupPath)
 }
+
+// GetStats 从 cpu.stat 中读取 CFS 限流的统计，CPU 使用时间由 cpuacct subsystem 读取
+func (s *CPUSubsystem) GetStats(cgroupPath string, stats *resource.Stats) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	values, err := readKeyedValues(path.Join(subsysCgroupPath, "cpu.stat"))
+	if err != nil {
+		return err
+	}
+	stats.CPU.NrPeriods = values["nr_periods"]
+	stats.CPU.NrThrottled = values["nr_throttled"]
+	stats.CPU.ThrottledUsec = values["throttled_time"] / 1000
+	return nil
+}
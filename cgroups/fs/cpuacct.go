@@ -0,0 +1,70 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+)
+
+// userHZ cpuacct.stat 中时间的单位是 USER_HZ，Linux 上固定为 100
+const userHZ = 100
+
+// CpuacctSubSystem 统计cgroup中进程使用的 CPU 时间，没有可以设置的资源限制
+/*
+cpuacct 经常和 cpu 挂载在同一个 hierarchy 中，此时两者的 cgroup 是同一个目录，重复创建和加入进程不会出错
+*/
+type CpuacctSubSystem struct {
+}
+
+// Name 返回cgroup名字
+func (s *CpuacctSubSystem) Name() string {
+	return "cpuacct"
+}
+
+// Set cpuacct 只统计使用量，不需要设置
+func (s *CpuacctSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
+	return nil
+}
+
+// Apply 将pid加入到cgroupPath对应的cgroup中，cgroup 不存在时先创建
+func (s *CpuacctSubSystem) Apply(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("get cgroup %s", cgroupPath))
+	}
+	return writeCgroupFile(subsysCgroupPath, "tasks", strconv.Itoa(pid))
+}
+
+// Remove 删除cgroupPath对应的cgroup
+func (s *CpuacctSubSystem) Remove(cgroupPath string) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsysCgroupPath)
+}
+
+// GetStats 从 cpuacct.usage 中读取使用的 CPU 总时间，从 cpuacct.stat 中读取用户态和内核态的时间
+func (s *CpuacctSubSystem) GetStats(cgroupPath string, stats *resource.Stats) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	// cpuacct.usage 的单位是纳秒
+	usage, err := readUint(path.Join(subsysCgroupPath, "cpuacct.usage"))
+	if err != nil {
+		return err
+	}
+	stats.CPU.UsageUsec = usage / 1000
+	values, err := readKeyedValues(path.Join(subsysCgroupPath, "cpuacct.stat"))
+	if err != nil {
+		return err
+	}
+	stats.CPU.UserUsec = values["user"] * 1000000 / userHZ
+	stats.CPU.SystemUsec = values["system"] * 1000000 / userHZ
+	return nil
+}
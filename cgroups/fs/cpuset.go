@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
 
 	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
 	"github.com/NatsuiroGinga/mydocker/constant"
@@ -19,15 +20,23 @@ func (s *CpusetSubSystem) Name() string {
 }
 
 func (s *CpusetSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
-	if res.CpuSet == "" {
+	if res.CpuSet == "" && res.CpuSetMems == "" {
 		return nil
 	}
 	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(path.Join(subsysCgroupPath, "cpuset.cpus"), []byte(res.CpuSet), constant.Perm0644); err != nil {
-		return fmt.Errorf("set cgroup cpuset fail %v", err)
+	if err = initCpuset(subsysCgroupPath); err != nil {
+		return err
+	}
+	if res.CpuSet != "" {
+		if err := os.WriteFile(path.Join(subsysCgroupPath, "cpuset.cpus"), []byte(res.CpuSet), constant.Perm0644); err != nil {
+			return fmt.Errorf("set cgroup cpuset fail %v", err)
+		}
+	}
+	if res.CpuSetMems != "" {
+		return writeCgroupFile(subsysCgroupPath, "cpuset.mems", res.CpuSetMems)
 	}
 	return nil
 }
@@ -36,7 +45,9 @@ func (s *CpusetSubSystem) Apply(cgroupPath string, pid int) error {
 	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
 	if err != nil {
 		return errors.Join(err, fmt.Errorf("get cgroup %s", cgroupPath))
-
+	}
+	if err = initCpuset(subsysCgroupPath); err != nil {
+		return err
 	}
 	if err := os.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), constant.Perm0644); err != nil {
 		return fmt.Errorf("set cgroup proc fail %v", err)
@@ -44,6 +55,31 @@ func (s *CpusetSubSystem) Apply(cgroupPath string, pid int) error {
 	return nil
 }
 
+// initCpuset 新建的 cpuset cgroup 的 cpuset.cpus 和 cpuset.mems 为空，此时无法加入进程，
+// 需要从父 cgroup 复制，父 cgroup 也是新建的时候先初始化父 cgroup
+func initCpuset(dir string) error {
+	for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+		content, err := os.ReadFile(path.Join(dir, file))
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(content)) != "" {
+			continue
+		}
+		parent := path.Dir(dir)
+		if err = initCpuset(parent); err != nil {
+			return err
+		}
+		if content, err = os.ReadFile(path.Join(parent, file)); err != nil {
+			return err
+		}
+		if err = os.WriteFile(path.Join(dir, file), content, constant.Perm0644); err != nil {
+			return fmt.Errorf("init %s of %s failed: %v", file, dir, err)
+		}
+	}
+	return nil
+}
+
 func (s *CpusetSubSystem) Remove(cgroupPath string) error {
 	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
 	if err != nil {
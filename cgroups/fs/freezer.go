@@ -0,0 +1,102 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/constant"
+)
+
+const (
+	freezerStateFile = "freezer.state"
+	frozen           = "FROZEN"
+	thawed           = "THAWED"
+)
+
+// FreezerSubSystem freezer subsystem 不限制资源，只用于暂停和恢复 cgroup 中的全部进程
+type FreezerSubSystem struct {
+}
+
+// Name 返回cgroup名字
+func (s *FreezerSubSystem) Name() string {
+	return "freezer"
+}
+
+// Set freezer 没有资源限制，只创建 cgroup
+func (s *FreezerSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
+	_, err := getCgroupPath(s.Name(), cgroupPath, true)
+	return err
+}
+
+// Apply 将pid加入到cgroupPath对应的cgroup中，cgroup 不存在时先创建
+func (s *FreezerSubSystem) Apply(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("get cgroup %s", cgroupPath))
+	}
+	if err = os.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), constant.Perm0644); err != nil {
+		return fmt.Errorf("append pid to cgroup tasks file failed: %v", err)
+	}
+	return nil
+}
+
+// Remove 删除cgroupPath对应的cgroup
+func (s *FreezerSubSystem) Remove(cgroupPath string) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsysCgroupPath)
+}
+
+// Freeze 冻结cgroupPath对应的cgroup中的全部进程，直到 freezer.state 变为 FROZEN 才返回
+func (s *FreezerSubSystem) Freeze(cgroupPath string) error {
+	return s.setState(cgroupPath, frozen)
+}
+
+// Thaw 恢复cgroupPath对应的cgroup中被冻结的进程
+func (s *FreezerSubSystem) Thaw(cgroupPath string) error {
+	return s.setState(cgroupPath, thawed)
+}
+
+// setState 写入 freezer.state 并等待状态生效，冻结超时时恢复为 THAWED
+/*
+写入 FROZEN 后内核逐个冻结进程，期间读到的是 FREEZING，
+cgroup 中有进程正在 fork 等情况下可能需要重新写入才能完成冻结
+*/
+func (s *FreezerSubSystem) setState(cgroupPath, state string) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	stateFile := path.Join(subsysCgroupPath, freezerStateFile)
+
+	deadline := time.Now().Add(resource.FreezeTimeout)
+	for {
+		if err = os.WriteFile(stateFile, []byte(state), constant.Perm0644); err != nil {
+			return fmt.Errorf("write %s to %s failed: %v", state, stateFile, err)
+		}
+		current, err := os.ReadFile(stateFile)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(current)) == state {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(resource.FreezePollInterval)
+	}
+
+	if state == frozen {
+		os.WriteFile(stateFile, []byte(thawed), constant.Perm0644)
+	}
+	return fmt.Errorf("cgroup %s did not become %s within %s", subsysCgroupPath, state, resource.FreezeTimeout)
+}
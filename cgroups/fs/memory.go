@@ -9,6 +9,7 @@ import (
 
 	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
 	"github.com/NatsuiroGinga/mydocker/constant"
+	"golang.org/x/sys/unix"
 )
 
 type MemorySubSystem struct {
@@ -20,26 +21,71 @@ func (s *MemorySubSystem) Name() string {
 }
 
 // Set 设置cgroupPath对应的cgroup的内存资源限制
+/*
+memory.memsw.limit_in_bytes 是内存加 swap 的总限制，内核要求它始终不小于 memory.limit_in_bytes，
+调大内存限制时如果超过了原来的总限制会失败，此时先写入总限制再写入内存限制
+*/
 func (s *MemorySubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
-	if res.MemoryLimit == "" {
+	if res.MemoryLimit == "" && res.MemorySwap == "" && res.MemoryReservation == "" {
 		return nil
 	}
 
+	limit, err := memoryValue(res.MemoryLimit)
+	if err != nil {
+		return err
+	}
+	swap, err := memoryValue(res.MemorySwap)
+	if err != nil {
+		return err
+	}
+	reservation, err := memoryValue(res.MemoryReservation)
+	if err != nil {
+		return err
+	}
 	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
 	if err != nil {
 		return err
 	}
 
 	// 设置这个cgroup的内存限制，即将限制写入到cgroup对应目录的memory.limit_in_bytes 文件中。
-	if err := os.WriteFile(path.Join(subsysCgroupPath, "memory.limit_in_bytes"), []byte(res.MemoryLimit), constant.Perm0644); err != nil {
-		return fmt.Errorf("set cgroup memory fail %v", err)
+	if limit != "" {
+		err = writeCgroupFile(subsysCgroupPath, "memory.limit_in_bytes", limit)
+		if err != nil && swap != "" {
+			if err = writeCgroupFile(subsysCgroupPath, "memory.memsw.limit_in_bytes", swap); err != nil {
+				return err
+			}
+			err = writeCgroupFile(subsysCgroupPath, "memory.limit_in_bytes", limit)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if swap != "" {
+		if err = writeCgroupFile(subsysCgroupPath, "memory.memsw.limit_in_bytes", swap); err != nil {
+			return err
+		}
+	}
+	if reservation != "" {
+		return writeCgroupFile(subsysCgroupPath, "memory.soft_limit_in_bytes", reservation)
 	}
 	return nil
 }
 
-// Apply 将pid加入到cgroupPath对应的cgroup中
+// memoryValue 将 100m 这样的内存大小转换为字节数，-1 表示不限制，为空时返回空
+func memoryValue(memory string) (string, error) {
+	if memory == "" {
+		return "", nil
+	}
+	n, err := resource.ParseMemory(memory)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// Apply 将pid加入到cgroupPath对应的cgroup中，cgroup 不存在时先创建
 func (s *MemorySubSystem) Apply(cgroupPath string, pid int) error {
-	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
 	if err != nil {
 		return errors.Join(err, fmt.Errorf("get cgroup %s", cgroupPath))
 	}
@@ -72,3 +118,82 @@ func (s *MemorySubSystem) Remove(cgroupPath string) error {
 	}
 	return os.RemoveAll(subsysCgroupPath)
 }
+
+// Usage 读取cgroupPath对应的cgroup当前使用的内存，单位是字节
+func (s *MemorySubSystem) Usage(cgroupPath string) (uint64, error) {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return 0, err
+	}
+	return readUint(path.Join(subsysCgroupPath, "memory.usage_in_bytes"))
+}
+
+// OOMEvents 从 memory.oom_control 中读取cgroupPath对应的cgroup中被 OOM killer 杀死的进程数
+//
+// v1 没有记录 OOM 的次数，只能通过 RegisterOOMEvent 注册的 eventfd 自行统计，OOM 总是为 0
+func (s *MemorySubSystem) OOMEvents(cgroupPath string) (*resource.OOMEvents, error) {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	oomKill, err := readKeyedValue(path.Join(subsysCgroupPath, "memory.oom_control"), "oom_kill")
+	if err != nil {
+		return nil, err
+	}
+	return &resource.OOMEvents{OOMKill: oomKill}, nil
+}
+
+// RegisterOOMEvent 通过 cgroup.event_control 为 memory.oom_control 注册 eventfd，cgroup 中每发生一次 OOM，eventfd 都会变为可读
+//
+// cgroup 被删除时 eventfd 同样会变为可读，读到通知后需要检查 cgroup 是否还存在
+func (s *MemorySubSystem) RegisterOOMEvent(cgroupPath string) (*os.File, error) {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	// 注册完成后内核不再需要 memory.oom_control 的 fd
+	oomControl, err := os.Open(path.Join(subsysCgroupPath, "memory.oom_control"))
+	if err != nil {
+		return nil, err
+	}
+	defer oomControl.Close()
+
+	// 使用非阻塞的 eventfd，os.File 才能通过 poller 读取，Close 时正在阻塞的 Read 会返回
+	fd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		return nil, errors.Join(err, errors.New("create eventfd failed"))
+	}
+	eventfd := os.NewFile(uintptr(fd), "oom-eventfd")
+	if err = writeCgroupFile(subsysCgroupPath, "cgroup.event_control", fmt.Sprintf("%d %d", fd, oomControl.Fd())); err != nil {
+		eventfd.Close()
+		return nil, err
+	}
+	return eventfd, nil
+}
+
+// v1 中不限制内存时 memory.limit_in_bytes 为接近 math.MaxInt64 的按页对齐的值
+const unlimitedMemory = 1 << 62
+
+// GetStats 读取内存使用量、内存限制和非活跃的文件页缓存
+func (s *MemorySubSystem) GetStats(cgroupPath string, stats *resource.Stats) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.Memory.Usage, err = readUint(path.Join(subsysCgroupPath, "memory.usage_in_bytes")); err != nil {
+		return err
+	}
+	limit, err := readUint(path.Join(subsysCgroupPath, "memory.limit_in_bytes"))
+	if err != nil {
+		return err
+	}
+	if limit < unlimitedMemory {
+		stats.Memory.Limit = limit
+	}
+	values, err := readKeyedValues(path.Join(subsysCgroupPath, "memory.stat"))
+	if err != nil {
+		return err
+	}
+	stats.Memory.Cache = values["total_inactive_file"]
+	return nil
+}
@@ -0,0 +1,68 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+)
+
+// PidsSubSystem 限制cgroup中的进程数，防止容器中的 fork 炸弹耗尽宿主机的 pid
+type PidsSubSystem struct {
+}
+
+// Name 返回cgroup名字
+func (s *PidsSubSystem) Name() string {
+	return "pids"
+}
+
+// Set 将最大进程数写入 pids.max，-1 表示不限制
+func (s *PidsSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
+	if res.PidsLimit == 0 {
+		return nil
+	}
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	limit := "max"
+	if res.PidsLimit > 0 {
+		limit = strconv.FormatInt(res.PidsLimit, 10)
+	}
+	return writeCgroupFile(subsysCgroupPath, "pids.max", limit)
+}
+
+// Apply 将pid加入到cgroupPath对应的cgroup中，cgroup 不存在时先创建
+func (s *PidsSubSystem) Apply(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("get cgroup %s", cgroupPath))
+	}
+	return writeCgroupFile(subsysCgroupPath, "tasks", strconv.Itoa(pid))
+}
+
+// Remove 删除cgroupPath对应的cgroup
+func (s *PidsSubSystem) Remove(cgroupPath string) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(subsysCgroupPath)
+}
+
+// GetStats 读取当前进程数和最大进程数
+func (s *PidsSubSystem) GetStats(cgroupPath string, stats *resource.Stats) error {
+	subsysCgroupPath, err := getCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.Pids.Current, err = readUint(path.Join(subsysCgroupPath, "pids.current")); err != nil {
+		return err
+	}
+	// 不限制时为 max
+	stats.Pids.Limit, _ = readUint(path.Join(subsysCgroupPath, "pids.max"))
+	return nil
+}
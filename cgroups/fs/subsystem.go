@@ -7,4 +7,8 @@ var SubsystemsIns = []resource.Subsystem{
 	&CpusetSubSystem{},
 	&MemorySubSystem{},
 	&CPUSubsystem{},
+	&CpuacctSubSystem{},
+	&FreezerSubSystem{},
+	&PidsSubSystem{},
+	&BlkioSubSystem{},
 }
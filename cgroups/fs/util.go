@@ -3,9 +3,11 @@ package fs
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/NatsuiroGinga/mydocker/constant"
@@ -17,7 +19,8 @@ const mountPointIndex = 4
 // getCgroupPath 找到cgroup在文件系统中的绝对路径
 /*
 实际就是将根目录和cgroup名称拼接成一个路径。
-如果指定了自动创建，就先检测一下是否存在，如果对应的目录不存在，则说明cgroup不存在，这里就给创建一个
+如果指定了自动创建，就先检测一下是否存在，如果对应的目录不存在，则说明cgroup不存在，这里就给创建一个，
+cgroupPath 可以有多级，例如 mydocker/<容器id>，父 cgroup 不存在时一起创建
 */
 func getCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string, error) {
 	// 不需要自动创建就直接返回
@@ -30,12 +33,21 @@ func getCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string
 	// 指定自动创建时判断是否存在
 	_, err := os.Stat(absPath)
 	if err != nil && errors.Is(err, fs.ErrNotExist) {
-		err = os.Mkdir(absPath, constant.Perm0755)
+		err = os.MkdirAll(absPath, constant.Perm0755)
 		return absPath, err
 	}
 
-	// 其他错误或者没有错误都直接返回，如果err=nil,那么errors.Wrap(err, "")也会是nil
-	return absPath, errors.Join(err, errors.New("create cgroup"))
+	// 其他错误或者没有错误都直接返回，errors.Join 遇到 nil 时不会返回 nil，需要单独判断
+	if err != nil {
+		return absPath, errors.Join(err, errors.New("create cgroup"))
+	}
+	return absPath, nil
+}
+
+// CgroupPath 返回cgroup在某个subsystem的hierarchy中的绝对路径，不会创建目录
+func CgroupPath(subsystem, cgroupPath string) string {
+	absPath, _ := getCgroupPath(subsystem, cgroupPath, false)
+	return absPath
 }
 
 // findCgroupMountPoint 通过/proc/self/mountinfo找出挂载了某个subsystem的hierarchy cgroup根节点所在的目录
@@ -73,3 +85,52 @@ func findCgroupMountPoint(subsystem string) string {
 	}
 	return ""
 }
+
+// writeCgroupFile 将 value 写入 dir 下的 cgroup 文件
+func writeCgroupFile(dir, file, value string) error {
+	if err := os.WriteFile(path.Join(dir, file), []byte(value), constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup %s to %s fail %v", file, value, err)
+	}
+	return nil
+}
+
+// readUint 读取只包含一个整数的 cgroup 文件，例如 memory.usage_in_bytes
+func readUint(file string) (uint64, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// readKeyedValue 读取形如 "key value" 每行一项的 cgroup 文件中 key 对应的值，例如 memory.oom_control
+func readKeyedValue(file, key string) (uint64, error) {
+	values, err := readKeyedValues(file)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := values[key]
+	if !ok {
+		return 0, fmt.Errorf("key %s not found in %s", key, file)
+	}
+	return value, nil
+}
+
+// readKeyedValues 读取形如 "key value" 每行一项的 cgroup 文件中的全部值，值不是整数的行被忽略
+func readKeyedValues(file string) (map[string]uint64, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values, nil
+}
@@ -7,7 +7,6 @@ import (
 	"strconv"
 
 	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
-	"github.com/NatsuiroGinga/mydocker/constant"
 )
 
 type CpuSubSystem struct {
@@ -23,7 +22,8 @@ func (s *CpuSubSystem) Name() string {
 }
 
 func (s *CpuSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
-	if res.CpuCfsQuota == 0 {
+	quota := res.CpuQuota(PeriodDefault)
+	if quota == 0 && res.CpuShare == "" {
 		return nil
 	}
 	subCgroupPath, err := getCgroupPath(cgroupPath, true)
@@ -33,13 +33,19 @@ func (s *CpuSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) erro
 
 	// cpu.cfs_period_us & cpu.cfs_quota_us 控制的是CPU使用时间，单位是微秒，比如每1秒钟，这个进程只能使用200ms，相当于只能用20%的CPU
 	// v2 中直接将 cpu.cfs_period_us & cpu.cfs_quota_us 统一记录到 cpu.max 中，比如 5000 10000 这样就是限制使用 50% cpu
-	if res.CpuCfsQuota != 0 {
-		// cpu.cfs_quota_us 则根据用户传递的参数来控制，比如参数为20，就是限制为20%CPU，所以把cpu.cfs_quota_us设置为cpu.cfs_period_us的20%就行
-		// 这里只是简单的计算了下，并没有处理一些特殊情况，比如负数什么的
-		if err = os.WriteFile(path.Join(subCgroupPath, "cpu.max"), []byte(fmt.Sprintf("%s %d", strconv.Itoa(PeriodDefault/Percent*res.CpuCfsQuota), PeriodDefault)), constant.Perm0644); err != nil {
-			return fmt.Errorf("set cgroup cpu share fail %v", err)
+	if quota != 0 {
+		if err = writeCgroupFile(subCgroupPath, "cpu.max", fmt.Sprintf("%d %d", quota, PeriodDefault)); err != nil {
+			return err
 		}
 	}
+	// v2 没有 cpu.shares，按比例换算成 cpu.weight
+	if res.CpuShare != "" {
+		shares, err := strconv.ParseUint(res.CpuShare, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cpu shares %s", res.CpuShare)
+		}
+		return writeCgroupFile(subCgroupPath, "cpu.weight", strconv.FormatUint(resource.CpuSharesToWeight(shares), 10))
+	}
 	return nil
 }
 
@@ -54,3 +60,24 @@ func (s *CpuSubSystem) Remove(cgroupPath string) error {
 	}
 	return os.Remove(subCgroupPath)
 }
+
+// GetStats 从 cpu.stat 中读取 CPU 使用时间和 CFS 限流的统计，单位都是微秒
+func (s *CpuSubSystem) GetStats(cgroupPath string, stats *resource.Stats) error {
+	subCgroupPath, err := getCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	values, err := readKeyedValues(path.Join(subCgroupPath, "cpu.stat"))
+	if err != nil {
+		return err
+	}
+	stats.CPU = resource.CPUStats{
+		UsageUsec:     values["usage_usec"],
+		UserUsec:      values["user_usec"],
+		SystemUsec:    values["system_usec"],
+		NrPeriods:     values["nr_periods"],
+		NrThrottled:   values["nr_throttled"],
+		ThrottledUsec: values["throttled_usec"],
+	}
+	return nil
+}
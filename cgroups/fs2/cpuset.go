@@ -1,12 +1,9 @@
 package fs2
 
 import (
-	"fmt"
 	"os"
-	"path"
 
 	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
-	"github.com/NatsuiroGinga/mydocker/constant"
 )
 
 type CpusetSubSystem struct {
@@ -17,15 +14,20 @@ func (s *CpusetSubSystem) Name() string {
 }
 
 func (s *CpusetSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
-	if res.CpuSet == "" {
+	if res.CpuSet == "" && res.CpuSetMems == "" {
 		return nil
 	}
 	subCgroupPath, err := getCgroupPath(cgroupPath, true)
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(path.Join(subCgroupPath, "cpuset.cpus"), []byte(res.CpuSet), constant.Perm0644); err != nil {
-		return fmt.Errorf("set cgroup cpuset fail %v", err)
+	if res.CpuSet != "" {
+		if err = writeCgroupFile(subCgroupPath, "cpuset.cpus", res.CpuSet); err != nil {
+			return err
+		}
+	}
+	if res.CpuSetMems != "" {
+		return writeCgroupFile(subCgroupPath, "cpuset.mems", res.CpuSetMems)
 	}
 	return nil
 }
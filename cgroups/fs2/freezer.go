@@ -0,0 +1,56 @@
+package fs2
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/constant"
+)
+
+// Freeze 冻结cgroupPath对应的cgroup中的全部进程，直到 cgroup.events 中 frozen 为 1 才返回
+func Freeze(cgroupPath string) error {
+	return setFrozen(cgroupPath, true)
+}
+
+// Thaw 恢复cgroupPath对应的cgroup中被冻结的进程
+func Thaw(cgroupPath string) error {
+	return setFrozen(cgroupPath, false)
+}
+
+// setFrozen 写入 cgroup.freeze 并等待 cgroup.events 中的 frozen 生效，冻结超时时恢复
+func setFrozen(cgroupPath string, frozen bool) error {
+	subCgroupPath, err := getCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	value, want := "0", uint64(0)
+	if frozen {
+		value, want = "1", 1
+	}
+	if err = os.WriteFile(path.Join(subCgroupPath, "cgroup.freeze"), []byte(value), constant.Perm0644); err != nil {
+		return fmt.Errorf("write cgroup.freeze of %s failed: %v", subCgroupPath, err)
+	}
+
+	deadline := time.Now().Add(resource.FreezeTimeout)
+	for {
+		current, err := readKeyedValue(path.Join(subCgroupPath, "cgroup.events"), "frozen")
+		if err != nil {
+			return err
+		}
+		if current == want {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(resource.FreezePollInterval)
+	}
+
+	if frozen {
+		os.WriteFile(path.Join(subCgroupPath, "cgroup.freeze"), []byte("0"), constant.Perm0644)
+	}
+	return fmt.Errorf("cgroup %s did not become frozen=%s within %s", subCgroupPath, value, resource.FreezeTimeout)
+}
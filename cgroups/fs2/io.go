@@ -0,0 +1,120 @@
+package fs2
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+)
+
+// IOSubSystem 设置块设备 IO 的权重和限速，对应 v1 的 blkio
+type IOSubSystem struct {
+}
+
+// Name 返回cgroup名字
+func (s *IOSubSystem) Name() string {
+	return "io"
+}
+
+// Set 设置 IO 权重和每个设备的读写限速
+/*
+v2 中同一设备的各项限速写在 io.max 的同一行，格式为 <major>:<minor> rbps=<n> wbps=<n> riops=<n> wiops=<n>，
+没有指定的项保持不变。io.weight 的范围与 v1 的 blkio.weight 不同，需要换算
+*/
+func (s *IOSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
+	type device struct{ major, minor int64 }
+	var devices []device
+	limits := make(map[device][]string)
+	for _, t := range []struct {
+		key     string
+		devices []resource.ThrottleDevice
+	}{
+		{"rbps", res.BlkioDeviceReadBps},
+		{"wbps", res.BlkioDeviceWriteBps},
+		{"riops", res.BlkioDeviceReadIOps},
+		{"wiops", res.BlkioDeviceWriteIOps},
+	} {
+		for _, d := range t.devices {
+			key := device{d.Major, d.Minor}
+			if _, ok := limits[key]; !ok {
+				devices = append(devices, key)
+			}
+			limits[key] = append(limits[key], fmt.Sprintf("%s=%d", t.key, d.Rate))
+		}
+	}
+	if res.BlkioWeight == 0 && len(devices) == 0 {
+		return nil
+	}
+
+	subCgroupPath, err := getCgroupPath(cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if res.BlkioWeight != 0 {
+		weight := fmt.Sprintf("default %d", resource.BlkioWeightToIOWeight(res.BlkioWeight))
+		if err = writeCgroupFile(subCgroupPath, "io.weight", weight); err != nil {
+			return err
+		}
+	}
+	for _, d := range devices {
+		value := fmt.Sprintf("%d:%d %s", d.major, d.minor, strings.Join(limits[d], " "))
+		if err = writeCgroupFile(subCgroupPath, "io.max", value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply 将pid加入到cgroupPath对应的cgroup中
+func (s *IOSubSystem) Apply(cgroupPath string, pid int) error {
+	return applyCgroup(pid, cgroupPath)
+}
+
+// Remove 删除cgroupPath对应的cgroup
+func (s *IOSubSystem) Remove(cgroupPath string) error {
+	subCgroupPath, err := getCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.Remove(subCgroupPath)
+}
+
+// GetStats 从 io.stat 中读取所有设备的读写总量
+//
+// io.stat 中每个设备一行，形如 8:0 rbytes=4096 wbytes=0 rios=1 wios=0 dbytes=0 dios=0
+func (s *IOSubSystem) GetStats(cgroupPath string, stats *resource.Stats) error {
+	subCgroupPath, err := getCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path.Join(subCgroupPath, "io.stat"))
+	if err != nil {
+		return err
+	}
+	counters := map[string]*uint64{
+		"rbytes": &stats.IO.ReadBytes,
+		"wbytes": &stats.IO.WriteBytes,
+		"rios":   &stats.IO.ReadIOs,
+		"wios":   &stats.IO.WriteIOs,
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			counter, ok := counters[key]
+			if !ok {
+				continue
+			}
+			if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+				*counter += n
+			}
+		}
+	}
+	return nil
+}
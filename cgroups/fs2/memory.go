@@ -1,12 +1,11 @@
 package fs2
 
 import (
-	"fmt"
 	"os"
 	"path"
+	"strconv"
 
 	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
-	"github.com/NatsuiroGinga/mydocker/constant"
 )
 
 type MemorySubSystem struct {
@@ -18,21 +17,58 @@ func (s *MemorySubSystem) Name() string {
 }
 
 // Set 设置cgroupPath对应的cgroup的内存资源限制
+/*
+v2 的 memory.swap.max 只限制 swap，不像 v1 的 memory.memsw.limit_in_bytes 那样包含内存，
+因此写入的是 swap 总限制减去内存限制。软限制对应 memory.low
+*/
 func (s *MemorySubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
-	if res.MemoryLimit == "" {
+	if res.MemoryLimit == "" && res.MemorySwap == "" && res.MemoryReservation == "" {
 		return nil
 	}
 	subCgroupPath, err := getCgroupPath(cgroupPath, true)
 	if err != nil {
 		return err
 	}
-	// 设置这个cgroup的内存限制，即将限制写入到 cgroup 对应目录的 memory.limit_in_bytes 文件中。
-	if err := os.WriteFile(path.Join(subCgroupPath, "memory.max"), []byte(res.MemoryLimit), constant.Perm0644); err != nil {
-		return fmt.Errorf("set cgroup memory fail %v", err)
+
+	var limit int64
+	if res.MemoryLimit != "" {
+		if limit, err = resource.ParseMemory(res.MemoryLimit); err != nil {
+			return err
+		}
+		if err = writeCgroupFile(subCgroupPath, "memory.max", memoryValue(limit)); err != nil {
+			return err
+		}
+	}
+	if res.MemorySwap != "" {
+		swap, err := resource.ParseMemory(res.MemorySwap)
+		if err != nil {
+			return err
+		}
+		if swap != resource.MemoryUnlimited {
+			swap -= limit
+		}
+		if err = writeCgroupFile(subCgroupPath, "memory.swap.max", memoryValue(swap)); err != nil {
+			return err
+		}
+	}
+	if res.MemoryReservation != "" {
+		reservation, err := resource.ParseSize(res.MemoryReservation)
+		if err != nil {
+			return err
+		}
+		return writeCgroupFile(subCgroupPath, "memory.low", memoryValue(reservation))
 	}
 	return nil
 }
 
+// memoryValue 将字节数转换为 v2 内存文件的取值，不限制时为 max
+func memoryValue(n int64) string {
+	if n == resource.MemoryUnlimited {
+		return "max"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
 // Apply 将pid加入到cgroupPath对应的cgroup中
 func (s *MemorySubSystem) Apply(cgroupPath string, pid int) error {
 	return applyCgroup(pid, cgroupPath)
@@ -46,3 +82,45 @@ func (s *MemorySubSystem) Remove(cgroupPath string) error {
 	}
 	return os.Remove(subCgroupPath)
 }
+
+// Usage 读取cgroupPath对应的cgroup当前使用的内存，单位是字节
+func (s *MemorySubSystem) Usage(cgroupPath string) (uint64, error) {
+	subCgroupPath, err := getCgroupPath(cgroupPath, false)
+	if err != nil {
+		return 0, err
+	}
+	return readUint(path.Join(subCgroupPath, "memory.current"))
+}
+
+// OOMEvents 从 memory.events 中读取cgroupPath对应的cgroup中 OOM 的次数和被 OOM killer 杀死的进程数
+func (s *MemorySubSystem) OOMEvents(cgroupPath string) (*resource.OOMEvents, error) {
+	subsysCgroupPath, err := getCgroupPath(cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	values, err := readKeyedValues(path.Join(subsysCgroupPath, "memory.events"))
+	if err != nil {
+		return nil, err
+	}
+	return &resource.OOMEvents{OOM: values["oom"], OOMKill: values["oom_kill"]}, nil
+}
+
+// GetStats 读取内存使用量、内存限制和非活跃的文件页缓存
+func (s *MemorySubSystem) GetStats(cgroupPath string, stats *resource.Stats) error {
+	subCgroupPath, err := getCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.Memory.Usage, err = readUint(path.Join(subCgroupPath, "memory.current")); err != nil {
+		return err
+	}
+	if stats.Memory.Limit, err = readLimit(path.Join(subCgroupPath, "memory.max")); err != nil {
+		return err
+	}
+	values, err := readKeyedValues(path.Join(subCgroupPath, "memory.stat"))
+	if err != nil {
+		return err
+	}
+	stats.Memory.Cache = values["inactive_file"]
+	return nil
+}
@@ -0,0 +1,61 @@
+package fs2
+
+import (
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+)
+
+// PidsSubSystem 限制cgroup中的进程数
+type PidsSubSystem struct {
+}
+
+// Name 返回cgroup名字
+func (s *PidsSubSystem) Name() string {
+	return "pids"
+}
+
+// Set 将最大进程数写入 pids.max，-1 表示不限制
+func (s *PidsSubSystem) Set(cgroupPath string, res *resource.ResourceConfig) error {
+	if res.PidsLimit == 0 {
+		return nil
+	}
+	subCgroupPath, err := getCgroupPath(cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	limit := "max"
+	if res.PidsLimit > 0 {
+		limit = strconv.FormatInt(res.PidsLimit, 10)
+	}
+	return writeCgroupFile(subCgroupPath, "pids.max", limit)
+}
+
+// Apply 将pid加入到cgroupPath对应的cgroup中
+func (s *PidsSubSystem) Apply(cgroupPath string, pid int) error {
+	return applyCgroup(pid, cgroupPath)
+}
+
+// Remove 删除cgroupPath对应的cgroup
+func (s *PidsSubSystem) Remove(cgroupPath string) error {
+	subCgroupPath, err := getCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	return os.Remove(subCgroupPath)
+}
+
+// GetStats 读取当前进程数和最大进程数
+func (s *PidsSubSystem) GetStats(cgroupPath string, stats *resource.Stats) error {
+	subCgroupPath, err := getCgroupPath(cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.Pids.Current, err = readUint(path.Join(subCgroupPath, "pids.current")); err != nil {
+		return err
+	}
+	stats.Pids.Limit, err = readLimit(path.Join(subCgroupPath, "pids.max"))
+	return err
+}
@@ -6,4 +6,6 @@ var Subsystems = []resource.Subsystem{
 	&CpuSubSystem{},
 	&MemorySubSystem{},
 	&CpusetSubSystem{},
+	&PidsSubSystem{},
+	&IOSubSystem{},
 }
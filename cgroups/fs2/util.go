@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/NatsuiroGinga/mydocker/constant"
 	"github.com/sirupsen/logrus"
@@ -16,7 +18,8 @@ const UnifiedMountpoint = "/sys/fs/cgroup"
 // getCgroupPath 找到cgroup在文件系统中的绝对路径
 /*
 实际就是将根目录和cgroup名称拼接成一个路径。
-如果指定了自动创建，就先检测一下是否存在，如果对应的目录不存在，则说明cgroup不存在，这里就给创建一个
+如果指定了自动创建，就先检测一下是否存在，如果对应的目录不存在，则说明cgroup不存在，这里就给创建一个。
+cgroupPath 可以有多级，例如 mydocker/<容器id>，创建时逐级开启子 cgroup 需要的 controller
 */
 func getCgroupPath(cgroupPath string, autoCreate bool) (string, error) {
 	// 不需要自动创建就直接返回
@@ -29,13 +32,55 @@ func getCgroupPath(cgroupPath string, autoCreate bool) (string, error) {
 	_, err := os.Stat(absPath)
 	// 只有不存在才创建
 	if err != nil && os.IsNotExist(err) {
-		err = os.Mkdir(absPath, constant.Perm0755)
-		return absPath, err
+		return absPath, createCgroup(cgroupRoot, cgroupPath)
 	}
 
 	return absPath, nil
 }
 
+// createCgroup 从 root 开始逐级创建 cgroupPath 对应的 cgroup
+/*
+cgroup v2 中子 cgroup 只能使用父 cgroup 的 cgroup.subtree_control 中开启了的 controller，
+因此创建每一级子 cgroup 之前，都要在父 cgroup 中开启 Subsystems 需要的 controller
+*/
+func createCgroup(root, cgroupPath string) error {
+	current := root
+	for _, name := range strings.Split(strings.Trim(cgroupPath, "/"), "/") {
+		enableControllers(current)
+		current = path.Join(current, name)
+		if err := os.Mkdir(current, constant.Perm0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// enableControllers 在 dir 的 cgroup.subtree_control 中开启 Subsystems 需要并且可用的 controller
+//
+// 逐个开启，某个 controller 开启失败时只打印警告，不影响其它 controller
+func enableControllers(dir string) {
+	content, err := os.ReadFile(path.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		logrus.Warnf("read controllers of %s failed: %v", dir, err)
+		return
+	}
+	available := strings.Fields(string(content))
+	for _, sys := range Subsystems {
+		if !slices.Contains(available, sys.Name()) {
+			continue
+		}
+		if err = os.WriteFile(path.Join(dir, "cgroup.subtree_control"), []byte("+"+sys.Name()), constant.Perm0644); err != nil {
+			logrus.Warnf("enable controller %s of %s failed: %v", sys.Name(), dir, err)
+		}
+	}
+}
+
+// CgroupPath 返回cgroup的绝对路径，不会创建目录
+func CgroupPath(cgroupPath string) string {
+	absPath, _ := getCgroupPath(cgroupPath, false)
+	return absPath
+}
+
 func applyCgroup(pid int, cgroupPath string) error {
 	subCgroupPath, err := getCgroupPath(cgroupPath, true)
 
@@ -53,3 +98,65 @@ func applyCgroup(pid int, cgroupPath string) error {
 	}
 	return nil
 }
+
+// writeCgroupFile 将 value 写入 cgroup 目录 dir 中的 file
+func writeCgroupFile(dir, file, value string) error {
+	if err := os.WriteFile(path.Join(dir, file), []byte(value), constant.Perm0644); err != nil {
+		return fmt.Errorf("set cgroup %s to %s fail %v", file, value, err)
+	}
+	return nil
+}
+
+// readUint 读取只包含一个整数的 cgroup 文件，例如 memory.usage_in_bytes
+func readUint(file string) (uint64, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// readLimit 读取 memory.max、pids.max 这样的限制，不限制时文件内容为 max，返回 0
+func readLimit(file string) (uint64, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// readKeyedValue 读取形如 "key value" 每行一项的 cgroup 文件中 key 对应的值，例如 memory.events
+func readKeyedValue(file, key string) (uint64, error) {
+	values, err := readKeyedValues(file)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := values[key]
+	if !ok {
+		return 0, fmt.Errorf("key %s not found in %s", key, file)
+	}
+	return value, nil
+}
+
+// readKeyedValues 读取形如 "key value" 每行一项的 cgroup 文件中的全部值，值不是整数的行被忽略
+func readKeyedValues(file string) (map[string]uint64, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values, nil
+}
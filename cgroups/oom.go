@@ -0,0 +1,95 @@
+package cgroups
+
+import (
+	"errors"
+	"os"
+	"path"
+	"unsafe"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/fs"
+	"github.com/NatsuiroGinga/mydocker/cgroups/fs2"
+	"golang.org/x/sys/unix"
+)
+
+// watchFile 在后台循环调用 read 读取 file 中的事件，每读到一个事件向返回的 channel 发送一次通知
+/*
+1）read 返回 false 或者出错时停止，例如 cgroup 已经被删除
+
+2）done 关闭时关闭 file，正在阻塞的 read 会返回错误，因此 file 必须是非阻塞的 fd
+
+3）通知只表示发生了事件，接收方需要自己读取计数。接收方来不及处理时，多个通知合并为一个
+
+停止后返回的 channel 会被关闭
+*/
+func watchFile(file *os.File, done <-chan struct{}, read func(file *os.File) (bool, error)) <-chan struct{} {
+	notify := make(chan struct{}, 1)
+	go func() {
+		<-done
+		file.Close()
+	}()
+	go func() {
+		defer close(notify)
+		for {
+			ok, err := read(file)
+			if err != nil || !ok {
+				return
+			}
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return notify
+}
+
+// notifyOOMV1 通过 memory.oom_control 的 eventfd 监听 OOM，每收到一次通知调用一次 onOOM
+func notifyOOMV1(memory *fs.MemorySubSystem, cgroupPath string, done <-chan struct{}, onOOM func()) (<-chan struct{}, error) {
+	eventfd, err := memory.RegisterOOMEvent(cgroupPath)
+	if err != nil {
+		return nil, err
+	}
+	eventControl := path.Join(fs.CgroupPath(memory.Name(), cgroupPath), "cgroup.event_control")
+	return watchFile(eventfd, done, func(file *os.File) (bool, error) {
+		// eventfd 每次读到的是 8 字节的计数
+		buf := make([]byte, 8)
+		if _, err := file.Read(buf); err != nil {
+			return false, err
+		}
+		// cgroup 被删除时 eventfd 同样会收到通知
+		if _, err := os.Stat(eventControl); err != nil {
+			return false, nil
+		}
+		onOOM()
+		return true, nil
+	}), nil
+}
+
+// notifyOOMV2 通过 inotify 监听 memory.events 的修改，memory.events 中的任意计数增加时都会收到通知
+func notifyOOMV2(cgroupPath string, done <-chan struct{}) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Join(err, errors.New("create inotify failed"))
+	}
+	inotify := os.NewFile(uintptr(fd), "memory-events-inotify")
+	if _, err = unix.InotifyAddWatch(fd, path.Join(fs2.CgroupPath(cgroupPath), "memory.events"), unix.IN_MODIFY); err != nil {
+		inotify.Close()
+		return nil, errors.Join(err, errors.New("watch memory.events failed"))
+	}
+	return watchFile(inotify, done, func(file *os.File) (bool, error) {
+		buf := make([]byte, 4096)
+		n, err := file.Read(buf)
+		if err != nil {
+			return false, err
+		}
+		// 监听的是文件而不是目录，每个事件都没有文件名，cgroup 被删除时会收到 IN_IGNORED
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			if event.Mask&unix.IN_IGNORED != 0 {
+				return false, nil
+			}
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+		}
+		return true, nil
+	}), nil
+}
@@ -0,0 +1,11 @@
+package resource
+
+import "time"
+
+// cgroup v1 的 freezer subsystem 和 cgroup v2 的 cgroup.freeze 共用的等待参数，保证两者的行为一致
+const (
+	// FreezeTimeout 等待 cgroup 中的进程全部冻结或解冻的最长时间
+	FreezeTimeout = 5 * time.Second
+	// FreezePollInterval 检查冻结状态是否生效的间隔
+	FreezePollInterval = 10 * time.Millisecond
+)
@@ -0,0 +1,199 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// MemoryUnlimited 内存、swap 不限制时的取值
+	MemoryUnlimited = -1
+	// minMemoryLimit 内存限制太小时容器进程无法启动
+	minMemoryLimit = 6 << 20
+	// maxCpuList cpuset 中允许的最大 cpu 和内存节点编号
+	maxCpuList = 8192
+)
+
+// ParseSize 解析带单位的大小，支持 b、k、m、g、t 后缀，以 1024 为单位，单位后面可以带 b，例如 100m、1.5GB
+func ParseSize(size string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(size))
+	value = strings.TrimSuffix(value, "b")
+	multiplier := int64(1)
+	if value != "" {
+		if i := strings.IndexByte("kmgt", value[len(value)-1]); i >= 0 {
+			multiplier = 1 << (10 * (i + 1))
+			value = value[:len(value)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) || n*float64(multiplier) >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %s", size)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// ParseMemory 解析内存大小，-1 表示不限制，返回 MemoryUnlimited
+func ParseMemory(memory string) (int64, error) {
+	if strings.TrimSpace(memory) == "-1" {
+		return MemoryUnlimited, nil
+	}
+	return ParseSize(memory)
+}
+
+// CpuSharesToWeight 将 cgroup v1 的 cpu.shares [2, 262144] 线性映射为 cgroup v2 的 cpu.weight [1, 10000]
+func CpuSharesToWeight(shares uint64) uint64 {
+	return 1 + (shares-2)*9999/262142
+}
+
+// BlkioWeightToIOWeight 将 cgroup v1 的 blkio.weight [10, 1000] 线性映射为 cgroup v2 的 io.weight [1, 10000]
+func BlkioWeightToIOWeight(weight uint16) uint64 {
+	return 1 + (uint64(weight)-10)*9999/990
+}
+
+// ParseThrottleBps 解析 <设备路径>:<速率>，例如 /dev/sda:1mb，速率支持 k、m、g 后缀
+func ParseThrottleBps(spec string) (ThrottleDevice, error) {
+	return parseThrottleDevice(spec, func(rate string) (uint64, error) {
+		n, err := ParseSize(rate)
+		return uint64(n), err
+	})
+}
+
+// ParseThrottleIOps 解析 <设备路径>:<每秒操作次数>，例如 /dev/sda:1000
+func ParseThrottleIOps(spec string) (ThrottleDevice, error) {
+	return parseThrottleDevice(spec, func(rate string) (uint64, error) {
+		return strconv.ParseUint(rate, 10, 64)
+	})
+}
+
+// parseThrottleDevice 解析 <设备路径>:<速率>，通过 stat 设备文件得到设备号
+func parseThrottleDevice(spec string, parseRate func(string) (uint64, error)) (ThrottleDevice, error) {
+	path, rate, ok := strings.Cut(spec, ":")
+	if !ok || !strings.HasPrefix(path, "/dev/") {
+		return ThrottleDevice{}, fmt.Errorf("invalid device throttle %s, must be <device-path>:<rate>, e.g. /dev/sda:1mb", spec)
+	}
+	n, err := parseRate(rate)
+	if err != nil || n == 0 {
+		return ThrottleDevice{}, fmt.Errorf("invalid rate %s of device %s", rate, path)
+	}
+	var stat unix.Stat_t
+	if err = unix.Stat(path, &stat); err != nil {
+		return ThrottleDevice{}, errors.Join(err, fmt.Errorf("stat device %s failed", path))
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return ThrottleDevice{}, fmt.Errorf("%s is not a block device", path)
+	}
+	return ThrottleDevice{
+		Major: int64(unix.Major(stat.Rdev)),
+		Minor: int64(unix.Minor(stat.Rdev)),
+		Rate:  n,
+	}, nil
+}
+
+// ParseCpuList 解析 0-2,4 这样的 cpu 或内存节点列表，返回其中的全部编号
+func ParseCpuList(list string) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid cpu list %s", list)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpu list %s", list)
+			}
+		}
+		if end >= maxCpuList {
+			return nil, fmt.Errorf("invalid cpu list %s, must be less than %d", list, maxCpuList)
+		}
+		for id := start; id <= end; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Validate 检查资源限制的取值以及各项之间的关系
+/*
+1）内存相关的值都必须能解析，swap 必须同时指定内存限制并且不小于内存限制，软限制不能大于内存限制
+
+2）--cpus 和百分比形式的 CPU 配额不能同时指定，CPU 数不能超过宿主机的 CPU 数
+
+3）cpu.shares 的范围是 2 到 262144，blkio.weight 的范围是 10 到 1000
+*/
+func (res *ResourceConfig) Validate() error {
+	var memory int64
+	if res.MemoryLimit != "" {
+		var err error
+		if memory, err = ParseMemory(res.MemoryLimit); err != nil {
+			return err
+		}
+		if memory != MemoryUnlimited && memory < minMemoryLimit {
+			return fmt.Errorf("minimum memory limit allowed is 6MB")
+		}
+	}
+	if res.MemorySwap != "" {
+		swap, err := ParseMemory(res.MemorySwap)
+		if err != nil {
+			return err
+		}
+		if memory == 0 || memory == MemoryUnlimited {
+			return errors.New("you should always set the memory limit when using memory swap limit")
+		}
+		if swap != MemoryUnlimited && swap < memory {
+			return errors.New("memory swap limit should be larger than or equal to memory limit")
+		}
+	}
+	if res.MemoryReservation != "" {
+		reservation, err := ParseSize(res.MemoryReservation)
+		if err != nil {
+			return err
+		}
+		if memory > 0 && reservation > memory {
+			return errors.New("memory reservation should be smaller than or equal to memory limit")
+		}
+	}
+
+	if res.CpuCfsQuota < 0 {
+		return fmt.Errorf("invalid cpu quota %d", res.CpuCfsQuota)
+	}
+	if res.Cpus != 0 {
+		if res.CpuCfsQuota != 0 {
+			return errors.New("conflicting options: cpu quota and cpus")
+		}
+		if res.Cpus < 0.01 || res.Cpus > float64(runtime.NumCPU()) {
+			return fmt.Errorf("invalid cpus %g, must be between 0.01 and %d", res.Cpus, runtime.NumCPU())
+		}
+	}
+	if res.CpuShare != "" {
+		shares, err := strconv.ParseUint(res.CpuShare, 10, 64)
+		if err != nil || shares < 2 || shares > 262144 {
+			return fmt.Errorf("invalid cpu shares %s, must be between 2 and 262144", res.CpuShare)
+		}
+	}
+	if res.CpuSet != "" {
+		if _, err := ParseCpuList(res.CpuSet); err != nil {
+			return err
+		}
+	}
+	if res.CpuSetMems != "" {
+		if _, err := ParseCpuList(res.CpuSetMems); err != nil {
+			return err
+		}
+	}
+
+	if res.PidsLimit < -1 {
+		return fmt.Errorf("invalid pids limit %d", res.PidsLimit)
+	}
+	if res.BlkioWeight != 0 && (res.BlkioWeight < 10 || res.BlkioWeight > 1000) {
+		return fmt.Errorf("invalid blkio weight %d, must be between 10 and 1000", res.BlkioWeight)
+	}
+	return nil
+}
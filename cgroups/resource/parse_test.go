@@ -0,0 +1,92 @@
+package resource
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size string
+		want int64
+		ok   bool
+	}{
+		{"1024", 1024, true},
+		{"4k", 4 << 10, true},
+		{"100M", 100 << 20, true},
+		{"2gb", 2 << 30, true},
+		{"1.5g", 3 << 29, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"10x", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.size)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v", tt.size, got, err)
+		}
+	}
+	if n, err := ParseMemory("-1"); err != nil || n != MemoryUnlimited {
+		t.Errorf("ParseMemory(-1) = %d, %v", n, err)
+	}
+}
+
+func TestParseCpuList(t *testing.T) {
+	got, err := ParseCpuList("0-2,5")
+	if err != nil || !reflect.DeepEqual(got, []int{0, 1, 2, 5}) {
+		t.Fatalf("ParseCpuList = %v, %v", got, err)
+	}
+	for _, list := range []string{"", "3-1", "a", "8192"} {
+		if _, err = ParseCpuList(list); err == nil {
+			t.Errorf("expected error for %q", list)
+		}
+	}
+}
+
+func TestCpuQuota(t *testing.T) {
+	tests := []struct {
+		res  ResourceConfig
+		want int64
+	}{
+		{ResourceConfig{}, 0},
+		{ResourceConfig{CpuCfsQuota: 50}, 50000},
+		{ResourceConfig{Cpus: 1.5}, 150000},
+	}
+	for _, tt := range tests {
+		if got := tt.res.CpuQuota(100000); got != tt.want {
+			t.Errorf("CpuQuota(%+v) = %d, want %d", tt.res, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []ResourceConfig{
+		{},
+		{MemoryLimit: "100m", MemorySwap: "200m", MemoryReservation: "50m"},
+		{MemoryLimit: "100m", MemorySwap: "-1"},
+		{MemoryLimit: "-1"},
+		{CpuShare: "512", PidsLimit: -1, BlkioWeight: 300},
+	}
+	for _, res := range valid {
+		if err := res.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", res, err)
+		}
+	}
+	invalid := []ResourceConfig{
+		{MemoryLimit: "1m"},
+		{MemorySwap: "200m"},
+		{MemoryLimit: "100m", MemorySwap: "50m"},
+		{MemoryLimit: "100m", MemoryReservation: "200m"},
+		{CpuCfsQuota: 50, Cpus: 0.5},
+		{Cpus: 100000},
+		{CpuShare: "1"},
+		{CpuSetMems: "1-0"},
+		{PidsLimit: -2},
+		{BlkioWeight: 5},
+	}
+	for _, res := range invalid {
+		if err := res.Validate(); err == nil {
+			t.Errorf("expected error for %+v", res)
+		}
+	}
+}
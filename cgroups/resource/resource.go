@@ -1,9 +1,107 @@
 package resource
 
-// ResourceConfig 用于传递资源限制配置的结构体，包含内存限制，CPU 时间片权重，CPU核心数
+import "slices"
+
+// ResourceConfig 用于传递资源限制配置的结构体，包含内存、CPU、进程数和块设备 IO 的限制，零值表示不限制
 type ResourceConfig struct {
-	MemoryLimit string
-	CpuCfsQuota int
-	CpuShare    string
-	CpuSet      string
+	MemoryLimit       string  // 内存限制，例如 100m
+	MemorySwap        string  // 内存加 swap 的总限制，-1 表示不限制 swap，必须同时指定 MemoryLimit
+	MemoryReservation string  // 内存软限制，内存紧张时尽量把容器的内存回收到该值以下
+	CpuCfsQuota       int     // CPU 配额，单位是百分比，例如 50 表示半个 CPU
+	Cpus              float64 // 可以使用的 CPU 数，例如 1.5，和 CpuCfsQuota 不能同时指定
+	CpuShare          string  // CPU 时间片权重，cgroup v2 中换算为 cpu.weight
+	CpuSet            string  // 可以使用的 CPU，例如 0-2,4
+	CpuSetMems        string  // 可以使用的内存节点，例如 0,1
+	PidsLimit         int64   // 最大进程数，-1 表示不限制
+	BlkioWeight       uint16  // 块设备 IO 权重，范围 10 到 1000
+
+	BlkioDeviceReadBps   []ThrottleDevice // 每个块设备每秒最多读取的字节数
+	BlkioDeviceWriteBps  []ThrottleDevice // 每个块设备每秒最多写入的字节数
+	BlkioDeviceReadIOps  []ThrottleDevice // 每个块设备每秒最多的读操作次数
+	BlkioDeviceWriteIOps []ThrottleDevice // 每个块设备每秒最多的写操作次数
+}
+
+// ThrottleDevice 块设备的 IO 限速，通过设备号指定设备
+type ThrottleDevice struct {
+	Major int64
+	Minor int64
+	Rate  uint64
+}
+
+// CpuQuota 返回每个 period 微秒内可以使用的 CPU 时间，单位是微秒，没有限制时返回 0
+//
+// --cpus 1.5 对应 1.5 个 period，百分比形式的 CpuCfsQuota 50 对应半个 period
+func (res *ResourceConfig) CpuQuota(period int64) int64 {
+	switch {
+	case res.Cpus > 0:
+		return int64(res.Cpus * float64(period))
+	case res.CpuCfsQuota > 0:
+		return period * int64(res.CpuCfsQuota) / 100
+	default:
+		return 0
+	}
+}
+
+// Merge 返回用 update 中指定了的限制覆盖 res 后的新配置，res 和 update 都不会被修改
+/*
+1）update 中为零值的项表示不修改，沿用 res 中的值
+
+2）Cpus 和 CpuCfsQuota 不能同时存在，指定其中一个时清除另一个
+
+3）块设备限速按设备合并，update 中出现的设备覆盖原来的限速，其余设备保持不变
+*/
+func (res *ResourceConfig) Merge(update *ResourceConfig) *ResourceConfig {
+	merged := &ResourceConfig{}
+	if res != nil {
+		*merged = *res
+	}
+	if update == nil {
+		return merged
+	}
+
+	override := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	override(&merged.MemoryLimit, update.MemoryLimit)
+	override(&merged.MemorySwap, update.MemorySwap)
+	override(&merged.MemoryReservation, update.MemoryReservation)
+	override(&merged.CpuShare, update.CpuShare)
+	override(&merged.CpuSet, update.CpuSet)
+	override(&merged.CpuSetMems, update.CpuSetMems)
+	if update.Cpus != 0 {
+		merged.Cpus, merged.CpuCfsQuota = update.Cpus, 0
+	}
+	if update.CpuCfsQuota != 0 {
+		merged.CpuCfsQuota, merged.Cpus = update.CpuCfsQuota, 0
+	}
+	if update.PidsLimit != 0 {
+		merged.PidsLimit = update.PidsLimit
+	}
+	if update.BlkioWeight != 0 {
+		merged.BlkioWeight = update.BlkioWeight
+	}
+	merged.BlkioDeviceReadBps = mergeThrottleDevices(merged.BlkioDeviceReadBps, update.BlkioDeviceReadBps)
+	merged.BlkioDeviceWriteBps = mergeThrottleDevices(merged.BlkioDeviceWriteBps, update.BlkioDeviceWriteBps)
+	merged.BlkioDeviceReadIOps = mergeThrottleDevices(merged.BlkioDeviceReadIOps, update.BlkioDeviceReadIOps)
+	merged.BlkioDeviceWriteIOps = mergeThrottleDevices(merged.BlkioDeviceWriteIOps, update.BlkioDeviceWriteIOps)
+	return merged
+}
+
+// mergeThrottleDevices 用 update 中的设备限速覆盖 devices 中相同设备的限速，返回新的切片
+func mergeThrottleDevices(devices, update []ThrottleDevice) []ThrottleDevice {
+	if len(update) == 0 {
+		return devices
+	}
+	merged := append([]ThrottleDevice(nil), devices...)
+	for _, u := range update {
+		i := slices.IndexFunc(merged, func(d ThrottleDevice) bool { return d.Major == u.Major && d.Minor == u.Minor })
+		if i >= 0 {
+			merged[i] = u
+		} else {
+			merged = append(merged, u)
+		}
+	}
+	return merged
 }
@@ -0,0 +1,37 @@
+package resource
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	res := &ResourceConfig{
+		MemoryLimit:        "100m",
+		CpuCfsQuota:        50,
+		PidsLimit:          20,
+		BlkioDeviceReadBps: []ThrottleDevice{{Major: 8, Minor: 0, Rate: 100}, {Major: 8, Minor: 16, Rate: 200}},
+	}
+	merged := res.Merge(&ResourceConfig{
+		MemoryLimit:        "512m",
+		Cpus:               2,
+		BlkioDeviceReadBps: []ThrottleDevice{{Major: 8, Minor: 16, Rate: 300}, {Major: 7, Minor: 0, Rate: 400}},
+	})
+	want := &ResourceConfig{
+		MemoryLimit:        "512m",
+		Cpus:               2,
+		PidsLimit:          20,
+		BlkioDeviceReadBps: []ThrottleDevice{{Major: 8, Minor: 0, Rate: 100}, {Major: 8, Minor: 16, Rate: 300}, {Major: 7, Minor: 0, Rate: 400}},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Fatalf("Merge = %+v, want %+v", merged, want)
+	}
+	if res.MemoryLimit != "100m" || res.BlkioDeviceReadBps[1].Rate != 200 {
+		t.Fatalf("Merge modified the original config %+v", res)
+	}
+
+	var empty *ResourceConfig
+	if merged = empty.Merge(&ResourceConfig{PidsLimit: 10}); merged.PidsLimit != 10 {
+		t.Fatalf("Merge on nil config = %+v", merged)
+	}
+}
@@ -0,0 +1,46 @@
+package resource
+
+// Stats cgroup 的资源使用情况，cgroup v1 和 v2 读取到的值统一换算为相同的单位
+type Stats struct {
+	CPU    CPUStats    `json:"cpu"`
+	Memory MemoryStats `json:"memory"`
+	Pids   PidsStats   `json:"pids"`
+	IO     IOStats     `json:"io"`
+}
+
+// CPUStats CPU 使用时间，单位都是微秒
+type CPUStats struct {
+	UsageUsec     uint64 `json:"usageUsec"`     // 累计使用的 CPU 时间
+	UserUsec      uint64 `json:"userUsec"`      // 其中用户态的时间
+	SystemUsec    uint64 `json:"systemUsec"`    // 其中内核态的时间
+	NrPeriods     uint64 `json:"nrPeriods"`     // 经过的 CFS 周期数
+	NrThrottled   uint64 `json:"nrThrottled"`   // 用完配额被限流的周期数
+	ThrottledUsec uint64 `json:"throttledUsec"` // 被限流的总时间
+}
+
+// MemoryStats 内存使用量，单位都是字节
+type MemoryStats struct {
+	Usage uint64 `json:"usage"` // 当前使用的内存，包含页缓存
+	Limit uint64 `json:"limit"` // 内存限制，0 表示不限制
+	Cache uint64 `json:"cache"` // 其中可以回收的非活跃文件页缓存
+}
+
+// PidsStats 进程数
+type PidsStats struct {
+	Current uint64 `json:"current"` // 当前的进程数
+	Limit   uint64 `json:"limit"`   // 最大进程数，0 表示不限制
+}
+
+// IOStats 块设备 IO，所有设备的总和
+type IOStats struct {
+	ReadBytes  uint64 `json:"readBytes"`
+	WriteBytes uint64 `json:"writeBytes"`
+	ReadIOs    uint64 `json:"readIos"`
+	WriteIOs   uint64 `json:"writeIos"`
+}
+
+// OOMEvents cgroup 中发生的 OOM 事件计数
+type OOMEvents struct {
+	OOM     uint64 `json:"oom"`     // 内存达到限制并且回收不了，触发 OOM killer 的次数
+	OOMKill uint64 `json:"oomKill"` // 被 OOM killer 杀死的进程数
+}
@@ -19,3 +19,9 @@ type Subsystem interface {
 	// Remove 移除某个Cgroup
 	Remove(path string) error
 }
+
+// StatsGetter 能够读取资源使用情况的 Subsystem 实现该接口
+type StatsGetter interface {
+	// GetStats 读取某个cgroup在这个Subsystem中的资源使用情况，填入 stats 中对应的部分
+	GetStats(path string, stats *Stats) error
+}
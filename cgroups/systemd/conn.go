@@ -0,0 +1,166 @@
+package systemd
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 连接 systemd 的 D-Bus 地址，root 用户优先使用 systemd 的私有 socket，不需要经过 dbus-daemon
+var (
+	PrivateSocket   = "/run/systemd/private"
+	SystemBusSocket = "/run/dbus/system_bus_socket"
+)
+
+// callTimeout 一次方法调用等待回复的最长时间
+const callTimeout = 30 * time.Second
+
+// Error D-Bus 返回的错误，Name 例如 org.freedesktop.systemd1.NoSuchUnit
+type Error struct {
+	Name    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+	return e.Name + ": " + e.Message
+}
+
+// Conn 一个 D-Bus 连接，不是并发安全的
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	serial uint32
+}
+
+// Dial 连接 systemd，私有 socket 不存在时连接 system bus
+func Dial() (*Conn, error) {
+	if _, err := os.Stat(PrivateSocket); err == nil {
+		return dial(PrivateSocket, false)
+	}
+	return dial(SystemBusSocket, true)
+}
+
+// dial 连接 socket 并完成认证，连接的是 bus 时还需要调用 Hello 注册
+func dial(socket string, bus bool) (*Conn, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("connect to dbus %s failed", socket))
+	}
+	c := &Conn{conn: conn, reader: bufio.NewReader(conn)}
+	if err = c.auth(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if bus {
+		if _, err = c.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", ""); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// auth 使用 EXTERNAL 方式认证，即由服务端通过 SO_PEERCRED 检查当前进程的 uid
+/*
+认证阶段是基于行的文本协议：
+
+1）客户端先发送一个 0 字节
+
+2）AUTH EXTERNAL <十六进制编码的 uid>，服务端回复 OK <guid>
+
+3）BEGIN 之后开始传输二进制的消息
+*/
+func (c *Conn) auth() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := fmt.Fprintf(c.conn, "\x00AUTH EXTERNAL %s\r\n", uid); err != nil {
+		return err
+	}
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return errors.Join(err, errors.New("read dbus auth reply failed"))
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("dbus auth rejected: %s", strings.TrimSpace(line))
+	}
+	_, err = io.WriteString(c.conn, "BEGIN\r\n")
+	return err
+}
+
+// Close 关闭连接
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Call 调用 dest 上 path 对象的 iface.member 方法，按照 sig 编码参数，返回解码后的回复
+//
+// 等待回复期间收到的信号等其它消息会被丢弃
+func (c *Conn) Call(dest string, path ObjectPath, iface, member, sig string, args ...any) ([]any, error) {
+	c.serial++
+	call := &message{
+		Type:        typeMethodCall,
+		Serial:      c.serial,
+		Path:        path,
+		Interface:   iface,
+		Member:      member,
+		Destination: dest,
+		Signature:   sig,
+		Body:        args,
+	}
+	data, err := call.marshal()
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("encode %s.%s failed", iface, member))
+	}
+	c.conn.SetDeadline(time.Now().Add(callTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if _, err = c.conn.Write(data); err != nil {
+		return nil, err
+	}
+
+	for {
+		reply, err := readMessage(c.reader)
+		if err != nil {
+			return nil, errors.Join(err, fmt.Errorf("read reply of %s.%s failed", iface, member))
+		}
+		if reply.ReplySerial != call.Serial {
+			continue
+		}
+		switch reply.Type {
+		case typeMethodReturn:
+			return reply.Body, nil
+		case typeError:
+			dbusErr := &Error{Name: reply.ErrorName}
+			if len(reply.Body) > 0 {
+				dbusErr.Message, _ = reply.Body[0].(string)
+			}
+			return nil, dbusErr
+		}
+	}
+}
+
+// readMessage 从 r 中读取一条完整的消息
+func readMessage(r io.Reader) (*message, error) {
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	total, err := messageLen(header)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, total)
+	copy(buf, header)
+	if _, err = io.ReadFull(r, buf[headerLen:]); err != nil {
+		return nil, err
+	}
+	return unmarshalMessage(buf)
+}
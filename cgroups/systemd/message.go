@@ -0,0 +1,528 @@
+package systemd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// D-Bus 消息类型
+const (
+	typeMethodCall   byte = 1
+	typeMethodReturn byte = 2
+	typeError        byte = 3
+	typeSignal       byte = 4
+)
+
+// D-Bus 消息头中的字段编号
+const (
+	fieldPath        byte = 1
+	fieldInterface   byte = 2
+	fieldMember      byte = 3
+	fieldErrorName   byte = 4
+	fieldReplySerial byte = 5
+	fieldDestination byte = 6
+	fieldSender      byte = 7
+	fieldSignature   byte = 8
+)
+
+// headerLen 消息头固定部分的长度：字节序、类型、标志、版本、body 长度、serial，以及头字段数组的长度
+const headerLen = 16
+
+// maxMessageLen D-Bus 规范限制单条消息最大 128MiB
+const maxMessageLen = 128 << 20
+
+// ObjectPath D-Bus 的对象路径，对应签名 o
+type ObjectPath string
+
+// Signature D-Bus 的类型签名，对应签名 g
+type Signature string
+
+// Variant D-Bus 的 variant，对应签名 v，Sig 为 Value 的签名
+type Variant struct {
+	Sig   string
+	Value any
+}
+
+// message 一条 D-Bus 消息，body 为按 signature 解码后的参数
+type message struct {
+	Type        byte
+	Serial      uint32
+	Path        ObjectPath
+	Interface   string
+	Member      string
+	ErrorName   string
+	ReplySerial uint32
+	Destination string
+	Sender      string
+	Signature   string
+	Body        []any
+}
+
+// encoder 按照 D-Bus 的小端序 wire format 编码，对齐以 buffer 起始位置为准，
+// 消息头和 body 都从 8 字节对齐的位置开始，分别编码即可
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+// encode 按照 sig 依次编码 values，sig 中完整类型的个数必须和 values 的个数相同
+func (e *encoder) encode(sig string, values ...any) error {
+	for i := 0; sig != ""; i++ {
+		t, rest, err := nextType(sig)
+		if err != nil {
+			return err
+		}
+		if i >= len(values) {
+			return fmt.Errorf("missing value for signature %s", t)
+		}
+		if err = e.value(t, values[i]); err != nil {
+			return err
+		}
+		sig = rest
+	}
+	return nil
+}
+
+// value 编码一个完整类型 t 的值，数组可以是任意 slice，结构体为 []any
+func (e *encoder) value(t string, v any) error {
+	switch t[0] {
+	case 'y':
+		b, ok := v.(byte)
+		if !ok {
+			return encodeError(t, v)
+		}
+		e.buf = append(e.buf, b)
+	case 'b':
+		b, ok := v.(bool)
+		if !ok {
+			return encodeError(t, v)
+		}
+		var u uint32
+		if b {
+			u = 1
+		}
+		e.uint32(u)
+	case 'i':
+		i, ok := v.(int32)
+		if !ok {
+			return encodeError(t, v)
+		}
+		e.uint32(uint32(i))
+	case 'u':
+		u, ok := v.(uint32)
+		if !ok {
+			return encodeError(t, v)
+		}
+		e.uint32(u)
+	case 'x', 't':
+		var u uint64
+		switch n := v.(type) {
+		case int64:
+			u = uint64(n)
+		case uint64:
+			u = n
+		default:
+			return encodeError(t, v)
+		}
+		e.align(8)
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, u)
+	case 's', 'o':
+		var s string
+		switch str := v.(type) {
+		case string:
+			s = str
+		case ObjectPath:
+			s = string(str)
+		default:
+			return encodeError(t, v)
+		}
+		e.uint32(uint32(len(s)))
+		e.buf = append(append(e.buf, s...), 0)
+	case 'g':
+		var s string
+		switch str := v.(type) {
+		case string:
+			s = str
+		case Signature:
+			s = string(str)
+		default:
+			return encodeError(t, v)
+		}
+		e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+	case 'v':
+		variant, ok := v.(Variant)
+		if !ok {
+			return encodeError(t, v)
+		}
+		if err := e.value("g", variant.Sig); err != nil {
+			return err
+		}
+		return e.value(variant.Sig, variant.Value)
+	case 'a':
+		return e.array(t[1:], v)
+	case '(', '{':
+		fields, ok := v.([]any)
+		if !ok {
+			return encodeError(t, v)
+		}
+		e.align(8)
+		return e.encode(t[1:len(t)-1], fields...)
+	default:
+		return fmt.Errorf("unsupported signature %s", t)
+	}
+	return nil
+}
+
+// array 编码数组，长度不包括第一个元素之前用于对齐的填充
+func (e *encoder) array(elem string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return encodeError("a"+elem, v)
+	}
+	e.uint32(0)
+	lenPos := len(e.buf) - 4
+	e.align(alignment(elem[0]))
+	start := len(e.buf)
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.value(elem, rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	binary.LittleEndian.PutUint32(e.buf[lenPos:], uint32(len(e.buf)-start))
+	return nil
+}
+
+func encodeError(t string, v any) error {
+	return fmt.Errorf("can not encode %T as %s", v, t)
+}
+
+// decoder 解码 D-Bus 的小端序 wire format，对齐以 buf 起始位置为准
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+var errShortBuffer = errors.New("dbus message too short")
+
+func (d *decoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+	if d.pos > len(d.buf) {
+		return errShortBuffer
+	}
+	return nil
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if d.pos+n > len(d.buf) {
+		return nil, errShortBuffer
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// decode 按照 sig 解码全部的值
+func (d *decoder) decode(sig string) ([]any, error) {
+	var values []any
+	for sig != "" {
+		t, rest, err := nextType(sig)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value(t)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		sig = rest
+	}
+	return values, nil
+}
+
+// value 解码一个完整类型 t 的值，数组和结构体都解码为 []any，ay 解码为 []byte
+func (d *decoder) value(t string) (any, error) {
+	switch t[0] {
+	case 'y':
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		u, err := d.uint32()
+		return u != 0, err
+	case 'i':
+		u, err := d.uint32()
+		return int32(u), err
+	case 'u':
+		return d.uint32()
+	case 'x', 't':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		b, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		u := binary.LittleEndian.Uint64(b)
+		if t[0] == 'x' {
+			return int64(u), nil
+		}
+		return u, nil
+	case 's', 'o':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(int(n) + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t[0] == 'o' {
+			return ObjectPath(b[:n]), nil
+		}
+		return string(b[:n]), nil
+	case 'g':
+		n, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(int(n[0]) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return string(b[:n[0]]), nil
+	case 'v':
+		sig, err := d.value("g")
+		if err != nil {
+			return nil, err
+		}
+		if _, rest, err := nextType(sig.(string)); err != nil || rest != "" {
+			return nil, fmt.Errorf("invalid variant signature %s", sig)
+		}
+		v, err := d.value(sig.(string))
+		return Variant{Sig: sig.(string), Value: v}, err
+	case 'a':
+		return d.array(t[1:])
+	case '(', '{':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		return d.decode(t[1 : len(t)-1])
+	default:
+		return nil, fmt.Errorf("unsupported signature %s", t)
+	}
+}
+
+func (d *decoder) array(elem string) (any, error) {
+	n, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if err = d.align(alignment(elem[0])); err != nil {
+		return nil, err
+	}
+	end := d.pos + int(n)
+	if end > len(d.buf) {
+		return nil, errShortBuffer
+	}
+	if elem == "y" {
+		b, _ := d.read(int(n))
+		return append([]byte(nil), b...), nil
+	}
+	values := []any{}
+	for d.pos < end {
+		v, err := d.value(elem)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// alignment 返回类型的对齐字节数
+func alignment(t byte) int {
+	switch t {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 's', 'o', 'a', 'h':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	default:
+		return 1
+	}
+}
+
+// nextType 从签名中取出第一个完整类型，例如 a(sv)s 返回 a(sv) 和 s
+func nextType(sig string) (string, string, error) {
+	if sig == "" {
+		return "", "", errors.New("empty signature")
+	}
+	switch sig[0] {
+	case 'a':
+		elem, rest, err := nextType(sig[1:])
+		if err != nil {
+			return "", "", fmt.Errorf("invalid signature %s", sig)
+		}
+		return "a" + elem, rest, nil
+	case '(', '{':
+		closing := map[byte]byte{'(': ')', '{': '}'}[sig[0]]
+		inner := sig[1:]
+		for inner != "" && inner[0] != closing {
+			_, rest, err := nextType(inner)
+			if err != nil {
+				return "", "", err
+			}
+			inner = rest
+		}
+		if inner == "" {
+			return "", "", fmt.Errorf("invalid signature %s", sig)
+		}
+		n := len(sig) - len(inner) + 1
+		return sig[:n], sig[n:], nil
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g', 'v', 'h':
+		return sig[:1], sig[1:], nil
+	default:
+		return "", "", fmt.Errorf("invalid signature %s", sig)
+	}
+}
+
+// marshal 将消息编码为 wire format
+func (m *message) marshal() ([]byte, error) {
+	body := &encoder{}
+	if err := body.encode(m.Signature, m.Body...); err != nil {
+		return nil, err
+	}
+
+	var fields []any
+	addField := func(code byte, sig string, value any) {
+		fields = append(fields, []any{code, Variant{Sig: sig, Value: value}})
+	}
+	if m.Path != "" {
+		addField(fieldPath, "o", m.Path)
+	}
+	if m.Interface != "" {
+		addField(fieldInterface, "s", m.Interface)
+	}
+	if m.Member != "" {
+		addField(fieldMember, "s", m.Member)
+	}
+	if m.ErrorName != "" {
+		addField(fieldErrorName, "s", m.ErrorName)
+	}
+	if m.ReplySerial != 0 {
+		addField(fieldReplySerial, "u", m.ReplySerial)
+	}
+	if m.Destination != "" {
+		addField(fieldDestination, "s", m.Destination)
+	}
+	if m.Sender != "" {
+		addField(fieldSender, "s", m.Sender)
+	}
+	if m.Signature != "" {
+		addField(fieldSignature, "g", Signature(m.Signature))
+	}
+
+	header := &encoder{buf: []byte{'l', m.Type, 0, 1}}
+	header.uint32(uint32(len(body.buf)))
+	header.uint32(m.Serial)
+	if err := header.encode("a(yv)", fields); err != nil {
+		return nil, err
+	}
+	header.align(8)
+	return append(header.buf, body.buf...), nil
+}
+
+// messageLen 根据消息头的固定部分计算整条消息的长度
+func messageLen(header []byte) (int, error) {
+	if header[0] != 'l' {
+		return 0, fmt.Errorf("unsupported dbus byte order %q", header[0])
+	}
+	bodyLen := binary.LittleEndian.Uint32(header[4:])
+	fieldsLen := binary.LittleEndian.Uint32(header[12:])
+	if bodyLen > maxMessageLen || fieldsLen > maxMessageLen {
+		return 0, errors.New("dbus message too long")
+	}
+	n := headerLen + int(fieldsLen)
+	n += (8 - n%8) % 8
+	return n + int(bodyLen), nil
+}
+
+// unmarshalMessage 解码一条完整的消息
+func unmarshalMessage(buf []byte) (*message, error) {
+	if len(buf) < headerLen {
+		return nil, errShortBuffer
+	}
+	total, err := messageLen(buf)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < total {
+		return nil, errShortBuffer
+	}
+	m := &message{Type: buf[1], Serial: binary.LittleEndian.Uint32(buf[8:])}
+
+	header := &decoder{buf: buf[:total], pos: 12}
+	fields, err := header.value("a(yv)")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields.([]any) {
+		field := f.([]any)
+		value := field[1].(Variant).Value
+		switch field[0].(byte) {
+		case fieldPath:
+			m.Path, _ = value.(ObjectPath)
+		case fieldInterface:
+			m.Interface, _ = value.(string)
+		case fieldMember:
+			m.Member, _ = value.(string)
+		case fieldErrorName:
+			m.ErrorName, _ = value.(string)
+		case fieldReplySerial:
+			m.ReplySerial, _ = value.(uint32)
+		case fieldDestination:
+			m.Destination, _ = value.(string)
+		case fieldSender:
+			m.Sender, _ = value.(string)
+		case fieldSignature:
+			m.Signature, _ = value.(string)
+		}
+	}
+	if err = header.align(8); err != nil {
+		return nil, err
+	}
+
+	body := &decoder{buf: buf[header.pos:total]}
+	if m.Body, err = body.decode(m.Signature); err != nil {
+		return nil, errors.Join(err, fmt.Errorf("decode body of %s", m.Member))
+	}
+	return m, nil
+}
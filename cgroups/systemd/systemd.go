@@ -0,0 +1,104 @@
+// Package systemd 通过 D-Bus 调用 systemd 管理 transient unit，只实现了 mydocker 需要的方法
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	dest    = "org.freedesktop.systemd1"
+	objPath = ObjectPath("/org/freedesktop/systemd1")
+	manager = "org.freedesktop.systemd1.Manager"
+
+	// ErrNoSuchUnit unit 不存在或者没有加载时 systemd 返回的错误名
+	ErrNoSuchUnit = "org.freedesktop.systemd1.NoSuchUnit"
+)
+
+// Property unit 的属性，对应 D-Bus 签名 (sv)
+type Property struct {
+	Name  string
+	Value Variant
+}
+
+// NewProperty 创建属性，sig 为 value 的 D-Bus 签名，例如 MemoryMax 为 t
+func NewProperty(name, sig string, value any) Property {
+	return Property{Name: name, Value: Variant{Sig: sig, Value: value}}
+}
+
+func encodeProperties(properties []Property) []any {
+	values := make([]any, 0, len(properties))
+	for _, p := range properties {
+		values = append(values, []any{p.Name, p.Value})
+	}
+	return values
+}
+
+// StartTransientUnit 创建并启动 transient unit，mode 一般为 replace，返回 systemd 的 job 路径
+//
+// 对应 systemd 的 StartTransientUnit(in s name, in s mode, in a(sv) properties, in a(sa(sv)) aux, out o job)
+func (c *Conn) StartTransientUnit(name, mode string, properties []Property) (ObjectPath, error) {
+	reply, err := c.Call(dest, objPath, manager, "StartTransientUnit", "ssa(sv)a(sa(sv))",
+		name, mode, encodeProperties(properties), []any{})
+	if err != nil {
+		return "", err
+	}
+	if len(reply) != 1 {
+		return "", fmt.Errorf("unexpected reply %v of StartTransientUnit", reply)
+	}
+	job, ok := reply[0].(ObjectPath)
+	if !ok {
+		return "", fmt.Errorf("unexpected reply %v of StartTransientUnit", reply)
+	}
+	return job, nil
+}
+
+// SetUnitProperties 修改正在运行的 unit 的属性，runtime 为 true 时只在本次启动中生效
+func (c *Conn) SetUnitProperties(name string, runtime bool, properties []Property) error {
+	_, err := c.Call(dest, objPath, manager, "SetUnitProperties", "sba(sv)", name, runtime, encodeProperties(properties))
+	return err
+}
+
+// StopUnit 停止 unit，mode 一般为 replace
+func (c *Conn) StopUnit(name, mode string) error {
+	_, err := c.Call(dest, objPath, manager, "StopUnit", "ss", name, mode)
+	return err
+}
+
+// ResetFailedUnit 清除 unit 的 failed 状态，否则 transient unit 失败后会一直保留
+func (c *Conn) ResetFailedUnit(name string) error {
+	_, err := c.Call(dest, objPath, manager, "ResetFailedUnit", "s", name)
+	return err
+}
+
+// IsNoSuchUnit 判断错误是否为 unit 不存在
+func IsNoSuchUnit(err error) bool {
+	var dbusErr *Error
+	return errors.As(err, &dbusErr) && dbusErr.Name == ErrNoSuchUnit
+}
+
+// ExpandSlice 将 slice 名展开为 cgroup 路径，例如 a-b.slice 展开为 a.slice/a-b.slice
+//
+// systemd 用 - 表示 slice 的层级，-.slice 为根 slice，展开为空路径
+func ExpandSlice(slice string) (string, error) {
+	name, ok := strings.CutSuffix(slice, ".slice")
+	if !ok || strings.Contains(name, "/") {
+		return "", fmt.Errorf("invalid slice name %s", slice)
+	}
+	if name == "-" {
+		return "", nil
+	}
+	if name == "" || strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") || strings.Contains(name, "--") {
+		return "", fmt.Errorf("invalid slice name %s", slice)
+	}
+
+	var expanded, prefix string
+	for _, component := range strings.Split(name, "-") {
+		prefix += component
+		expanded = path.Join(expanded, prefix+".slice")
+		prefix += "-"
+	}
+	return expanded, nil
+}
@@ -0,0 +1,167 @@
+package systemd
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// serveFakeSystemd 在临时目录中启动一个只处理一个连接的 D-Bus 服务端，handler 返回方法调用的回复
+func serveFakeSystemd(t *testing.T, handler func(call *message) *message) {
+	socket := filepath.Join(t.TempDir(), "private")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	oldSocket := PrivateSocket
+	PrivateSocket = socket
+	t.Cleanup(func() { PrivateSocket = oldSocket })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		if b, err := reader.ReadByte(); err != nil || b != 0 {
+			return
+		}
+		if line, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, "AUTH EXTERNAL ") {
+			conn.Write([]byte("REJECTED EXTERNAL\r\n"))
+			return
+		}
+		conn.Write([]byte("OK 0123456789abcdef0123456789abcdef\r\n"))
+		if line, err := reader.ReadString('\n'); err != nil || line != "BEGIN\r\n" {
+			return
+		}
+		for serial := uint32(1); ; serial++ {
+			call, err := readMessage(reader)
+			if err != nil {
+				return
+			}
+			reply := handler(call)
+			reply.Serial = serial
+			reply.ReplySerial = call.Serial
+			data, err := reply.marshal()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Write(data)
+		}
+	}()
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := &message{
+		Type:        typeMethodCall,
+		Serial:      7,
+		Path:        objPath,
+		Interface:   manager,
+		Member:      "StartTransientUnit",
+		Destination: dest,
+		Signature:   "ssa(sv)a(sa(sv))",
+		Body: []any{"mydocker-c0.scope", "replace", []any{
+			[]any{"Delegate", Variant{Sig: "b", Value: true}},
+			[]any{"PIDs", Variant{Sig: "au", Value: []any{uint32(1234)}}},
+			[]any{"MemoryMax", Variant{Sig: "t", Value: uint64(100 << 20)}},
+			[]any{"AllowedCPUs", Variant{Sig: "ay", Value: []byte{0x5}}},
+		}, []any{}},
+	}
+	data, err := m.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := unmarshalMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Fatalf("round trip mismatch:\n got %#v\nwant %#v", got, m)
+	}
+}
+
+func TestStartTransientUnit(t *testing.T) {
+	calls := make(chan *message, 4)
+	serveFakeSystemd(t, func(call *message) *message {
+		calls <- call
+		switch call.Member {
+		case "StartTransientUnit":
+			return &message{Type: typeMethodReturn, Signature: "o", Body: []any{ObjectPath("/org/freedesktop/systemd1/job/42")}}
+		case "StopUnit":
+			return &message{Type: typeError, ErrorName: ErrNoSuchUnit, Signature: "s", Body: []any{"Unit mydocker-c0.scope not loaded."}}
+		default:
+			return &message{Type: typeMethodReturn}
+		}
+	})
+
+	conn, err := Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	job, err := conn.StartTransientUnit("mydocker-c0.scope", "replace", []Property{
+		NewProperty("Slice", "s", "system.slice"),
+		NewProperty("PIDs", "au", []uint32{1234}),
+		NewProperty("MemoryMax", "t", uint64(1<<20)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job != "/org/freedesktop/systemd1/job/42" {
+		t.Fatalf("unexpected job %s", job)
+	}
+	call := <-calls
+	if call.Destination != dest || call.Path != objPath || call.Interface != manager || call.Signature != "ssa(sv)a(sa(sv))" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+	wantProperties := []any{
+		[]any{"Slice", Variant{Sig: "s", Value: "system.slice"}},
+		[]any{"PIDs", Variant{Sig: "au", Value: []any{uint32(1234)}}},
+		[]any{"MemoryMax", Variant{Sig: "t", Value: uint64(1 << 20)}},
+	}
+	if call.Body[0] != "mydocker-c0.scope" || call.Body[1] != "replace" || !reflect.DeepEqual(call.Body[2], wantProperties) {
+		t.Fatalf("unexpected body %#v", call.Body)
+	}
+
+	if err = conn.SetUnitProperties("mydocker-c0.scope", true, []Property{NewProperty("CPUWeight", "t", uint64(100))}); err != nil {
+		t.Fatal(err)
+	}
+	if call = <-calls; call.Member != "SetUnitProperties" || call.Body[1] != true {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	err = conn.StopUnit("mydocker-c0.scope", "replace")
+	if !IsNoSuchUnit(err) {
+		t.Fatalf("expected no such unit, got %v", err)
+	}
+}
+
+func TestExpandSlice(t *testing.T) {
+	tests := []struct {
+		slice string
+		want  string
+		ok    bool
+	}{
+		{"-.slice", "", true},
+		{"system.slice", "system.slice", true},
+		{"mydocker-web.slice", "mydocker.slice/mydocker-web.slice", true},
+		{"a-b-c.slice", "a.slice/a-b.slice/a-b-c.slice", true},
+		{"system", "", false},
+		{"a--b.slice", "", false},
+		{"-a.slice", "", false},
+		{"a/b.slice", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ExpandSlice(tt.slice)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ExpandSlice(%q) = %q, %v", tt.slice, got, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"path"
+	"strings"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/cgroups/systemd"
+)
+
+const (
+	// DefaultCgroupParent 没有指定 --cgroup-parent 时容器 cgroup 的父 cgroup，每个容器的 cgroup 为 mydocker/<容器id>
+	DefaultCgroupParent = "mydocker"
+	// DefaultSystemdSlice 使用 systemd 驱动并且没有指定 --cgroup-parent 时容器 scope 所在的 slice
+	DefaultSystemdSlice = "system.slice"
+	// legacyCgroupPath 旧版本中所有容器共用的 cgroup，gc 时清理
+	legacyCgroupPath = "mydocker-cgroup"
+)
+
+// containerCgroupPath 返回容器 cgroup 相对于 cgroup 根目录的路径，parent 需要先通过 validateCgroupParent 检查
+/*
+cgroupfs 驱动下为 <parent>/<容器id>，parent 为空时使用 DefaultCgroupParent。
+
+systemd 驱动下 parent 为 slice 名，容器的 cgroup 为 slice 展开后的路径加上 mydocker-<容器id>.scope，
+例如 system.slice/mydocker-<容器id>.scope
+*/
+func containerCgroupPath(driver, parent, containerId string) string {
+	if driver == cgroups.DriverSystemd {
+		if parent == "" {
+			parent = DefaultSystemdSlice
+		}
+		slicePath, _ := systemd.ExpandSlice(parent)
+		return path.Join(slicePath, "mydocker-"+containerId+".scope")
+	}
+	if parent == "" {
+		parent = DefaultCgroupParent
+	}
+	return path.Join(strings.TrimPrefix(path.Clean("/"+parent), "/"), containerId)
+}
+
+// cgroupParent 由容器 cgroup 的路径得到创建容器时的 parent，是 containerCgroupPath 的逆过程
+func cgroupParent(driver, cgroupPath string) string {
+	parent := path.Dir(cgroupPath)
+	if driver != cgroups.DriverSystemd {
+		return parent
+	}
+	if parent == "." { // 直接位于根 slice 中
+		return "-.slice"
+	}
+	return path.Base(parent)
+}
+
+// validateCgroupParent 检查 --cgroup-driver 和 --cgroup-parent
+//
+// cgroupfs 驱动下 parent 不能包含 ..，以 / 开头时同样相对于 cgroup 根目录；systemd 驱动下 parent 必须是 slice 名
+func validateCgroupParent(driver, parent string) error {
+	if err := cgroups.ValidateDriver(driver); err != nil {
+		return &Error{Kind: ErrInvalidParameter, Err: err}
+	}
+	if parent == "" {
+		return nil
+	}
+	if driver == cgroups.DriverSystemd {
+		if _, err := systemd.ExpandSlice(parent); err != nil {
+			return invalidParameter("invalid cgroup parent %s, must be a slice like system.slice when using the systemd cgroup driver", parent)
+		}
+		return nil
+	}
+	for _, elem := range strings.Split(parent, "/") {
+		if elem == ".." {
+			return invalidParameter("invalid cgroup parent %s, must not contain ..", parent)
+		}
+	}
+	if path.Clean("/"+parent) == "/" {
+		return invalidParameter("invalid cgroup parent %s, must not be the root cgroup", parent)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+)
+
+func TestContainerCgroupPath(t *testing.T) {
+	tests := []struct {
+		driver string
+		parent string
+		want   string
+	}{
+		{"", "", "mydocker/c0"},
+		{cgroups.DriverCgroupfs, "web", "web/c0"},
+		{"", "/web/", "web/c0"},
+		{"", "/system.slice//web", "system.slice/web/c0"},
+		{cgroups.DriverSystemd, "", "system.slice/mydocker-c0.scope"},
+		{cgroups.DriverSystemd, "mydocker-web.slice", "mydocker.slice/mydocker-web.slice/mydocker-c0.scope"},
+		{cgroups.DriverSystemd, "-.slice", "mydocker-c0.scope"},
+	}
+	for _, tt := range tests {
+		if got := containerCgroupPath(tt.driver, tt.parent, "c0"); got != tt.want {
+			t.Errorf("containerCgroupPath(%q, %q) = %s, want %s", tt.driver, tt.parent, got, tt.want)
+		}
+		// 重新启动容器时由 cgroup 路径还原出的 parent 得到相同的路径
+		if got := containerCgroupPath(tt.driver, cgroupParent(tt.driver, tt.want), "c0"); got != tt.want {
+			t.Errorf("containerCgroupPath(%q, cgroupParent(%s)) = %s, want %s", tt.driver, tt.want, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCgroupParent(t *testing.T) {
+	valid := []struct{ driver, parent string }{
+		{"", ""},
+		{"", "web"},
+		{cgroups.DriverCgroupfs, "/web/api"},
+		{cgroups.DriverSystemd, ""},
+		{cgroups.DriverSystemd, "mydocker-web.slice"},
+	}
+	for _, tt := range valid {
+		if err := validateCgroupParent(tt.driver, tt.parent); err != nil {
+			t.Errorf("validateCgroupParent(%q, %q) = %v", tt.driver, tt.parent, err)
+		}
+	}
+	invalid := []struct{ driver, parent string }{
+		{"", "/"},
+		{"", "../web"},
+		{"", "web/../../x"},
+		{cgroups.DriverSystemd, "web"},
+		{cgroups.DriverSystemd, "web/api.slice"},
+		{"lxc", ""},
+	}
+	for _, tt := range invalid {
+		if err := validateCgroupParent(tt.driver, tt.parent); !errors.Is(err, ErrInvalidParameter) {
+			t.Errorf("validateCgroupParent(%q, %q) = %v, want invalid parameter", tt.driver, tt.parent, err)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Package client 在当前进程中直接操作 mydocker 的容器、网络和镜像
+/*
+mydocker 的命令行和 daemon 都通过 Client 管理容器，其它 Go 程序也可以直接导入使用，
+不需要执行 mydocker 命令再解析输出。
+
+容器的 init 进程、shim 进程和 exec 进程都需要由 mydocker 可执行文件启动，
+因此在其它程序中使用时需要通过 New 指定 mydocker 可执行文件的路径。
+
+操作已有容器的方法都可以通过完整的容器 id、唯一的 id 前缀或者容器名指定容器。
+
+所有方法失败时都返回 *Error，可以通过 errors.Is(err, ErrNotFound) 等判断错误类型。
+*/
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+	"github.com/NatsuiroGinga/mydocker/state"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// SelfExe 在 mydocker 进程中使用 Client 时，mydocker 可执行文件的路径
+const SelfExe = "/proc/self/exe"
+
+// Client 管理容器、网络和镜像，需要 root 权限
+type Client struct {
+	binary string // mydocker 可执行文件的路径
+}
+
+// New 创建 Client，binary 为 mydocker 可执行文件的路径
+func New(binary string) *Client {
+	return &Client{binary: binary}
+}
+
+// Create 创建容器并返回容器 id，容器的 init 进程阻塞直到 Start
+//
+// 容器由 shim 进程创建和监控，当前进程退出不影响容器
+func (c *Client) Create(config *ContainerConfig) (string, error) {
+	id, err := c.createDetached(config)
+	return id, wrapError("create", config.Image, err)
+}
+
+// Start 启动 created 状态的容器
+func (c *Client) Start(ref string) error {
+	return wrapError("start", ref, withContainer(ref, startContainer))
+}
+
+// Run 创建并启动容器，返回容器 id，等价于 mydocker run -d
+func (c *Client) Run(config *ContainerConfig) (string, error) {
+	id, err := c.Create(config)
+	if err != nil {
+		return "", err
+	}
+	return id, c.Start(id)
+}
+
+// RunAttached 在当前进程中运行容器，容器进程使用当前进程的标准输入输出，
+// 等待容器退出后返回退出码，等价于 mydocker run -it
+func (c *Client) RunAttached(config *ContainerConfig) (int, error) {
+	exitCode, err := c.runAttached(config)
+	return exitCode, wrapError("run", config.Image, err)
+}
+
+// Stop 停止容器，发送停止信号后等待容器退出，超时后发送 SIGKILL，opts 为 nil 时使用默认的信号和超时时间
+func (c *Client) Stop(ref string, opts *StopOptions) error {
+	return wrapError("stop", ref, withContainer(ref, func(id string) error {
+		return stopContainer(id, opts)
+	}))
+}
+
+// Kill 向容器的 init 进程发送信号，不修改容器状态，signal 支持 9、KILL、SIGKILL 三种写法，为空时为 SIGTERM
+func (c *Client) Kill(ref, signal string) error {
+	return wrapError("kill", ref, withContainer(ref, func(id string) error {
+		return killContainer(id, signal)
+	}))
+}
+
+// Pause 通过 cgroup freezer 暂停容器中的全部进程
+func (c *Client) Pause(ref string) error {
+	return wrapError("pause", ref, withContainer(ref, pauseContainer))
+}
+
+// Unpause 恢复被暂停的容器
+func (c *Client) Unpause(ref string) error {
+	return wrapError("unpause", ref, withContainer(ref, unpauseContainer))
+}
+
+// Update 修改容器的资源限制，res 中为零值的项保持不变，运行中的容器立即生效
+func (c *Client) Update(ref string, res *resource.ResourceConfig) error {
+	return wrapError("update", ref, withContainer(ref, func(id string) error {
+		return updateResources(id, res)
+	}))
+}
+
+// Stats 返回容器当前的资源使用情况，只能读取 created、running、paused 状态的容器
+func (c *Client) Stats(ref string) (*ContainerStats, error) {
+	stats, err := resolveContainer(ref, containerStats)
+	return stats, wrapError("stats", ref, err)
+}
+
+// Remove 删除容器，force 为 true 时先停止运行中的容器
+func (c *Client) Remove(ref string, force bool) error {
+	return wrapError("remove", ref, withContainer(ref, func(id string) error {
+		return removeContainer(id, force)
+	}))
+}
+
+// List 按照创建时间从新到旧列出容器，opts 为 nil 时只列出没有停止的容器
+func (c *Client) List(opts *ListOptions) ([]*container.Info, error) {
+	containers, err := listContainers(opts)
+	return containers, wrapError("list", "", err)
+}
+
+// Inspect 返回容器的详细信息，包括 cgroup 路径、资源限制、挂载点、环境变量和退出状态
+func (c *Client) Inspect(ref string) (*ContainerDetail, error) {
+	detail, err := resolveContainer(ref, inspectContainer)
+	return detail, wrapError("inspect", ref, err)
+}
+
+// Logs 返回后台运行的容器的日志，调用方负责关闭
+func (c *Client) Logs(ref string) (io.ReadCloser, error) {
+	logs, err := resolveContainer(ref, openContainerLog)
+	return logs, wrapError("logs", ref, err)
+}
+
+// Wait 阻塞直到容器退出，返回退出码
+func (c *Client) Wait(ref string) (int, error) {
+	exitCode, err := resolveContainer(ref, waitContainer)
+	return exitCode, wrapError("wait", ref, err)
+}
+
+// Exec 在容器中执行命令，等待命令结束后返回退出码和输出
+func (c *Client) Exec(ref string, cmd []string) (*ExecResult, error) {
+	result, err := resolveContainer(ref, func(id string) (*ExecResult, error) {
+		return c.execOutput(id, cmd)
+	})
+	return result, wrapError("exec", ref, err)
+}
+
+// ExecAttached 在容器中执行命令，命令使用指定的标准输入输出，返回命令的退出码
+func (c *Client) ExecAttached(ref string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	exitCode, err := resolveContainer(ref, func(id string) (int, error) {
+		return c.execAttached(id, cmd, stdin, stdout, stderr)
+	})
+	return exitCode, wrapError("exec", ref, err)
+}
+
+// NetworkCreate 创建网络
+func (c *Client) NetworkCreate(config *NetworkConfig) error {
+	return wrapError("network create", config.Name, createNetwork(config))
+}
+
+// NetworkList 列出满足过滤条件的网络，filters 为空时列出全部网络
+func (c *Client) NetworkList(filters Filters) ([]*Network, error) {
+	networks, err := listNetworks(filters)
+	return networks, wrapError("network list", "", err)
+}
+
+// NetworkInspect 返回网络信息
+func (c *Client) NetworkInspect(name string) (*Network, error) {
+	nw, err := inspectNetwork(name)
+	return nw, wrapError("network inspect", name, err)
+}
+
+// NetworkRemove 删除网络
+func (c *Client) NetworkRemove(name string) error {
+	return wrapError("network remove", name, removeNetwork(name))
+}
+
+// ImageList 列出全部镜像
+func (c *Client) ImageList() ([]*Image, error) {
+	images, err := listImages()
+	return images, wrapError("image list", "", err)
+}
+
+// ImageInspect 返回镜像信息
+func (c *Client) ImageInspect(name string) (*Image, error) {
+	image, err := inspectImage(name)
+	return image, wrapError("image inspect", name, err)
+}
+
+// Commit 将容器的 rootfs 保存为镜像，imageName 为空时以容器 id 作为镜像名，labels 为镜像的标签
+func (c *Client) Commit(ref, imageName string, labels map[string]string) error {
+	return wrapError("commit", ref, withContainer(ref, func(id string) error {
+		return commitContainer(id, imageName, labels)
+	}))
+}
+
+// Reconcile 将 init 进程已经不存在的容器标记为退出，gc 为 true 时还会清理遗留的 overlay 目录、cgroup 和 veth
+//
+// ps、inspect 等读取容器信息的操作会自动修正单个容器的状态，宿主机重启后可以通过 Reconcile 一次性修正
+func (c *Client) Reconcile(gc bool) (*ReconcileReport, error) {
+	report, err := reconcile(gc)
+	return report, wrapError("reconcile", "", err)
+}
+
+// Restore 按照重启策略重新启动已经退出的容器，返回重新启动的容器 id，daemon 启动时在 Reconcile 之后调用
+//
+// always 的容器即使被 mydocker stop 停止过也会重新启动，unless-stopped 的容器被停止过时不会
+func (c *Client) Restore() ([]string, error) {
+	restored, err := c.restoreContainers()
+	return restored, wrapError("restore", "", err)
+}
+
+// DiskUsage 返回镜像、容器和由 mydocker 创建的数据卷占用的磁盘空间
+func (c *Client) DiskUsage() (*DiskUsage, error) {
+	usage, err := diskUsage()
+	return usage, wrapError("system df", "", err)
+}
+
+// Prune 删除已经停止的容器、没有使用的网络和镜像，opts 为 nil 时只删除悬空镜像且不删除数据卷
+func (c *Client) Prune(opts *PruneOptions) (*PruneReport, error) {
+	report, err := prune(opts)
+	return report, wrapError("system prune", "", err)
+}
+
+// Events 按时间顺序对满足条件的容器和网络事件调用 fn，没有指定 until 时持续等待新的事件，直到 ctx 结束或者 fn 返回错误
+func (c *Client) Events(ctx context.Context, opts *EventsOptions, fn func(event *events.Event) error) error {
+	return wrapError("events", "", watchEvents(ctx, opts, fn))
+}
+
+// CreateBundle 根据 OCI bundle 创建容器，对应 OCI 生命周期中的 create 操作
+func (c *Client) CreateBundle(id, bundle string) error {
+	return wrapError("create", id, c.createBundleContainer(id, bundle))
+}
+
+// Delete 删除容器并执行 poststop hooks，对应 OCI 生命周期中的 delete 操作
+func (c *Client) Delete(ref string, force bool) error {
+	return wrapError("delete", ref, withContainer(ref, func(id string) error {
+		return deleteContainer(id, force)
+	}))
+}
+
+// State 返回容器的 OCI state
+func (c *Client) State(ref string) (*specs.State, error) {
+	ociState, err := resolveContainer(ref, containerState)
+	return ociState, wrapError("state", ref, err)
+}
+
+// binaryPath 返回 mydocker 可执行文件的路径，没有指定时为 SelfExe
+func (c *Client) binaryPath() string {
+	if c.binary == "" {
+		return SelfExe
+	}
+	return c.binary
+}
+
+// resolveContainer 将 ref 解析为容器 id 后调用 fn
+func resolveContainer[T any](ref string, fn func(id string) (T, error)) (T, error) {
+	id, err := resolveContainerId(ref)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return fn(id)
+}
+
+// withContainer 将 ref 解析为容器 id 后调用 fn
+func withContainer(ref string, fn func(id string) error) error {
+	id, err := resolveContainerId(ref)
+	if err != nil {
+		return err
+	}
+	return fn(id)
+}
+
+// lookupContainer 读取容器信息，容器不存在时返回 ErrNotFound，init 进程已经不存在的容器会被标记为退出
+func lookupContainer(containerId string) (*container.Info, error) {
+	containerInfo, err := state.Load(containerId)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, notFound("no such container: %s", containerId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	containerInfo, _ = reconcileContainer(containerInfo)
+	return containerInfo, nil
+}
+
+// updateContainer 在容器的锁内修改容器信息，容器不存在时返回 ErrNotFound
+func updateContainer(containerId string, fn func(info *container.Info) error) (*container.Info, error) {
+	containerInfo, err := state.Update(containerId, fn)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, notFound("no such container: %s", containerId)
+	}
+	return containerInfo, err
+}
@@ -1,17 +1,21 @@
-package main
+package client
 
 import (
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 
 	"github.com/NatsuiroGinga/mydocker/utils"
 	"github.com/sirupsen/logrus"
 )
 
-var ErrImageAlreadyExists = errors.New("image already exists")
-
-func commitContainer(containerID string, imageName string) error {
+// commitContainer 将容器的 rootfs 打包为 /var/lib/mydocker/image/{imageName}.tar，
+// 指定了标签时保存到 /var/lib/mydocker/image/{imageName}.json
+func commitContainer(containerID string, imageName string, labels map[string]string) error {
+	if _, err := lookupContainer(containerID); err != nil {
+		return err
+	}
 	mntPath := utils.GetMerged(containerID)
 	if len(imageName) == 0 {
 		imageName = containerID
@@ -24,7 +28,7 @@ func commitContainer(containerID string, imageName string) error {
 	}
 
 	if exist {
-		return ErrImageAlreadyExists
+		return conflict("image %s already exists", imageName)
 	}
 
 	logrus.Infof("commitContainer imageTar:%s", imageTar)
@@ -33,5 +37,12 @@ func commitContainer(containerID string, imageName string) error {
 		return errors.Join(err, fmt.Errorf("tar folder %s failed", mntPath))
 	}
 
+	if len(labels) == 0 {
+		return nil
+	}
+	if err = saveImageConfig(imageName, &imageConfig{Labels: labels}); err != nil {
+		os.Remove(imageTar)
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+	"github.com/NatsuiroGinga/mydocker/state"
+	"github.com/sirupsen/logrus"
+)
+
+// createBundleContainer 根据 OCI bundle 创建容器，对应 OCI 生命周期中的 create 操作
+/*
+1）读取 bundle 中的 config.json，按照 linux.namespaces 启动 init 进程
+
+2）按照 linux.resources 设置 cgroup
+
+3）记录容器信息，状态为 created
+
+4）执行 prestart 和 createRuntime hooks
+
+完成后 init 进程阻塞在 exec fifo 上，直到执行 mydocker start
+*/
+func (c *Client) createBundleContainer(containerId, bundle string) (err error) {
+	if err = container.ValidateID(containerId); err != nil {
+		return &Error{Kind: ErrInvalidParameter, Err: err}
+	}
+	bundle, err = filepath.Abs(bundle)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("get abs path of bundle %s failed", bundle))
+	}
+
+	if state.Exists(containerId) {
+		return conflict("container %s already exists", containerId)
+	}
+	// bundle 创建的容器以容器 id 作为容器名，和 run 一样占用容器名，创建失败时释放
+	if _, err = reserveContainerName(containerId, containerId); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			container.ReleaseName(containerId, containerId)
+		}
+	}()
+
+	spec, err := container.LoadSpec(bundle)
+	if err != nil {
+		return err
+	}
+
+	cmd, syncPipe, err := container.NewBundleParentProcess(containerId, bundle, spec)
+	if err != nil {
+		state.Delete(containerId)
+		return err
+	}
+	defer syncPipe.Close()
+	cmd.Path = c.binaryPath() // init 进程由 mydocker 可执行文件启动
+	if err = startInitProcess(cmd); err != nil {
+		state.Delete(containerId)
+		return err
+	}
+
+	cgroupPath, res := container.SpecCgroupPath(spec, containerId), container.SpecResource(spec)
+	cgroupManager := cgroups.NewCgroupManager(cgroupPath)
+	if err = cgroupManager.Set(res); err != nil {
+		destroyBundleContainer(cmd.Process.Pid, containerId, cgroupManager)
+		return errors.Join(err, errors.New("set cgroup resources failed"))
+	}
+	if err = cgroupManager.Apply(cmd.Process.Pid); err != nil {
+		destroyBundleContainer(cmd.Process.Pid, containerId, cgroupManager)
+		return errors.Join(err, errors.New("apply cgroup failed"))
+	}
+
+	// cgroup 设置完成后通知 init 进程开始初始化，并等待初始化完成
+	if err = sendInitConfig(container.BundleInitConfig(bundle, spec), syncPipe); err == nil {
+		err = container.WaitInitReady(syncPipe)
+	}
+	if err != nil {
+		destroyBundleContainer(cmd.Process.Pid, containerId, cgroupManager)
+		return err
+	}
+
+	startTime, err := container.ProcessStartTime(cmd.Process.Pid)
+	if err != nil {
+		logrus.Warnf("read start time of process %d failed: %v", cmd.Process.Pid, err)
+	}
+	containerInfo := &container.Info{
+		Id:         containerId,
+		Pid:        strconv.Itoa(cmd.Process.Pid),
+		StartTime:  startTime,
+		Name:       containerId,
+		Command:    strings.Join(spec.Process.Args, " "),
+		Env:        spec.Process.Env,
+		Bundle:     bundle,
+		Labels:     spec.Annotations, // bundle 中的 annotations 作为容器的标签
+		Resources:  res,
+		CgroupPath: cgroupPath,
+	}
+	if err = state.Create(containerInfo); err != nil {
+		destroyBundleContainer(cmd.Process.Pid, containerId, cgroupManager)
+		return err
+	}
+
+	hooks := spec.Hooks
+	if hooks != nil {
+		ociState := container.BundleState(containerInfo, spec)
+		if err = container.RunHooks(append(hooks.Prestart, hooks.CreateRuntime...), ociState); err != nil {
+			destroyBundleContainer(cmd.Process.Pid, containerId, cgroupManager)
+			return err
+		}
+	}
+
+	logrus.Infof("container %s created, pid %d", containerId, cmd.Process.Pid)
+	logContainerEvent(containerInfo, events.Create, nil)
+	// 容器已经创建成功，init 进程交给 mydocker start、delete 管理，释放失败也不影响容器
+	cmd.Process.Release()
+	return nil
+}
+
+// startInitProcess 启动 init 进程，并关闭父进程中已经传递给子进程的 fd
+//
+// 父进程必须关闭自己持有的 sync socket 子进程端，否则 init 进程异常退出时父进程读不到 EOF
+func startInitProcess(cmd *exec.Cmd) error {
+	err := cmd.Start()
+	for _, f := range cmd.ExtraFiles {
+		f.Close()
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("start init process failed"))
+	}
+	return nil
+}
+
+// destroyBundleContainer create 失败时 kill 掉 init 进程并清理 cgroup 和容器信息
+func destroyBundleContainer(pid int, containerId string, cgroupManager cgroups.CgroupManager) {
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		logrus.Warnf("kill init process %d error %v", pid, err)
+	}
+	waitProcessExit(pid)
+	cgroupManager.Destroy()
+	if err := state.Delete(containerId); err != nil {
+		logrus.Warnf("delete container %s info error %v", containerId, err)
+	}
+}
+
+// waitProcessExit 等待进程退出，进程不一定是当前进程的子进程，因此通过轮询判断
+func waitProcessExit(pid int) {
+	for container.ProcessExists(pid) {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
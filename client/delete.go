@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+	"github.com/NatsuiroGinga/mydocker/state"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+)
+
+// deleteContainer 删除容器，对应 OCI 生命周期中的 delete 操作
+/*
+1）容器仍在运行时，只有指定了 force 才会先 SIGKILL 再删除
+
+2）销毁 cgroup，删除容器信息
+
+3）执行 poststop hooks，按照规范 poststop 失败只打印警告
+
+不是通过 bundle 创建的容器直接交给 removeContainer 处理
+*/
+func deleteContainer(containerId string, force bool) error {
+	containerInfo, err := lookupContainer(containerId)
+	if err != nil {
+		return err
+	}
+	if containerInfo.Bundle == "" {
+		return removeContainer(containerId, force)
+	}
+
+	spec, err := container.LoadSpec(containerInfo.Bundle)
+	if err != nil {
+		return err
+	}
+
+	ociState := container.BundleState(containerInfo, spec)
+	if ociState.Status != specs.StateStopped {
+		if !force {
+			return conflict("container %s is %s, stop it before deleting or use force", containerId, ociState.Status)
+		}
+		pid, _ := strconv.Atoi(containerInfo.Pid)
+		if err = syscall.Kill(pid, syscall.SIGKILL); err != nil {
+			return fmt.Errorf("kill container %s error %v", containerId, err)
+		}
+		waitProcessExit(pid)
+		ociState.Status = specs.StateStopped
+		ociState.Pid = 0
+	}
+
+	cgroups.NewCgroupManager(container.SpecCgroupPath(spec, containerId)).Destroy()
+	if err = state.Delete(containerId); err != nil {
+		return err
+	}
+	logContainerEvent(containerInfo, events.Destroy, nil)
+
+	if spec.Hooks != nil {
+		if err = container.RunHooks(spec.Hooks.Poststop, ociState); err != nil {
+			logrus.Warnf("run poststop hooks error %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 错误类型，通过 errors.Is(err, client.ErrNotFound) 判断
+var (
+	ErrNotFound         = errors.New("not found")         // 容器、网络、镜像等对象不存在
+	ErrConflict         = errors.New("conflict")          // 对象的状态不允许执行该操作，例如删除运行中的容器
+	ErrInvalidParameter = errors.New("invalid parameter") // 参数错误
+)
+
+// Error Client 的操作失败时返回的错误
+type Error struct {
+	Op   string // 失败的操作，例如 stop、network remove
+	Id   string // 操作的对象，容器 id、网络名或镜像名
+	Kind error  // ErrNotFound、ErrConflict、ErrInvalidParameter 之一，其它错误为 nil
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Op == "" {
+		return e.Err.Error()
+	}
+	if e.Id == "" {
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Id, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is 使 errors.Is 可以通过错误类型判断
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// notFound 对象不存在
+func notFound(format string, args ...any) error {
+	return &Error{Kind: ErrNotFound, Err: fmt.Errorf(format, args...)}
+}
+
+// conflict 对象的状态不允许执行该操作
+func conflict(format string, args ...any) error {
+	return &Error{Kind: ErrConflict, Err: fmt.Errorf(format, args...)}
+}
+
+// invalidParameter 参数错误
+func invalidParameter(format string, args ...any) error {
+	return &Error{Kind: ErrInvalidParameter, Err: fmt.Errorf(format, args...)}
+}
+
+// wrapError 在公开的方法返回前补充失败的操作和对象，内部函数返回的错误不需要关心这些
+func wrapError(op, id string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		return &Error{Op: op, Id: id, Err: err}
+	}
+	if e.Op != "" { // 已经补充过了，例如 Run 中调用的 Start
+		return err
+	}
+	if err == e { // 内部函数直接返回的 *Error，避免嵌套
+		err = e.Err
+	}
+	return &Error{Op: op, Id: id, Kind: e.Kind, Err: err}
+}
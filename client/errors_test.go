@@ -0,0 +1,51 @@
+package client
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWrapError(t *testing.T) {
+	if wrapError("stop", "abc", nil) != nil {
+		t.Fatal("expected nil for nil error")
+	}
+
+	err := wrapError("stop", "abc", conflict("container %s is not running", "abc"))
+	if !errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected kind of %v", err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Op != "stop" || e.Id != "abc" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if err.Error() != "stop abc: container abc is not running" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+
+	// 内部调用时已经补充过的操作不会被外层覆盖
+	if err = wrapError("remove", "abc", err); !errors.As(err, &e) || e.Op != "stop" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	plain := errors.New("boom")
+	err = wrapError("list", "", plain)
+	if !errors.Is(err, plain) || errors.Is(err, ErrNotFound) || err.Error() != "list: boom" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestLookupContainerNotFound(t *testing.T) {
+	if _, err := lookupContainer("no-such-container-for-test"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestWrapJoinedError(t *testing.T) {
+	err := wrapError("create", "busybox", errors.Join(notFound("no such network: %s", "br0"), errors.New("create container failed")))
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err.Error() != "create busybox: no such network: br0\ncreate container failed" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+)
+
+// errEventsEnd 读到了 until 之后的事件
+var errEventsEnd = errors.New("events end")
+
+// eventFilters 事件支持的过滤条件
+var eventFilters = filterFuncs[*events.Event]{
+	"type":  func(event *events.Event, value string) bool { return event.Type == value },
+	"event": func(event *events.Event, value string) bool { return event.Action == value },
+	// 网络事件中的容器记录在属性中
+	"container": func(event *events.Event, value string) bool {
+		if event.Type == events.Network {
+			return strings.HasPrefix(event.Attributes["container"], value)
+		}
+		return strings.HasPrefix(event.Id, value) || event.Attributes["name"] == value
+	},
+	"network": func(event *events.Event, value string) bool { return event.Type == events.Network && event.Id == value },
+	"image":   func(event *events.Event, value string) bool { return event.Attributes["image"] == value },
+}
+
+// eventTypes type 过滤条件可以使用的值
+var eventTypes = []string{events.Container, events.Network}
+
+// logContainerEvent 记录容器的事件，属性中带上容器名和镜像
+func logContainerEvent(info *container.Info, action string, attributes map[string]string) {
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+	attributes["name"] = info.Name
+	if info.Image != "" {
+		attributes["image"] = info.Image
+	}
+	events.Log(events.Container, action, info.Id, attributes)
+}
+
+// logDieEvent 记录容器进程退出的事件，属性中带上退出码
+func logDieEvent(info *container.Info) {
+	logContainerEvent(info, events.Die, map[string]string{
+		"exitCode":  strconv.Itoa(info.ExitCode),
+		"oomKilled": strconv.FormatBool(info.OOMKilled),
+	})
+}
+
+// watchEvents 按时间顺序对满足条件的事件调用 fn
+/*
+since、until 和 prune 的 until 过滤条件一样，可以是 10m 这样的时长，也可以是时间戳。
+
+没有指定 since 时不回放已经记录的事件，只输出开始监听之后发生的事件。
+
+没有指定 until 或者 until 还没有到时，读完已有的事件后继续等待新的事件，直到 ctx 结束或者到达 until
+*/
+func watchEvents(ctx context.Context, opts *EventsOptions, fn func(event *events.Event) error) error {
+	if opts == nil {
+		opts = &EventsOptions{}
+	}
+	since, until, err := opts.timeRange()
+	if err != nil {
+		return err
+	}
+
+	follow := until.IsZero() || until.After(time.Now())
+	if follow && !until.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, until)
+		defer cancel()
+	}
+	err = events.Watch(ctx, follow, since.IsZero(), func(event *events.Event) error {
+		if !until.IsZero() && event.Time.After(until) {
+			return errEventsEnd
+		}
+		if event.Time.Before(since) || !matchFilters(event, opts.Filters, eventFilters) {
+			return nil
+		}
+		return fn(event)
+	})
+	if errors.Is(err, errEventsEnd) {
+		return nil
+	}
+	return err
+}
+
+// Validate 检查过滤条件以及 since、until 的格式
+func (opts *EventsOptions) Validate() error {
+	_, _, err := opts.timeRange()
+	return err
+}
+
+// timeRange 检查过滤条件并解析 since、until，没有指定时为零值
+func (opts *EventsOptions) timeRange() (since, until time.Time, err error) {
+	if err = validateEventFilters(opts.Filters); err != nil {
+		return
+	}
+	if opts.Since != "" {
+		if since, err = parseUntil(opts.Since); err != nil {
+			err = invalidParameter("invalid since %s, must be a duration like 10m or a timestamp", opts.Since)
+			return
+		}
+	}
+	if opts.Until != "" {
+		if until, err = parseUntil(opts.Until); err != nil {
+			err = invalidParameter("invalid until %s, must be a duration like 10m or a timestamp", opts.Until)
+			return
+		}
+	}
+	return
+}
+
+// validateEventFilters 检查事件过滤条件的 key 和 type 的值是否合法
+func validateEventFilters(filters Filters) error {
+	if err := validateFilters(filters, eventFilters); err != nil {
+		return err
+	}
+	for _, value := range filters["type"] {
+		if !slices.Contains(eventTypes, value) {
+			return invalidParameter("invalid filter type=%s, must be one of %s", value, strings.Join(eventTypes, ", "))
+		}
+	}
+	return nil
+}
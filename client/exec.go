@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+	log "github.com/sirupsen/logrus"
+)
+
+// nsenter里的C代码里已经出现mydocker_pid和mydocker_cmd这两个Key,主要是为了控制是否执行C代码里面的setns.
+const (
+	EnvExecPid = "mydocker_pid"
+	EnvExecCmd = "mydocker_cmd"
+)
+
+// execAttached 获取容器进程ID并设置环境变量，然后fork新进程并启动
+//
+// 1. 首先是通过ContainerId 找到进程 PID
+//
+// 2. 然后则是通过 exec 简单 fork 出了一个进程，并把这个进程的标准输入输出绑定到指定的 stdin、stdout、stderr 上。
+//
+// 3. 返回命令的退出码
+func (c *Client) execAttached(containerId string, comArray []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	cmd, err := c.newExecCommand(containerId, comArray)
+	if err != nil {
+		return 0, err
+	}
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return 0, errors.Join(err, fmt.Errorf("exec container %s failed", containerId))
+	}
+	return cmd.ProcessState.ExitCode(), nil
+}
+
+// execOutput 在容器中执行命令，等待命令结束后返回退出码和输出
+func (c *Client) execOutput(containerId string, comArray []string) (*ExecResult, error) {
+	var output bytes.Buffer
+	exitCode, err := c.execAttached(containerId, comArray, nil, &output, &output)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecResult{ExitCode: exitCode, Output: output.String()}, nil
+}
+
+// newExecCommand 创建进入容器 namespace 执行命令的进程
+/*
+通过环境变量把容器 PID 和命令传递给新进程，nsenter 的 C 代码在 Go 运行时启动前读取它们并执行 setns。
+同时把容器进程的环境变量传递给新进程，实现通过exec命令也能查询到容器的环境变量。
+*/
+func (c *Client) newExecCommand(containerId string, comArray []string) (*exec.Cmd, error) {
+	// 根据传进来的容器名获取对应的PID
+	containerInfo, err := lookupContainer(containerId)
+	if err != nil {
+		return nil, err
+	}
+	// 和 docker 一样，暂停的容器不能 exec
+	if containerInfo.Status == container.PAUSED {
+		return nil, conflict("container %s is paused, unpause the container before exec", containerId)
+	}
+	pid := containerInfo.Pid
+	if pid == "" {
+		return nil, conflict("container %s is not running", containerId)
+	}
+
+	cmdStr := strings.Join(comArray, " ")
+	log.Infof("container pid：%s command：%s", pid, cmdStr)
+
+	cmd := exec.Command(c.binaryPath(), "exec")
+	cmd.Env = append(os.Environ(), EnvExecPid+"="+pid, EnvExecCmd+"="+cmdStr)
+	cmd.Env = append(cmd.Env, getEnvsByPid(pid)...)
+	return cmd, nil
+}
+
+// getEnvsByPid 读取指定PID进程的环境变量
+func getEnvsByPid(pid string) []string {
+	path := fmt.Sprintf("/proc/%s/environ", pid)
+	contentBytes, err := os.ReadFile(path)
+	if err != nil {
+		log.Errorf("Read file %s error %v", path, err)
+		return nil
+	}
+	// env split by \u0000
+	envs := strings.Split(string(contentBytes), "\u0000")
+	return envs
+}
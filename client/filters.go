@@ -0,0 +1,88 @@
+package client
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+)
+
+// Filters 过滤条件，key 为过滤的字段，例如 status、name
+//
+// 同一个 key 的多个值满足其中一个即可，不同的 key 需要同时满足
+type Filters map[string][]string
+
+// ParseFilters 解析 --filter 参数，每个参数的格式为 key=value，例如 status=exited
+func ParseFilters(args []string) (Filters, error) {
+	filters := Filters{}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, invalidParameter("invalid filter %q, must be key=value", arg)
+		}
+		filters.Add(key, value)
+	}
+	return filters, nil
+}
+
+// Add 增加一个过滤条件
+func (f Filters) Add(key, value string) {
+	f[key] = append(f[key], value)
+}
+
+// filterFuncs 各个过滤条件的判断函数，返回对象是否满足 value
+type filterFuncs[T any] map[string]func(item T, value string) bool
+
+// containerFilters 容器支持的过滤条件
+var containerFilters = filterFuncs[*container.Info]{
+	"id":       func(info *container.Info, value string) bool { return strings.HasPrefix(info.Id, value) },
+	"name":     func(info *container.Info, value string) bool { return strings.Contains(info.Name, value) },
+	"status":   func(info *container.Info, value string) bool { return info.Status == value },
+	"network":  func(info *container.Info, value string) bool { return info.NetworkName == value },
+	"ancestor": func(info *container.Info, value string) bool { return info.Image == value },
+	"label":    func(info *container.Info, value string) bool { return matchLabel(info.Labels, value) },
+}
+
+// networkFilters 网络支持的过滤条件
+var networkFilters = filterFuncs[*Network]{
+	"name":   func(nw *Network, value string) bool { return strings.Contains(nw.Name, value) },
+	"driver": func(nw *Network, value string) bool { return nw.Driver == value },
+	"label":  func(nw *Network, value string) bool { return matchLabel(nw.Labels, value) },
+}
+
+// containerStatuses status 过滤条件可以使用的值
+var containerStatuses = []string{container.CREATED, container.RUNNING, container.PAUSED, container.RESTARTING, container.STOP, container.Exit}
+
+// validateFilters 检查过滤条件的 key 是否支持
+func validateFilters[T any](filters Filters, funcs filterFuncs[T]) error {
+	for key := range filters {
+		if _, ok := funcs[key]; !ok {
+			return invalidParameter("invalid filter %q", key)
+		}
+	}
+	return nil
+}
+
+// validateContainerFilters 检查容器过滤条件的 key 和 status 的值是否合法
+func validateContainerFilters(filters Filters) error {
+	if err := validateFilters(filters, containerFilters); err != nil {
+		return err
+	}
+	for _, value := range filters["status"] {
+		if !slices.Contains(containerStatuses, value) {
+			return invalidParameter("invalid filter status=%s, must be one of %s", value, strings.Join(containerStatuses, ", "))
+		}
+	}
+	return nil
+}
+
+// matchFilters 返回对象是否满足全部过滤条件
+func matchFilters[T any](item T, filters Filters, funcs filterFuncs[T]) bool {
+	for key, values := range filters {
+		match := funcs[key]
+		if !slices.ContainsFunc(values, func(value string) bool { return match(item, value) }) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+)
+
+func TestParseFilters(t *testing.T) {
+	filters, err := ParseFilters([]string{"status=exited", "status=created", "name=web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filters["status"]) != 2 || filters["name"][0] != "web" {
+		t.Fatalf("unexpected filters %v", filters)
+	}
+	if _, err = ParseFilters([]string{"exited"}); !errors.Is(err, ErrInvalidParameter) {
+		t.Fatalf("expected invalid parameter, got %v", err)
+	}
+}
+
+func TestValidateContainerFilters(t *testing.T) {
+	if err := validateContainerFilters(Filters{"status": {"exited"}, "ancestor": {"busybox"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, filters := range []Filters{{"color": {"red"}}, {"status": {"dead"}}} {
+		if err := validateContainerFilters(filters); !errors.Is(err, ErrInvalidParameter) {
+			t.Fatalf("expected invalid parameter for %v, got %v", filters, err)
+		}
+	}
+}
+
+func TestMatchContainer(t *testing.T) {
+	info := &container.Info{Id: "1234567890", Name: "web-1", Status: container.Exit, NetworkName: "testbr", Image: "busybox",
+		Labels: map[string]string{"team": "infra"}}
+	tests := []struct {
+		filters Filters
+		match   bool
+	}{
+		{nil, true},
+		{Filters{"status": {container.RUNNING, container.Exit}}, true},
+		{Filters{"status": {container.RUNNING}}, false},
+		{Filters{"name": {"web"}, "id": {"1234"}}, true},
+		{Filters{"name": {"web"}, "network": {"other"}}, false},
+		{Filters{"ancestor": {"busybox"}}, true},
+		{Filters{"label": {"team"}}, true},
+		{Filters{"label": {"team=infra"}}, true},
+		{Filters{"label": {"team=web"}}, false},
+		{Filters{"label": {"job"}}, false},
+	}
+	for _, test := range tests {
+		if got := matchFilters(info, test.filters, containerFilters); got != test.match {
+			t.Errorf("matchFilters(%v) = %v, want %v", test.filters, got, test.match)
+		}
+	}
+}
+
+func TestMatchEvent(t *testing.T) {
+	die := &events.Event{Type: events.Container, Action: events.Die, Id: "1234567890",
+		Attributes: map[string]string{"name": "web-1", "image": "busybox", "exitCode": "137"}}
+	connect := &events.Event{Type: events.Network, Action: events.Connect, Id: "testbr",
+		Attributes: map[string]string{"container": "1234567890"}}
+	tests := []struct {
+		event   *events.Event
+		filters Filters
+		match   bool
+	}{
+		{die, Filters{"type": {events.Container}, "event": {events.Die, events.OOM}}, true},
+		{die, Filters{"event": {events.Start}}, false},
+		{die, Filters{"container": {"web-1"}}, true},
+		{die, Filters{"container": {"1234"}, "image": {"busybox"}}, true},
+		{die, Filters{"network": {"testbr"}}, false},
+		{connect, Filters{"container": {"1234"}, "network": {"testbr"}}, true},
+		{connect, Filters{"type": {events.Container}}, false},
+	}
+	for _, test := range tests {
+		if got := matchFilters(test.event, test.filters, eventFilters); got != test.match {
+			t.Errorf("matchFilters(%+v, %v) = %v, want %v", test.event, test.filters, got, test.match)
+		}
+	}
+	if err := validateEventFilters(Filters{"type": {"volume"}}); !errors.Is(err, ErrInvalidParameter) {
+		t.Fatalf("expected invalid parameter, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/constant"
+	"github.com/NatsuiroGinga/mydocker/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const imageExt = ".tar" // 镜像以 tar 包的形式保存在 utils.ImagePath 下
+
+// listImages 遍历镜像目录，返回全部镜像，镜像名为去掉 .tar 后缀的文件名
+func listImages() ([]*Image, error) {
+	entries, err := os.ReadDir(utils.ImagePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("read dir %s failed", utils.ImagePath))
+	}
+
+	images := make([]*Image, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), imageExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		images = append(images, newImage(strings.TrimSuffix(entry.Name(), imageExt), info))
+	}
+	return images, nil
+}
+
+// inspectImage 返回镜像信息，镜像不存在时返回 ErrNotFound
+func inspectImage(name string) (*Image, error) {
+	info, err := os.Stat(utils.GetImage(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, notFound("no such image: %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newImage(name, info), nil
+}
+
+func newImage(name string, info fs.FileInfo) *Image {
+	image := &Image{
+		Name:    name,
+		Path:    utils.GetImage(name),
+		Size:    info.Size(),
+		Created: info.ModTime().Format(time.DateTime),
+	}
+	config, err := loadImageConfig(name)
+	if err != nil {
+		logrus.Warnf("load config of image %s failed: %v", name, err)
+	} else {
+		image.Labels = config.Labels
+	}
+	return image
+}
+
+// imageConfig 镜像 tar 包之外的元数据，不存在元数据文件的镜像各项均为空
+type imageConfig struct {
+	Labels map[string]string `json:"labels"`
+}
+
+func saveImageConfig(name string, config *imageConfig) error {
+	content, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	file := utils.GetImageConfig(name)
+	if err = os.WriteFile(file, content, constant.Perm0644); err != nil {
+		return errors.Join(err, fmt.Errorf("write image config %s failed", file))
+	}
+	return nil
+}
+
+func loadImageConfig(name string) (*imageConfig, error) {
+	config := new(imageConfig)
+	content, err := os.ReadFile(utils.GetImageConfig(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return config, json.Unmarshal(content, config)
+}
+
+// removeImage 删除镜像的 tar 包和元数据
+func removeImage(name string) error {
+	if err := os.Remove(utils.GetImage(name)); err != nil {
+		return errors.Join(err, fmt.Errorf("remove image %s failed", name))
+	}
+	if err := os.Remove(utils.GetImageConfig(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Join(err, fmt.Errorf("remove config of image %s failed", name))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// inspectContainer 读取容器信息，并补充 cgroup 在宿主机上的绝对路径和容器的挂载点
+func inspectContainer(containerId string) (*ContainerDetail, error) {
+	info, err := lookupContainer(containerId)
+	if err != nil {
+		return nil, err
+	}
+	detail := &ContainerDetail{Info: info, Mounts: containerMounts(info)}
+	if info.CgroupPath != "" {
+		detail.CgroupPaths = cgroups.CgroupPaths(info.CgroupPath)
+	}
+	return detail, nil
+}
+
+// containerMounts 返回容器的挂载点
+/*
+通过镜像创建的容器，rootfs 是 overlay 的 merged 目录，数据卷通过 bind mount 挂载；
+通过 OCI bundle 创建的容器，rootfs 和挂载点都来自 bundle 的 config.json。
+*/
+func containerMounts(info *container.Info) []*Mount {
+	if info.Bundle != "" {
+		spec, err := container.LoadSpec(info.Bundle)
+		if err != nil {
+			logrus.Warnf("load spec of bundle %s failed: %v", info.Bundle, err)
+			return nil
+		}
+		mounts := []*Mount{{Type: "bind", Source: container.SpecRootfs(info.Bundle, spec), Destination: "/"}}
+		for _, m := range spec.Mounts {
+			mounts = append(mounts, &Mount{Type: m.Type, Source: m.Source, Destination: m.Destination})
+		}
+		return mounts
+	}
+
+	mounts := []*Mount{{Type: "overlay", Source: utils.GetMerged(info.Id), Destination: "/"}}
+	if source, destination, ok := splitVolume(info.Volume); ok {
+		mounts = append(mounts, &Mount{Type: "bind", Source: source, Destination: destination})
+	}
+	return mounts
+}
+
+// splitVolume 将 -v 指定的 /host:/container 拆分为宿主机目录和容器中的目录，格式和 container 包中挂载数据卷时相同
+func splitVolume(volume string) (source, destination string, ok bool) {
+	parts := strings.Split(volume, ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
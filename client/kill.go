@@ -0,0 +1,56 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+	"golang.org/x/sys/unix"
+)
+
+// killContainer 向容器的 init 进程发送信号，不修改容器状态
+func killContainer(containerId, signal string) error {
+	sig, err := parseSignal(signal)
+	if err != nil {
+		return &Error{Kind: ErrInvalidParameter, Err: err}
+	}
+
+	containerInfo, err := lookupContainer(containerId)
+	if err != nil {
+		return err
+	}
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil || !container.ProcessAlive(pid, containerInfo.StartTime) {
+		return conflict("container %s is not running", containerId)
+	}
+
+	if err = syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("kill container %s with %s error %v", containerId, unix.SignalName(sig), err)
+	}
+	return nil
+}
+
+// parseSignal 解析信号，支持 9、KILL、SIGKILL 三种写法，为空时默认为 SIGTERM
+func parseSignal(signal string) (syscall.Signal, error) {
+	if signal == "" {
+		return syscall.SIGTERM, nil
+	}
+	if num, err := strconv.Atoi(signal); err == nil {
+		if num <= 0 || num > 64 {
+			return 0, fmt.Errorf("invalid signal %s", signal)
+		}
+		return syscall.Signal(num), nil
+	}
+
+	name := strings.ToUpper(signal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return 0, fmt.Errorf("invalid signal %s", signal)
+	}
+	return sig, nil
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseLabels 解析 --label-file 指定的文件和 --label 参数，--label 中的同名标签会覆盖文件中的
+/*
+标签的格式为 key=value，只有 key 时 value 为空字符串。
+标签文件中每行一个标签，忽略空行和以 # 开头的注释行。
+*/
+func ParseLabels(labels []string, labelFiles []string) (map[string]string, error) {
+	var all []string
+	for _, file := range labelFiles {
+		lines, err := readLabelFile(file)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, lines...)
+	}
+	all = append(all, labels...)
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]string, len(all))
+	for _, label := range all {
+		key, value, _ := strings.Cut(label, "=")
+		if key == "" {
+			return nil, invalidParameter("invalid label %q, must be key=value", label)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// readLabelFile 读取标签文件中的每一行，忽略空行和注释
+func readLabelFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("open label file %s failed", file))
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Join(err, fmt.Errorf("read label file %s failed", file))
+	}
+	return lines, nil
+}
+
+// matchLabel 判断是否存在 label 过滤条件指定的标签，条件为 key 时只要求存在该标签，为 key=value 时还要求值相等
+func matchLabel(labels map[string]string, filter string) bool {
+	key, value, hasValue := strings.Cut(filter, "=")
+	actual, ok := labels[key]
+	return ok && (!hasValue || actual == value)
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "labels")
+	content := "# comment\nteam=infra\n\nenv=staging\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	labels, err := ParseLabels([]string{"env=prod", "canary"}, []string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"team": "infra", "env": "prod", "canary": ""}
+	if !maps.Equal(labels, expected) {
+		t.Fatalf("expected %v, got %v", expected, labels)
+	}
+
+	if labels, err = ParseLabels(nil, nil); err != nil || labels != nil {
+		t.Fatalf("expected no labels, got %v, %v", labels, err)
+	}
+	if _, err = ParseLabels([]string{"=value"}, nil); !errors.Is(err, ErrInvalidParameter) {
+		t.Fatalf("expected invalid parameter, got %v", err)
+	}
+	if _, err = ParseLabels(nil, []string{filepath.Join(t.TempDir(), "none")}); err == nil {
+		t.Fatal("expected error for missing label file")
+	}
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/state"
+)
+
+// listContainers 遍历容器信息，按照创建时间从新到旧返回满足条件的容器
+/*
+1. 首先通过 state.List 读取存放在/var/lib/mydocker/containers/目录下的全部容器信息
+
+2. 然后将 init 进程已经不存在的容器标记为退出，再按照创建时间排序
+
+3. 最后根据 opts 过滤，没有指定 All 和 Last 时只返回没有停止的容器，指定了 Last 时只保留最近创建的 Last 个容器
+*/
+func listContainers(opts *ListOptions) ([]*container.Info, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	if err := validateContainerFilters(opts.Filters); err != nil {
+		return nil, err
+	}
+
+	containers, err := state.List()
+	if err != nil {
+		return nil, err
+	}
+	for i, info := range containers {
+		containers[i], _ = reconcileContainer(info)
+	}
+
+	// CreatedTime 的格式为 2006-01-02 15:04:05，可以直接按字符串比较
+	slices.SortStableFunc(containers, func(a, b *container.Info) int {
+		return cmp.Or(cmp.Compare(b.CreatedTime, a.CreatedTime), cmp.Compare(a.Id, b.Id))
+	})
+	all := opts.All || opts.Last > 0
+	containers = slices.DeleteFunc(containers, func(info *container.Info) bool {
+		if !all && (info.Status == container.STOP || info.Status == container.Exit) {
+			return true
+		}
+		return !matchFilters(info, opts.Filters, containerFilters)
+	})
+	if opts.Last > 0 && len(containers) > opts.Last {
+		containers = containers[:opts.Last]
+	}
+	return containers, nil
+}
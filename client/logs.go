@@ -0,0 +1,27 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+)
+
+// openContainerLog 打开容器的日志文件，只有后台运行的容器才有日志文件
+func openContainerLog(containerId string) (io.ReadCloser, error) {
+	if _, err := lookupContainer(containerId); err != nil {
+		return nil, err
+	}
+	logFileLocation := containerLogPath(containerId)
+	file, err := os.Open(logFileLocation)
+	if err != nil {
+		return nil, fmt.Errorf("log container open file %s error %w", logFileLocation, err)
+	}
+	return file, nil
+}
+
+// containerLogPath 容器日志文件的路径
+func containerLogPath(containerId string) string {
+	return fmt.Sprintf(container.InfoLocFormat, containerId) + container.GetLogfile(containerId)
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"errors"
+	"net"
+
+	"github.com/NatsuiroGinga/mydocker/network"
+)
+
+// createNetwork 检查参数后创建网络
+func createNetwork(config *NetworkConfig) error {
+	if config.Name == "" {
+		return invalidParameter("missing network name")
+	}
+	if _, _, err := net.ParseCIDR(config.Subnet); err != nil {
+		return invalidParameter("invalid subnet %s", config.Subnet)
+	}
+	return networkError(network.CreateNetwork(config.Driver, config.Subnet, config.Name, config.Labels))
+}
+
+// listNetworks 返回满足过滤条件的网络
+func listNetworks(filters Filters) ([]*Network, error) {
+	if err := validateFilters(filters, networkFilters); err != nil {
+		return nil, err
+	}
+	networks, err := network.ListNetwork()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Network, 0, len(networks))
+	for _, nw := range networks {
+		if item := newNetwork(nw); matchFilters(item, filters, networkFilters) {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+func inspectNetwork(name string) (*Network, error) {
+	nw, err := network.GetNetwork(name)
+	if err != nil {
+		return nil, networkError(err)
+	}
+	return newNetwork(nw), nil
+}
+
+func removeNetwork(name string) error {
+	return networkError(network.DeleteNetwork(name))
+}
+
+func newNetwork(nw *network.Network) *Network {
+	return &Network{Name: nw.Name, Driver: nw.Driver, Subnet: nw.IPRange.String(), Labels: nw.Labels, Created: nw.Created}
+}
+
+// networkError 将 network 包的错误转换为对应类型的 Error
+func networkError(err error) error {
+	switch {
+	case errors.Is(err, network.ErrNetworkNotFound):
+		return &Error{Kind: ErrNotFound, Err: err}
+	case errors.Is(err, network.ErrNetworkAlreadyExists):
+		return &Error{Kind: ErrConflict, Err: err}
+	default:
+		return err
+	}
+}
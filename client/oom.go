@@ -0,0 +1,87 @@
+package client
+
+import (
+	"errors"
+	"io/fs"
+	"strconv"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+	"github.com/NatsuiroGinga/mydocker/state"
+	"github.com/sirupsen/logrus"
+)
+
+// oomRecheckDelay 收到 OOM 通知后再次读取 OOM 计数的间隔
+const oomRecheckDelay = 100 * time.Millisecond
+
+// errOOMUnchanged OOM 计数和容器信息中记录的一样，不需要写回
+var errOOMUnchanged = errors.New("oom events unchanged")
+
+// watchOOM 在容器运行期间监听 cgroup 中的 OOM 事件并记录到容器信息中，返回停止监听的函数
+/*
+OOM killer 杀死的是 cgroup 中占用内存最多的进程，不一定是 init 进程，容器可能继续运行，
+因此不能只在容器退出后检查，而是在运行期间就记录下来，便于通过 mydocker inspect 查看。
+
+停止监听的函数等到正在进行的记录完成后才返回，之后容器信息不会再被修改
+*/
+func watchOOM(containerId string, cgroupManager cgroups.CgroupManager) func() {
+	done := make(chan struct{})
+	notify, err := cgroupManager.NotifyOOM(done)
+	if err != nil {
+		logrus.Warnf("watch oom events of container %s failed: %v", containerId, err)
+		return func() {}
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		var recheck <-chan time.Time
+		for {
+			select {
+			case _, ok := <-notify:
+				if !ok {
+					return
+				}
+				// 通知在 OOM killer 杀死进程之前就发出了，此时 oom_kill 计数可能还没有增加，稍后再读取一次
+				recheck = time.After(oomRecheckDelay)
+			case <-recheck:
+				recheck = nil
+			}
+			oomEvents, err := cgroupManager.OOMEvents()
+			if err != nil {
+				logrus.Warnf("read oom events of container %s failed: %v", containerId, err)
+				continue
+			}
+			recordOOMEvents(containerId, oomEvents)
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// recordOOMEvents 计数增加时写入容器信息并记录 oom 事件
+func recordOOMEvents(containerId string, oomEvents *resource.OOMEvents) {
+	info, err := state.Update(containerId, func(info *container.Info) error {
+		if info.OOMCount == oomEvents.OOM && info.OOMKillCount == oomEvents.OOMKill {
+			return errOOMUnchanged
+		}
+		info.OOMCount = oomEvents.OOM
+		info.OOMKillCount = oomEvents.OOMKill
+		return nil
+	})
+	switch {
+	case err == nil:
+		logrus.Warnf("container %s is out of memory, oom count %d, processes killed by oom killer %d",
+			containerId, oomEvents.OOM, oomEvents.OOMKill)
+		logContainerEvent(info, events.OOM, map[string]string{
+			"oomCount":     strconv.FormatUint(oomEvents.OOM, 10),
+			"oomKillCount": strconv.FormatUint(oomEvents.OOMKill, 10),
+		})
+	case !errors.Is(err, errOOMUnchanged) && !errors.Is(err, fs.ErrNotExist):
+		logrus.Errorf("save container %s info failed: %v", containerId, err)
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/state"
+)
+
+// pauseContainer 通过 cgroup freezer 冻结容器中的全部进程，并将容器状态修改为 paused
+/*
+冻结的是整个 cgroup，多个容器共用同一个 cgroup 时会把其他容器一起冻结，这种情况直接拒绝。
+
+检查状态、冻结和修改状态在同一把锁内完成，避免和 stop、unpause 交叉执行
+*/
+func pauseContainer(containerId string) error {
+	if err := checkCgroupExclusive(containerId, "pause"); err != nil {
+		return err
+	}
+	_, err := updateContainer(containerId, func(info *container.Info) error {
+		if info.Status == container.PAUSED {
+			return conflict("container %s is already paused", containerId)
+		}
+		if info.Status != container.RUNNING {
+			return conflict("container %s is not running", containerId)
+		}
+		if info.CgroupPath == "" {
+			return conflict("container %s has no cgroup", containerId)
+		}
+		if err := cgroups.NewManager(info.CgroupDriver, info.CgroupPath).Freeze(); err != nil {
+			return err
+		}
+		info.Status = container.PAUSED
+		return nil
+	})
+	return err
+}
+
+// unpauseContainer 恢复被冻结的容器，并将容器状态修改为 running
+func unpauseContainer(containerId string) error {
+	_, err := updateContainer(containerId, func(info *container.Info) error {
+		if info.Status != container.PAUSED {
+			return conflict("container %s is not paused", containerId)
+		}
+		if err := cgroups.NewManager(info.CgroupDriver, info.CgroupPath).Thaw(); err != nil {
+			return err
+		}
+		info.Status = container.RUNNING
+		return nil
+	})
+	return err
+}
+
+// checkCgroupExclusive 检查没有其他活跃的容器和 containerId 共用同一个 cgroup，action 为要执行的操作，用于错误信息
+func checkCgroupExclusive(containerId, action string) error {
+	containerInfo, err := lookupContainer(containerId)
+	if err != nil {
+		return err
+	}
+	if containerInfo.CgroupPath == "" {
+		return nil
+	}
+	containers, err := state.List()
+	if err != nil {
+		return err
+	}
+	for _, info := range containers {
+		if info.Id != containerId && info.CgroupPath == containerInfo.CgroupPath && activeContainer(info) {
+			return conflict("container %s shares cgroup %s with container %s, can not %s it alone",
+				containerId, containerInfo.CgroupPath, info.Id, action)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/network"
+	"github.com/NatsuiroGinga/mydocker/state"
+	"github.com/NatsuiroGinga/mydocker/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// gcGracePeriod 正在创建的容器从创建 overlay 目录到写入容器信息之间的时间不会超过这个值
+const gcGracePeriod = time.Minute
+
+// errNotStale 加锁后发现容器已经被 shim 等进程更新过了
+var errNotStale = errors.New("container is not stale")
+
+// reconcile 修正进程已经不存在的容器的状态，gc 为 true 时还会清理遗留的资源
+/*
+1）容器记录的 init 进程已经不存在，或者 pid 被其它进程复用了，将容器标记为退出，
+例如宿主机重启之后、或者 shim 进程被杀死时容器会一直停留在 running 状态。
+等待重启的容器则检查负责重启它的 shim 等进程，该进程不存在时同样标记为退出
+
+2）gc 为 true 时依次清理：
+没有正常断开网络的容器的 veth 和端口映射规则、
+不属于任何容器的 veth、
+没有容器信息的 overlay 目录、
+已经退出的容器遗留的 cgroup
+*/
+func reconcile(gc bool) (*ReconcileReport, error) {
+	containers, err := state.List()
+	if err != nil {
+		return nil, err
+	}
+	report := &ReconcileReport{}
+	for i, info := range containers {
+		var changed bool
+		if containers[i], changed = reconcileContainer(info); changed {
+			report.Containers = append(report.Containers, info.Id)
+		}
+	}
+	if !gc {
+		return report, nil
+	}
+
+	var active []*container.Info
+	for _, info := range containers {
+		if activeContainer(info) {
+			active = append(active, info)
+		}
+	}
+	report.Networks = gcNetworks(containers)
+	if report.Endpoints, err = network.RemoveStaleEndpoints(active); err != nil {
+		logrus.Warnf("remove stale endpoints failed: %v", err)
+	}
+	report.Workspaces = gcWorkspaces(containers)
+	report.Cgroups = gcCgroups(containers)
+	return report, nil
+}
+
+// reconcileContainer 容器的 init 进程已经不存在时将容器标记为退出，返回最新的容器信息以及是否修改过
+//
+// 读取和加锁之间 shim 可能已经记录了真正的退出码，因此在锁内再检查一次
+func reconcileContainer(info *container.Info) (*container.Info, bool) {
+	if !staleContainer(info) {
+		return info, false
+	}
+	var restarting bool
+	updated, err := state.Update(info.Id, func(latest *container.Info) error {
+		if !staleContainer(latest) {
+			return errNotStale
+		}
+		restarting = latest.Status == container.RESTARTING
+		if restarting {
+			logrus.Debugf("supervisor %d of container %s is gone, mark it as exited", latest.SupervisorPid, latest.Id)
+		} else {
+			logrus.Debugf("process %s of container %s is gone, mark it as exited", latest.Pid, latest.Id)
+		}
+		latest.Status = container.Exit
+		latest.Pid = ""
+		latest.StartTime = 0
+		// 等待重启的容器已经记录了上一次退出时的退出码和退出时间，也已经记录过 die 事件
+		if restarting {
+			return nil
+		}
+		latest.ExitCode = container.ExitCodeUnknown
+		latest.FinishedAt = time.Now().Format(time.DateTime)
+		return nil
+	})
+	if err == nil {
+		if !restarting {
+			logDieEvent(updated)
+		}
+		return updated, true
+	}
+	if !errors.Is(err, errNotStale) {
+		logrus.Warnf("reconcile container %s failed: %v", info.Id, err)
+		return info, false
+	}
+	if latest, err := state.Load(info.Id); err == nil {
+		return latest, false
+	}
+	return info, false
+}
+
+// staleContainer 容器处于 created、running、paused 状态但 init 进程已经不存在，
+// 或者处于 restarting 状态但负责重启它的进程已经不存在
+//
+// 旧版本创建的容器没有记录负责重启的进程，restarting 状态时无法判断
+func staleContainer(info *container.Info) bool {
+	switch info.Status {
+	case container.CREATED, container.RUNNING, container.PAUSED:
+	case container.RESTARTING:
+		return info.SupervisorPid != 0 && !container.ProcessAlive(info.SupervisorPid, info.SupervisorStartTime)
+	default:
+		return false
+	}
+	pid, err := strconv.Atoi(info.Pid)
+	if err != nil {
+		return true
+	}
+	return !container.ProcessAlive(pid, info.StartTime)
+}
+
+// activeContainer 容器仍然占用着进程、网络和 cgroup 等资源
+func activeContainer(info *container.Info) bool {
+	switch info.Status {
+	case container.CREATED, container.RUNNING, container.PAUSED, container.RESTARTING:
+		return true
+	default:
+		return false
+	}
+}
+
+// gcNetworks 容器正常退出时 shim 会断开网络并清空 IP，没有清空的说明 shim 没能完成清理
+func gcNetworks(containers []*container.Info) []string {
+	var cleaned []string
+	for _, info := range containers {
+		if activeContainer(info) || info.IP == "" || info.NetworkName == "" {
+			continue
+		}
+		if err := network.Disconnect(info.NetworkName, info); err != nil {
+			logrus.Warnf("disconnect container %s from network %s failed: %v", info.Id, info.NetworkName, err)
+		}
+		_, err := state.Update(info.Id, func(latest *container.Info) error {
+			if activeContainer(latest) {
+				return errNotStale
+			}
+			latest.IP = ""
+			return nil
+		})
+		if err == nil {
+			cleaned = append(cleaned, info.Id)
+		}
+	}
+	return cleaned
+}
+
+// gcWorkspaces 删除没有容器信息的 overlay 目录，正在创建的容器还没有写入容器信息，因此跳过最近修改过的目录
+func gcWorkspaces(containers []*container.Info) []string {
+	entries, err := os.ReadDir(utils.RootPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logrus.Warnf("read dir %s failed: %v", utils.RootPath, err)
+		}
+		return nil
+	}
+	known := make(map[string]bool, len(containers))
+	for _, info := range containers {
+		known[info.Id] = true
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		id := entry.Name()
+		if !entry.IsDir() || known[id] || state.Exists(id) {
+			continue
+		}
+		if fi, err := entry.Info(); err != nil || time.Since(fi.ModTime()) < gcGracePeriod {
+			continue
+		}
+		if err = container.RemoveWorkSpace(id); err != nil {
+			logrus.Warnf("remove workspace of container %s failed: %v", id, err)
+			continue
+		}
+		removed = append(removed, id)
+	}
+	return removed
+}
+
+// gcCgroups 删除已经退出的容器的 cgroup，仍被其它容器使用的 cgroup 不会删除
+func gcCgroups(containers []*container.Info) []string {
+	inUse := map[string]bool{}
+	candidates := map[string]string{legacyCgroupPath: cgroups.DriverCgroupfs} // cgroup 路径到驱动的映射
+	for _, info := range containers {
+		if info.CgroupPath == "" {
+			continue
+		}
+		if activeContainer(info) {
+			inUse[info.CgroupPath] = true
+		} else {
+			candidates[info.CgroupPath] = info.CgroupDriver
+		}
+	}
+
+	var removed []string
+	for path, driver := range candidates {
+		if inUse[path] || !cgroupExists(path) {
+			continue
+		}
+		if err := cgroups.DestroyCgroup(driver, path); err != nil {
+			logrus.Warnf("destroy cgroup %s failed: %v", path, err)
+			continue
+		}
+		removed = append(removed, path)
+	}
+	slices.Sort(removed)
+	return removed
+}
+
+// cgroupExists 判断 path 对应的 cgroup 是否存在于任意一个 subsystem 中
+func cgroupExists(path string) bool {
+	for _, p := range cgroups.CgroupPaths(path) {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
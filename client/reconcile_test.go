@@ -0,0 +1,36 @@
+package client
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+)
+
+func TestStaleContainer(t *testing.T) {
+	pid := os.Getpid()
+	startTime, err := container.ProcessStartTime(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		info  *container.Info
+		stale bool
+	}{
+		{&container.Info{Status: container.RUNNING, Pid: strconv.Itoa(pid), StartTime: startTime}, false},
+		{&container.Info{Status: container.CREATED, Pid: strconv.Itoa(pid)}, false},
+		// pid 被其它进程复用了
+		{&container.Info{Status: container.RUNNING, Pid: strconv.Itoa(pid), StartTime: startTime + 1}, true},
+		{&container.Info{Status: container.RUNNING, Pid: ""}, true},
+		{&container.Info{Status: container.RESTARTING}, false},
+		{&container.Info{Status: container.RESTARTING, SupervisorPid: pid, SupervisorStartTime: startTime}, false},
+		// 负责重启的进程已经不存在
+		{&container.Info{Status: container.RESTARTING, SupervisorPid: pid, SupervisorStartTime: startTime + 1}, true},
+		{&container.Info{Status: container.Exit}, false},
+	} {
+		if got := staleContainer(tc.info); got != tc.stale {
+			t.Errorf("staleContainer(%+v) = %v, expected %v", tc.info, got, tc.stale)
+		}
+	}
+}
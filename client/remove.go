@@ -0,0 +1,46 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+	"github.com/NatsuiroGinga/mydocker/state"
+)
+
+/*
+removeContainer 则是 rm 命令的真正实现，根据 Id 拿到容器信息，然后先判断状态:
+
+# STOP、EXIT 状态，则直接删除
+
+# RUNNING、CREATED、PAUSED、RESTARTING 状态，如果带了 force flag 则先 Stop 然后再删除，否则返回错误
+*/
+func removeContainer(containerId string, force bool) error {
+	containerInfo, err := lookupContainer(containerId)
+	if err != nil {
+		return err
+	}
+
+	switch containerInfo.Status {
+	case container.STOP, container.Exit: // 已经停止的容器可以直接删除
+		if err = state.Delete(containerId); err != nil {
+			return err
+		}
+		container.DeleteWorkSpace(containerId, containerInfo.Volume)
+		logContainerEvent(containerInfo, events.Destroy, nil)
+		return nil
+	case container.RUNNING, container.CREATED, container.PAUSED, container.RESTARTING: // 运行中的容器如果指定了force则先stop再删除
+		if !force {
+			return conflict("couldn't remove running container [%s], stop the container before "+
+				"attempting removal or force remove", containerId)
+		}
+		// 强制删除时不等待容器退出，直接 SIGKILL
+		timeout := 0
+		if err = stopContainer(containerId, &StopOptions{Signal: "SIGKILL", Timeout: &timeout}); err != nil {
+			return err
+		}
+		return removeContainer(containerId, force)
+	default:
+		return fmt.Errorf("couldn't remove container, invalid status %s", containerInfo.Status)
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/state"
+)
+
+// resolveContainerId 将命令行中指定的容器解析为完整的容器 id
+/*
+按照以下顺序查找，找到即返回：
+
+1）完整的容器 id
+
+2）容器名，先查索引，再查容器信息，兼容没有索引的容器
+
+3）容器 id 的前缀，匹配多个容器时返回 ErrInvalidParameter，需要指定更长的前缀
+*/
+func resolveContainerId(ref string) (string, error) {
+	if ref == "" {
+		return "", invalidParameter("missing container name or id")
+	}
+	// 容器名和 id 都不会包含 /，直接拼接路径前先排除掉
+	if strings.Contains(ref, "/") {
+		return "", notFound("no such container: %s", ref)
+	}
+	if state.Exists(ref) {
+		return ref, nil
+	}
+	containerId, err := container.LookupName(ref)
+	if err != nil {
+		return "", err
+	}
+	if containerId != "" {
+		return containerId, nil
+	}
+
+	containers, err := listContainers(&ListOptions{All: true})
+	if err != nil {
+		return "", err
+	}
+	return matchContainerRef(ref, containers)
+}
+
+// matchContainerRef 在 containers 中查找名称为 ref 或者 id 以 ref 开头的容器
+func matchContainerRef(ref string, containers []*container.Info) (string, error) {
+	var matches []string
+	for _, info := range containers {
+		if info.Name == ref {
+			return info.Id, nil
+		}
+		if strings.HasPrefix(info.Id, ref) {
+			matches = append(matches, info.Id)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", notFound("no such container: %s", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", invalidParameter("multiple containers found with prefix %s: %s", ref, strings.Join(matches, ", "))
+	}
+}
+
+// maxGenerateNameRetries 自动生成的容器名和已有的容器重复时最多重新生成的次数
+const maxGenerateNameRetries = 10
+
+// reserveContainerName 占用容器名，没有指定容器名时自动生成一个，返回容器使用的容器名
+func reserveContainerName(name, containerId string) (string, error) {
+	if name != "" {
+		err := container.ReserveName(name, containerId, state.List)
+		if errors.Is(err, container.ErrNameInUse) {
+			return "", &Error{Kind: ErrConflict, Err: err}
+		}
+		return name, err
+	}
+
+	for retry := 0; retry < maxGenerateNameRetries; retry++ {
+		name = container.GenerateName(retry)
+		if err := container.ReserveName(name, containerId, state.List); !errors.Is(err, container.ErrNameInUse) {
+			return name, err
+		}
+	}
+	return "", errors.New("generate container name failed, please specify one with --name")
+}
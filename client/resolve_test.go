@@ -0,0 +1,34 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+)
+
+func TestMatchContainerRef(t *testing.T) {
+	containers := []*container.Info{
+		{Id: "1234567890", Name: "web"},
+		{Id: "1299999999", Name: "db"},
+		{Id: "5555555555", Name: "12"},
+	}
+	tests := []struct {
+		ref  string
+		id   string
+		kind error
+	}{
+		{"web", "1234567890", nil},
+		{"123", "1234567890", nil},
+		{"5", "5555555555", nil},
+		{"12", "5555555555", nil}, // 容器名优先于 id 前缀
+		{"1", "", ErrInvalidParameter},
+		{"9", "", ErrNotFound},
+	}
+	for _, test := range tests {
+		id, err := matchContainerRef(test.ref, containers)
+		if id != test.id || (test.kind == nil) != (err == nil) || (test.kind != nil && !errors.Is(err, test.kind)) {
+			t.Errorf("matchContainerRef(%q) = %q, %v, want %q, %v", test.ref, id, err, test.id, test.kind)
+		}
+	}
+}
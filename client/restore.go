@@ -0,0 +1,96 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/state"
+	"github.com/sirupsen/logrus"
+)
+
+// restoreContainers 按照重启策略重新启动已经退出的容器，返回重新启动的容器 id
+/*
+宿主机重启或者 shim 被杀死后没有进程再负责重启容器，daemon 启动时先 reconcile 将这些容器标记为退出，再由这里重新启动：
+
+1）只处理通过镜像创建、已经退出的容器，是否重新启动见 RestartPolicy.ShouldRestore，
+unless-stopped 的容器被 mydocker stop 停止过时不会重新启动
+
+2）每个容器启动一个新的 shim，由 shim 重新挂载 rootfs、启动容器进程，之后和 mydocker run -d 创建的容器一样由 shim 负责
+*/
+func (c *Client) restoreContainers() ([]string, error) {
+	containers, err := state.List()
+	if err != nil {
+		return nil, err
+	}
+	var restored []string
+	for _, info := range containers {
+		if info.Bundle != "" || (info.Status != container.Exit && info.Status != container.STOP) ||
+			!info.RestartPolicy.ShouldRestore(info.ManuallyStopped) {
+			continue
+		}
+		if err = c.startShim(&shimConfig{ContainerId: info.Id, Config: restoreConfig(info), Restore: true}); err != nil {
+			logrus.Warnf("restore container %s failed: %v", info.Id, err)
+			continue
+		}
+		restored = append(restored, info.Id)
+	}
+	return restored, nil
+}
+
+// restoreConfig 由容器信息还原出创建容器时的配置
+func restoreConfig(info *container.Info) *ContainerConfig {
+	args := info.Args
+	if len(args) == 0 { // 旧版本没有记录参数，只能按空格拆分
+		args = strings.Fields(info.Command)
+	}
+	return &ContainerConfig{
+		Image:         info.Image,
+		Cmd:           args,
+		Name:          info.Name,
+		Env:           info.Env,
+		Volume:        info.Volume,
+		Network:       info.NetworkName,
+		PortMapping:   info.PortMapping,
+		Resources:     info.Resources,
+		RestartPolicy: info.RestartPolicy,
+		Labels:        info.Labels,
+		StopSignal:    info.StopSignal,
+		CgroupParent:  cgroupParent(info.CgroupDriver, info.CgroupPath),
+		CgroupDriver:  info.CgroupDriver,
+	}
+}
+
+// restoreContainer 在 shim 中重新启动已经退出的容器，之后由当前 shim 负责等待和重启容器
+//
+// 容器已经不是退出状态时放弃，例如同时有两个 daemon 在启动
+func (c *Client) restoreContainer(containerId string, opts *ContainerConfig) (*container.Info, *exec.Cmd, cgroups.CgroupManager, error) {
+	supervisorPid := os.Getpid()
+	supervisorStartTime, err := container.ProcessStartTime(supervisorPid)
+	if err != nil {
+		logrus.Warnf("read start time of process %d failed: %v", supervisorPid, err)
+	}
+	_, err = state.Update(containerId, func(info *container.Info) error {
+		if info.Status != container.Exit && info.Status != container.STOP {
+			return conflict("container %s is %s, only exited container can be restored", containerId, info.Status)
+		}
+		info.Status = container.RESTARTING
+		info.ManuallyStopped = false
+		info.SupervisorPid = supervisorPid
+		info.SupervisorStartTime = supervisorStartTime
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	container.RestoreWorkSpace(containerId, opts.Volume)
+	containerInfo, cmd, cgroupManager, err := c.restartContainer(false, containerId, opts)
+	if err == nil && containerInfo == nil {
+		err = fmt.Errorf("container %s was stopped or removed while restoring", containerId)
+	}
+	return containerInfo, cmd, cgroupManager, err
+}
@@ -0,0 +1,472 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+	"github.com/NatsuiroGinga/mydocker/network"
+	"github.com/NatsuiroGinga/mydocker/state"
+	"github.com/NatsuiroGinga/mydocker/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// errRestartCanceled 等待重启期间容器被手动停止了
+var errRestartCanceled = errors.New("restart canceled")
+
+// runAttached 执行具体 command
+/*
+这里的Start方法是真正开始前面创建好的command的调用，它首先会clone出来一个namespace隔离的
+进程，然后在子进程中，调用/proc/self/exe,也就是调用自己，发送init参数，调用我们写的init方法，
+去初始化容器的一些资源。
+
+run 等价于 create + start：先创建容器，init 进程完成初始化后阻塞在 exec fifo 上，再通过 start 放行。
+
+runAttached 只负责前台运行，等待容器进程退出并返回其退出码；后台运行的容器见 createDetached，
+由 shim 进程负责容器退出后的清理工作。
+*/
+func (c *Client) runAttached(opts *ContainerConfig) (int, error) {
+	const tty = true // 前台运行时容器进程直接使用当前终端的输入输出
+	if err := validateContainerConfig(opts); err != nil {
+		return 0, err
+	}
+	// 生成容器 id 和容器名
+	containerId := container.GenerateContainerID()
+	opts, err := withContainerName(opts, containerId)
+	if err != nil {
+		return 0, err
+	}
+
+	containerInfo, cmd, cgroupManager, err := c.createContainer(tty, containerId, opts)
+	if err != nil {
+		container.ReleaseName(opts.Name, containerId)
+		return 0, errors.Join(err, errors.New("create container failed"))
+	}
+
+	if err = startContainer(containerId); err != nil {
+		// 容器还没有运行过用户命令，和创建失败一样全部清理掉
+		teardownContainer(cmd, containerInfo, cgroupManager)
+		container.DeleteWorkSpace(containerId, opts.Volume)
+		state.Delete(containerId)
+		container.ReleaseName(opts.Name, containerId)
+		logContainerEvent(containerInfo, events.Destroy, nil)
+		return 0, errors.Join(err, fmt.Errorf("start container %s failed", containerId))
+	}
+
+	// 前台运行，等待容器进程结束
+	exitCode, err := c.superviseContainer(tty, containerInfo, cmd, cgroupManager, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.AutoRemove {
+		if err = removeContainer(containerId, false); err != nil {
+			logrus.Errorf("remove container %s failed: %v", containerId, err)
+		}
+	}
+	return exitCode, nil
+}
+
+// createDetached 由 shim 进程创建容器并返回容器 id，容器的 init 进程阻塞直到 start
+func (c *Client) createDetached(opts *ContainerConfig) (string, error) {
+	if err := validateContainerConfig(opts); err != nil {
+		return "", err
+	}
+	containerId := container.GenerateContainerID()
+	opts, err := withContainerName(opts, containerId)
+	if err != nil {
+		return "", err
+	}
+	if err = c.startShim(&shimConfig{ContainerId: containerId, Config: opts}); err != nil {
+		container.ReleaseName(opts.Name, containerId)
+		return "", err
+	}
+	return containerId, nil
+}
+
+// withContainerName 为容器占用容器名，返回填好了容器名的配置，不修改调用方传入的配置
+func withContainerName(opts *ContainerConfig, containerId string) (*ContainerConfig, error) {
+	name, err := reserveContainerName(opts.Name, containerId)
+	if err != nil {
+		return nil, err
+	}
+	config := *opts
+	config.Name = name
+	return &config, nil
+}
+
+// validateContainerConfig 创建容器前检查镜像和网络是否存在，避免创建到一半才失败
+func validateContainerConfig(opts *ContainerConfig) error {
+	if opts.Image == "" {
+		return invalidParameter("missing image name")
+	}
+	if opts.Name != "" {
+		if err := container.ValidateName(opts.Name); err != nil {
+			return &Error{Kind: ErrInvalidParameter, Err: err}
+		}
+	}
+	exist, err := utils.PathExists(utils.GetImage(opts.Image))
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return notFound("no such image: %s", opts.Image)
+	}
+	if opts.Network != "" {
+		if _, err = network.GetNetwork(opts.Network); err != nil {
+			return networkError(err)
+		}
+	}
+	if _, err = container.ParseRestartPolicy(opts.RestartPolicy.String()); err != nil {
+		return &Error{Kind: ErrInvalidParameter, Err: err}
+	}
+	if _, err = parseSignal(opts.StopSignal); err != nil {
+		return &Error{Kind: ErrInvalidParameter, Err: err}
+	}
+	if opts.Resources != nil {
+		if err = opts.Resources.Validate(); err != nil {
+			return &Error{Kind: ErrInvalidParameter, Err: err}
+		}
+	}
+	return validateCgroupParent(opts.CgroupDriver, opts.CgroupParent)
+}
+
+// superviseContainer 等待容器进程退出，并按照重启策略重启容器，返回容器最后一次退出时的退出码
+/*
+前台运行的 mydocker run 和后台运行容器的 shim 都通过它等待容器：
+
+1）容器进程退出后记录退出码，释放网络和 cgroup
+
+2）按照重启策略判断是否需要重启，被 mydocker stop 停止的容器不会重启
+
+3）需要重启时先等待一段时间，等待时间随连续重启的次数翻倍，然后在原有的 rootfs 上重新启动容器进程
+*/
+func (c *Client) superviseContainer(tty bool, containerInfo *container.Info, cmd *exec.Cmd, cgroupManager cgroups.CgroupManager, opts *ContainerConfig) (int, error) {
+	containerId := containerInfo.Id
+	var delay time.Duration
+	for {
+		startedAt := time.Now()
+		stopWatchOOM := watchOOM(containerId, cgroupManager)
+		// 进程非 0 退出时 Wait 也会返回错误，退出码以 ProcessState 为准
+		err := cmd.Wait()
+		stopWatchOOM()
+		if cmd.ProcessState == nil {
+			return 0, errors.Join(err, fmt.Errorf("wait container %s failed", containerId))
+		}
+		exitCode := container.ExitCode(cmd.ProcessState)
+		logrus.Infof("container %s exited with code %d", containerId, exitCode)
+
+		info := finishContainer(containerInfo, cgroupManager, exitCode)
+		if info == nil || !info.RestartPolicy.ShouldRestart(exitCode, info.RestartCount, info.ManuallyStopped) {
+			return exitCode, nil
+		}
+
+		delay = container.NextRestartDelay(delay, time.Since(startedAt))
+		// 退出后到这里之间容器可能已经被 mydocker stop 停止了，在锁内再检查一次
+		_, err = state.Update(containerId, func(info *container.Info) error {
+			if info.ManuallyStopped {
+				return errRestartCanceled
+			}
+			info.Status = container.RESTARTING
+			return nil
+		})
+		if err != nil {
+			if !errors.Is(err, errRestartCanceled) && !errors.Is(err, fs.ErrNotExist) {
+				logrus.Errorf("save container %s info failed: %v", containerId, err)
+			}
+			return exitCode, nil
+		}
+		logrus.Infof("restart container %s in %v", containerId, delay)
+		time.Sleep(delay)
+
+		containerInfo, cmd, cgroupManager, err = c.restartContainer(tty, containerId, opts)
+		if err != nil {
+			logrus.Errorf("restart container %s failed: %v", containerId, err)
+			return exitCode, nil
+		}
+		if containerInfo == nil { // 等待期间容器被停止或删除了
+			return exitCode, nil
+		}
+	}
+}
+
+// restartContainer 在原有的 rootfs 上重新启动容器进程，并增加重启计数
+//
+// 重启前的等待期间容器可能已经被 mydocker stop 停止或者被 mydocker rm 删除，此时不再重启，返回的容器信息为 nil
+func (c *Client) restartContainer(tty bool, containerId string, opts *ContainerConfig) (*container.Info, *exec.Cmd, cgroups.CgroupManager, error) {
+	info, err := state.Load(containerId)
+	if err != nil || info.Status != container.RESTARTING || info.ManuallyStopped {
+		return nil, nil, nil, nil
+	}
+	// mydocker update 修改后的资源限制在重启后仍然生效
+	opts.Resources = info.Resources
+
+	processInfo, cmd, cgroupManager, err := c.launchContainer(tty, containerId, opts)
+	if err != nil {
+		state.Update(containerId, func(info *container.Info) error {
+			info.Status = container.Exit
+			return nil
+		})
+		return nil, nil, nil, err
+	}
+
+	// 启动期间容器可能被 mydocker stop 停止了，此时放弃这次重启
+	info, err = state.Update(containerId, func(info *container.Info) error {
+		if info.ManuallyStopped {
+			return errRestartCanceled
+		}
+		info.Pid = processInfo.Pid
+		info.StartTime = processInfo.StartTime
+		info.IP = processInfo.IP
+		info.Status = container.CREATED
+		info.RestartCount++
+		// 重启后是新的 cgroup，OOM 计数从 0 开始
+		info.OOMCount = 0
+		info.OOMKillCount = 0
+		return nil
+	})
+	if err != nil {
+		teardownContainer(cmd, processInfo, cgroupManager)
+		if errors.Is(err, errRestartCanceled) || errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, nil, nil
+		}
+		return nil, nil, nil, err
+	}
+	if err = startContainer(containerId); err != nil {
+		teardownContainer(cmd, processInfo, cgroupManager)
+		state.Update(containerId, func(info *container.Info) error {
+			info.Status = container.Exit
+			info.Pid = ""
+			info.StartTime = 0
+			info.IP = ""
+			return nil
+		})
+		return nil, nil, nil, err
+	}
+	return info, cmd, cgroupManager, nil
+}
+
+// teardownContainer 杀死还没有运行用户命令的 init 进程，释放它的网络和 cgroup，创建或重启容器失败时使用
+func teardownContainer(cmd *exec.Cmd, containerInfo *container.Info, cgroupManager cgroups.CgroupManager) {
+	syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
+	cmd.Wait()
+	if containerInfo.IP != "" {
+		network.Disconnect(containerInfo.NetworkName, containerInfo)
+	}
+	cgroupManager.Destroy()
+}
+
+// finishContainer 容器进程退出后释放网络和 cgroup 资源，并记录退出码和退出时间
+/*
+容器的 rootfs 和容器信息会保留下来，便于通过 mydocker ps -a、mydocker logs 查看，直到 mydocker rm 时才删除。
+
+销毁 cgroup 之前读取最终的 OOM 计数，进程被 SIGKILL 杀死、oom_kill 计数大于 0 并且不是被 mydocker stop 停止时，
+认为是被 OOM killer 杀死的。
+
+返回更新后的容器信息，容器已经被删除时返回 nil。
+*/
+func finishContainer(containerInfo *container.Info, cgroupManager cgroups.CgroupManager, exitCode int) *container.Info {
+	containerId := containerInfo.Id
+
+	events, err := cgroupManager.OOMEvents()
+	if err != nil {
+		logrus.Warnf("read oom events of container %s failed: %v", containerId, err)
+		events = &resource.OOMEvents{}
+	}
+	oomKilled := exitCode == 128+int(syscall.SIGKILL) && events.OOMKill > 0
+
+	if containerInfo.NetworkName != "" {
+		if err := network.Disconnect(containerInfo.NetworkName, containerInfo); err != nil {
+			logrus.Warnf("disconnect container %s from network %s failed: %v", containerId, containerInfo.NetworkName, err)
+		}
+	}
+	cgroupManager.Destroy()
+
+	// 容器运行期间 mydocker stop 等命令可能修改过容器信息，在锁内读取并修改
+	// 容器不存在说明已经被 mydocker rm -f 删除了，不能再写回
+	info, err := state.Update(containerId, func(info *container.Info) error {
+		info.Status = container.Exit
+		info.Pid = ""
+		info.StartTime = 0
+		info.IP = "" // 网络已经断开，IP 已经释放
+		info.ExitCode = exitCode
+		info.FinishedAt = time.Now().Format(time.DateTime)
+		// 被 mydocker stop 杀死的容器即使之前有进程被 OOM killer 杀死过，也不是因为 OOM 退出的
+		info.OOMKilled = oomKilled && !info.ManuallyStopped
+		info.OOMCount = max(info.OOMCount, events.OOM)
+		info.OOMKillCount = max(info.OOMKillCount, events.OOMKill)
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		logrus.Infof("container %s has been removed, skip recording exit code", containerId)
+		return nil
+	}
+	if err != nil {
+		logrus.Errorf("save container %s info failed: %v", containerId, err)
+		return nil
+	}
+	if info.OOMKilled {
+		logrus.Warnf("container %s was killed by oom killer", containerId)
+	}
+	logDieEvent(info)
+	return info
+}
+
+// createContainer 创建容器但不运行用户命令，对应 mydocker create
+/*
+1）准备 overlayfs
+
+2）启动 init 进程并完成初始化，见 launchContainer
+
+3）记录容器信息，状态为 created
+
+返回后 init 进程阻塞在 exec fifo 上，直到 mydocker start
+*/
+func (c *Client) createContainer(tty bool, containerId string, opts *ContainerConfig) (*container.Info, *exec.Cmd, cgroups.CgroupManager, error) {
+	logrus.Infof("containerID: %s", containerId)
+	container.NewWorkSpace(containerId, opts.Image, opts.Volume)
+
+	processInfo, cmd, cgroupManager, err := c.launchContainer(tty, containerId, opts)
+	if err != nil {
+		container.DeleteWorkSpace(containerId, opts.Volume)
+		state.Delete(containerId)
+		return nil, nil, nil, err
+	}
+
+	// 记录容器信息， 写入/var/lib/mydocker/[containerId]/config.json中
+	processInfo.Command = strings.Join(opts.Cmd, " ")
+	processInfo.Args = opts.Cmd
+	processInfo.Image = opts.Image
+	processInfo.Env = opts.Env
+	processInfo.Volume = opts.Volume
+	processInfo.Resources = opts.Resources
+	processInfo.RestartPolicy = opts.RestartPolicy
+	processInfo.Labels = opts.Labels
+	processInfo.StopSignal = opts.StopSignal
+	// 创建容器的进程之后通过 superviseContainer 等待容器进程退出
+	processInfo.SupervisorPid = os.Getpid()
+	if processInfo.SupervisorStartTime, err = container.ProcessStartTime(processInfo.SupervisorPid); err != nil {
+		logrus.Warnf("read start time of process %d failed: %v", processInfo.SupervisorPid, err)
+	}
+	if err = state.Create(processInfo); err != nil {
+		syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+		if processInfo.IP != "" {
+			network.Disconnect(opts.Network, processInfo)
+		}
+		cgroupManager.Destroy()
+		container.DeleteWorkSpace(containerId, opts.Volume)
+		state.Delete(containerId)
+		return nil, nil, nil, errors.Join(err, errors.New("record container info failed"))
+	}
+	logContainerEvent(processInfo, events.Create, nil)
+
+	return processInfo, cmd, cgroupManager, nil
+}
+
+// launchContainer 在已经准备好的 rootfs 上启动容器的 init 进程，创建和重启容器时使用
+/*
+1）启动 init 进程，此时 init 进程阻塞在 sync socket 上
+
+2）设置 cgroup
+
+3）如果指定了网络则配置网络
+
+4）通过 sync socket 发送命令，等待 init 进程完成挂载等初始化工作
+
+返回的容器信息只包含 init 进程和网络相关的字段，失败时已经释放了网络和 cgroup
+*/
+func (c *Client) launchContainer(tty bool, containerId string, opts *ContainerConfig) (*container.Info, *exec.Cmd, cgroups.CgroupManager, error) {
+	cmd, syncPipe := container.NewParentProcess(tty, containerId)
+	if cmd == nil {
+		return nil, nil, nil, errors.New("new parent process error")
+	}
+	cmd.Path = c.binaryPath() // init 进程由 mydocker 可执行文件启动
+	defer syncPipe.Close()
+	// 启动子进程
+	if err := startInitProcess(cmd); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 每个容器使用自己的 cgroup，容器退出时销毁 cgroup 不会影响其它容器
+	cgroupPath := containerCgroupPath(opts.CgroupDriver, opts.CgroupParent, containerId)
+	cgroupManager := cgroups.NewManager(opts.CgroupDriver, cgroupPath)
+	if opts.Resources != nil { // 通过 Client 创建的容器可以不限制资源
+		if err := cgroupManager.Set(opts.Resources); err != nil {
+			syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
+			cmd.Wait()
+			cgroupManager.Destroy()
+			return nil, nil, nil, errors.Join(err, errors.New("set cgroup resources failed"))
+		}
+	}
+	if err := cgroupManager.Apply(cmd.Process.Pid); err != nil {
+		syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+		cgroupManager.Destroy()
+		return nil, nil, nil, errors.Join(err, errors.New("apply cgroup failed"))
+	}
+
+	startTime, err := container.ProcessStartTime(cmd.Process.Pid)
+	if err != nil {
+		logrus.Warnf("read start time of process %d failed: %v", cmd.Process.Pid, err)
+	}
+	containerInfo := &container.Info{
+		Id:           containerId,
+		Pid:          strconv.Itoa(cmd.Process.Pid),
+		StartTime:    startTime,
+		Name:         opts.Name,
+		PortMapping:  opts.PortMapping,
+		CgroupPath:   cgroupPath,
+		CgroupDriver: opts.CgroupDriver,
+	}
+	// 失败时需要把已经创建的资源清理掉
+	destroy := func() {
+		syscall.Kill(cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+		if containerInfo.IP != "" {
+			network.Disconnect(opts.Network, containerInfo)
+		}
+		cgroupManager.Destroy()
+	}
+
+	// 如果指定了网络信息则进行配置
+	if opts.Network != "" {
+		// config container network
+		ip, err := network.Connect(opts.Network, containerInfo)
+		if err != nil {
+			destroy()
+			return nil, nil, nil, errors.Join(err, errors.New("connect network failed"))
+		}
+		containerInfo.IP = ip.String()
+		containerInfo.NetworkName = opts.Network
+	}
+
+	// cgroup 和网络都配置好之后，才在子进程创建后通过管道来发送参数
+	if err := sendInitConfig(container.NewInitConfig(containerId, opts.Cmd, opts.Env), syncPipe); err != nil {
+		destroy()
+		return nil, nil, nil, err
+	}
+	if err := container.WaitInitReady(syncPipe); err != nil {
+		destroy()
+		return nil, nil, nil, err
+	}
+
+	return containerInfo, cmd, cgroupManager, nil
+}
+
+// sendInitConfig 通过 sync socket 将指令发送给子进程
+func sendInitConfig(config *container.InitConfig, syncPipe *os.File) error {
+	logrus.Infof("all command is %q", config.Args)
+
+	return container.SendInitConfig(syncPipe, config)
+}
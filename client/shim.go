@@ -0,0 +1,166 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/constant"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	shimResultFdIndex = 3          // shim 进程通过该 fd 将创建结果返回给 mydocker
+	shimLogName       = "shim.log" // shim 进程自身的日志，位于容器信息目录下
+)
+
+// shimConfig mydocker 通过 stdin 传递给 shim 进程的配置
+type shimConfig struct {
+	ContainerId string           `json:"containerId"`
+	Config      *ContainerConfig `json:"config"`
+	Restore     bool             `json:"restore,omitempty"` // 重新启动已经退出的容器，而不是创建新的容器
+}
+
+// shimResult shim 进程创建容器的结果，为空表示成功
+type shimResult struct {
+	Error string `json:"error,omitempty"`
+}
+
+// startShim 启动 shim 进程，等待它完成容器的创建后返回
+/*
+后台运行的容器如果由 mydocker 直接创建，mydocker 退出后就没有进程等待容器退出并做清理工作了，
+因此改为由 shim 进程创建容器。shim 通过 setsid 脱离当前会话，在 mydocker 退出后继续作为容器 init 进程的父进程：
+
+1）创建容器，持有容器的 stdout、stderr 并写入日志文件，容器的 init 进程阻塞直到 start
+
+2）通过 fd 3 将创建结果返回给 mydocker，mydocker 读到结果后即可退出
+
+3）等待容器进程退出，记录退出码和退出时间，释放网络和 cgroup，按照重启策略重启容器，指定了 --rm 时删除容器
+*/
+func (c *Client) startShim(config *shimConfig) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return errors.Join(err, errors.New("marshal shim config failed"))
+	}
+
+	configReader, configWriter, err := os.Pipe()
+	if err != nil {
+		return errors.Join(err, errors.New("create shim config pipe failed"))
+	}
+	defer configWriter.Close()
+	resultReader, resultWriter, err := os.Pipe()
+	if err != nil {
+		return errors.Join(err, errors.New("create shim result pipe failed"))
+	}
+	defer resultReader.Close()
+	shimLog, err := newShimLogFile(config.ContainerId)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(c.binaryPath(), "shim")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Stdin = configReader
+	cmd.Stdout = shimLog
+	cmd.Stderr = shimLog
+	cmd.ExtraFiles = []*os.File{resultWriter}
+	cmd.Dir = "/"
+	err = cmd.Start()
+	// 关闭父进程中已经传递给 shim 的 fd，否则 shim 异常退出时读不到 EOF
+	configReader.Close()
+	resultWriter.Close()
+	shimLog.Close()
+	if err != nil {
+		return errors.Join(err, errors.New("start shim process failed"))
+	}
+
+	if _, err = configWriter.Write(configBytes); err != nil {
+		return errors.Join(err, errors.New("write shim config failed"))
+	}
+	configWriter.Close()
+
+	result := new(shimResult)
+	if err = json.NewDecoder(resultReader).Decode(result); err != nil {
+		cmd.Wait()
+		return errors.Join(err, fmt.Errorf("shim of container %s exited before the container was created", config.ContainerId))
+	}
+	if result.Error != "" {
+		return errors.New(result.Error)
+	}
+
+	logrus.Infof("container %s is supervised by shim %d", config.ContainerId, cmd.Process.Pid)
+	// shim 在容器退出后才会退出，在 daemon 中需要回收它，否则会留下僵尸进程
+	go cmd.Wait()
+	return nil
+}
+
+// RunShim shim 进程的入口，对应 mydocker shim 命令，只能在 mydocker 进程中调用
+func RunShim() error {
+	config := new(shimConfig)
+	if err := json.NewDecoder(os.Stdin).Decode(config); err != nil {
+		return errors.Join(err, errors.New("read shim config failed"))
+	}
+	containerId := config.ContainerId
+	resultPipe := os.NewFile(uintptr(shimResultFdIndex), "shim-result")
+
+	c := New(SelfExe)
+	var (
+		containerInfo *container.Info
+		cmd           *exec.Cmd
+		cgroupManager cgroups.CgroupManager
+		err           error
+	)
+	if config.Restore {
+		containerInfo, cmd, cgroupManager, err = c.restoreContainer(containerId, config.Config)
+	} else {
+		containerInfo, cmd, cgroupManager, err = c.createContainer(false, containerId, config.Config)
+	}
+	writeShimResult(resultPipe, err)
+	if containerInfo == nil {
+		return err
+	}
+
+	logrus.Infof("shim is waiting for container %s, pid %d", containerId, cmd.Process.Pid)
+	if _, err = c.superviseContainer(false, containerInfo, cmd, cgroupManager, config.Config); err != nil {
+		return err
+	}
+
+	if config.Config.AutoRemove {
+		if err = removeContainer(containerId, false); err != nil {
+			logrus.Errorf("remove container %s failed: %v", containerId, err)
+		}
+	}
+	return nil
+}
+
+// writeShimResult 将创建结果写回 mydocker 并关闭管道
+func writeShimResult(resultPipe *os.File, err error) {
+	defer resultPipe.Close()
+	result := new(shimResult)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if err = json.NewEncoder(resultPipe).Encode(result); err != nil {
+		logrus.Errorf("write shim result failed: %v", err)
+	}
+}
+
+// newShimLogFile 创建 shim 进程的日志文件 /var/lib/mydocker/containers/{containerID}/shim.log
+func newShimLogFile(containerId string) (*os.File, error) {
+	dirPath := fmt.Sprintf(container.InfoLocFormat, containerId)
+	if err := os.MkdirAll(dirPath, constant.Perm0622); err != nil {
+		return nil, errors.Join(err, fmt.Errorf("mkdir %s failed", dirPath))
+	}
+	logPath := path.Join(dirPath, shimLogName)
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("create file %s failed", logPath))
+	}
+	return logFile, nil
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+	"github.com/sirupsen/logrus"
+)
+
+// startContainer 启动一个处于 created 状态的容器，对应 OCI 生命周期中的 start 操作
+/*
+1）在容器的锁内检查状态，打开 exec fifo 的读端，阻塞在 fifo 上的 init 进程随即 exec 用户进程
+
+2）将容器状态修改为 running
+
+3）执行 poststart hooks，按照规范 poststart 失败只打印警告
+
+检查状态和修改状态在同一把锁内完成，同时执行的两个 start 只有一个会成功
+*/
+func startContainer(containerId string) error {
+	containerInfo, err := updateContainer(containerId, func(info *container.Info) error {
+		if info.Status != container.CREATED {
+			return conflict("container %s is %s, only created container can be started", containerId, info.Status)
+		}
+		pid, err := strconv.Atoi(info.Pid)
+		if err != nil {
+			return fmt.Errorf("invalid pid %s of container %s", info.Pid, containerId)
+		}
+		if err = container.ReleaseExecFifo(containerId, pid); err != nil {
+			return err
+		}
+		info.Status = container.RUNNING
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	logContainerEvent(containerInfo, events.Start, nil)
+
+	if containerInfo.Bundle != "" {
+		spec, err := container.LoadSpec(containerInfo.Bundle)
+		if err != nil {
+			logrus.Warnf("load spec of bundle %s error %v", containerInfo.Bundle, err)
+			return nil
+		}
+		if spec.Hooks != nil {
+			ociState := container.BundleState(containerInfo, spec)
+			if err = container.RunHooks(spec.Hooks.Poststart, ociState); err != nil {
+				logrus.Warnf("run poststart hooks error %v", err)
+			}
+		}
+	}
+	return nil
+}
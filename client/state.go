@@ -0,0 +1,22 @@
+package client
+
+import (
+	"github.com/NatsuiroGinga/mydocker/container"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// containerState 返回容器的 OCI state，不是通过 bundle 创建的容器没有 bundle 相关的字段
+func containerState(containerId string) (*specs.State, error) {
+	containerInfo, err := lookupContainer(containerId)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec *specs.Spec
+	if containerInfo.Bundle != "" {
+		if spec, err = container.LoadSpec(containerInfo.Bundle); err != nil {
+			return nil, err
+		}
+	}
+	return container.BundleState(containerInfo, spec), nil
+}
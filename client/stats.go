@@ -0,0 +1,71 @@
+package client
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/network"
+	"github.com/sirupsen/logrus"
+)
+
+// containerStats 读取容器 cgroup 的资源使用情况和容器中网卡的流量
+/*
+只有 cgroup 存在的容器，即 created、running、paused 状态的容器才能读取。
+没有网络的容器中只有 lo，Networks 为空
+*/
+func containerStats(containerId string) (*ContainerStats, error) {
+	info, err := lookupContainer(containerId)
+	if err != nil {
+		return nil, err
+	}
+	if !cgroupAlive(info) {
+		return nil, conflict("container %s is not running", containerId)
+	}
+	if info.CgroupPath == "" {
+		return nil, conflict("container %s has no cgroup", containerId)
+	}
+
+	stats, err := cgroups.NewManager(info.CgroupDriver, info.CgroupPath).Stats()
+	if err != nil {
+		return nil, err
+	}
+	result := &ContainerStats{
+		Id:          info.Id,
+		Name:        info.Name,
+		Read:        time.Now(),
+		Stats:       *stats,
+		MemoryLimit: stats.Memory.Limit,
+	}
+	// 没有限制内存或者限制超过了宿主机的内存时，容器最多只能使用宿主机的内存
+	if total, err := hostMeminfo("MemTotal"); err == nil && (result.MemoryLimit == 0 || result.MemoryLimit > total) {
+		result.MemoryLimit = total
+	}
+	if pid, err := strconv.Atoi(info.Pid); err == nil {
+		if result.Networks, err = network.ReadInterfaceStats(pid); err != nil {
+			logrus.Warnf("read network stats of container %s failed: %v", containerId, err)
+		}
+	}
+	return result, nil
+}
+
+// CPUPercent 根据相邻两次读取的 CPU 使用时间计算 CPU 使用率，100% 表示用满一个 CPU
+func CPUPercent(prev, cur *ContainerStats) float64 {
+	if prev == nil || cur == nil || !cur.Read.After(prev.Read) || cur.CPU.UsageUsec < prev.CPU.UsageUsec {
+		return 0
+	}
+	elapsed := float64(cur.Read.Sub(prev.Read).Microseconds())
+	return float64(cur.CPU.UsageUsec-prev.CPU.UsageUsec) / elapsed * 100
+}
+
+// MemoryPercent 返回去掉可回收的页缓存之后的内存使用量以及占内存限制的百分比
+func MemoryPercent(stats *ContainerStats) (uint64, float64) {
+	usage := stats.Memory.Usage
+	if stats.Memory.Cache < usage {
+		usage -= stats.Memory.Cache
+	}
+	if stats.MemoryLimit == 0 {
+		return usage, 0
+	}
+	return usage, float64(usage) / float64(stats.MemoryLimit) * 100
+}
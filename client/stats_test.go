@@ -0,0 +1,28 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+)
+
+func TestCPUPercent(t *testing.T) {
+	now := time.Now()
+	prev := &ContainerStats{Read: now, Stats: resource.Stats{CPU: resource.CPUStats{UsageUsec: 1000000}}}
+	cur := &ContainerStats{Read: now.Add(2 * time.Second), Stats: resource.Stats{CPU: resource.CPUStats{UsageUsec: 2000000}}}
+	if got := CPUPercent(prev, cur); got != 50 {
+		t.Fatalf("CPUPercent = %v, want 50", got)
+	}
+	if got := CPUPercent(nil, cur); got != 0 {
+		t.Fatalf("CPUPercent without previous stats = %v, want 0", got)
+	}
+}
+
+func TestMemoryPercent(t *testing.T) {
+	stats := &ContainerStats{MemoryLimit: 200, Stats: resource.Stats{Memory: resource.MemoryStats{Usage: 120, Cache: 20}}}
+	usage, percent := MemoryPercent(stats)
+	if usage != 100 || percent != 50 {
+		t.Fatalf("MemoryPercent = %d, %v, want 100, 50", usage, percent)
+	}
+}
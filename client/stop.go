@@ -0,0 +1,164 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+	"github.com/NatsuiroGinga/mydocker/state"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultStopTimeout 没有指定超时时间时，stop 等待容器退出的秒数
+const DefaultStopTimeout = 10
+
+// stopPollInterval 等待容器进程退出时检查的间隔
+const stopPollInterval = 50 * time.Millisecond
+
+/*
+stopContainer 负责停止容器的运行
+
+主要分四步：
+
+1.在容器的锁内检查状态，已经退出的容器不需要停止，直接返回。否则标记容器是被手动停止的，
+等待容器的进程看到该标记后不会按照重启策略重启容器。必须在发送信号之前写入，否则容器退出时可能还读不到该标记。
+暂停的容器进程还存在时先解冻，冻结的进程无法处理信号
+
+2.向容器的 init 进程发送停止信号，依次使用 opts.Signal、创建容器时指定的 --stop-signal、SIGTERM
+
+3.等待进程退出，超时后发送 SIGKILL。PID namespace 中的 1 号进程没有注册信号处理函数时会忽略 SIGTERM，
+只能通过 SIGKILL 停止
+
+4.进程确实退出后，等待 shim 或前台运行的 mydocker run 记录退出码，保留它记录的 exited 状态和退出码。
+没有进程负责记录时才将容器状态更新为 stop 并清空 PID
+*/
+func stopContainer(containerId string, opts *StopOptions) error {
+	if opts == nil {
+		opts = &StopOptions{}
+	}
+	// 1. 标记为手动停止，正在等待重启的容器没有进程，直接置为退出状态即可
+	var exited, restarting bool
+	containerInfo, err := updateContainer(containerId, func(info *container.Info) error {
+		if info.Status == container.Exit || info.Status == container.STOP {
+			exited = true
+			return nil
+		}
+		info.ManuallyStopped = true
+		restarting = info.Status == container.RESTARTING
+		if restarting {
+			info.Status = container.Exit
+		}
+		// 进程已经退出的暂停容器不能解冻后标记为运行中，由第 4 步记录为停止
+		pid, _ := strconv.Atoi(info.Pid)
+		if info.Status == container.PAUSED && container.ProcessAlive(pid, info.StartTime) {
+			if err := cgroups.NewManager(info.CgroupDriver, info.CgroupPath).Thaw(); err != nil {
+				return err
+			}
+			info.Status = container.RUNNING
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if exited {
+		return nil
+	}
+	if restarting {
+		logContainerEvent(containerInfo, events.Stop, nil)
+		return nil
+	}
+
+	pidInt, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return conflict("container %s is not running", containerId)
+	}
+	signal := opts.Signal
+	if signal == "" {
+		signal = containerInfo.StopSignal
+	}
+	sig, err := parseSignal(signal)
+	if err != nil {
+		return &Error{Kind: ErrInvalidParameter, Err: err}
+	}
+	timeout := DefaultStopTimeout
+	if opts.Timeout != nil {
+		timeout = *opts.Timeout
+	}
+
+	// 2、3. 发送停止信号并等待退出，超时后发送 SIGKILL
+	if container.ProcessAlive(pidInt, containerInfo.StartTime) {
+		if err = signalAndWait(pidInt, containerInfo.StartTime, sig, timeout); err != nil {
+			return errors.Join(err, fmt.Errorf("stop container %s failed", containerId))
+		}
+	}
+
+	// 4. 没有进程记录退出码时修改容器信息，将容器置为STOP状态，并清空PID
+	waitExitRecorded(containerInfo)
+	_, err = updateContainer(containerId, func(info *container.Info) error {
+		if info.Pid != containerInfo.Pid { // 已经记录了退出码，或者已经是新的进程了，不能覆盖
+			return nil
+		}
+		info.Status = container.STOP
+		info.Pid = ""
+		info.StartTime = 0
+		info.ExitCode = container.ExitCodeUnknown
+		info.FinishedAt = time.Now().Format(time.DateTime)
+		return nil
+	})
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("save container %s info failed", containerId))
+	}
+	logContainerEvent(containerInfo, events.Stop, nil)
+	return nil
+}
+
+// signalAndWait 向进程发送 sig，等待 timeout 秒后进程仍然存在则发送 SIGKILL，直到进程退出才返回
+//
+// timeout 为负数时一直等待，不发送 SIGKILL
+func signalAndWait(pid int, startTime uint64, sig syscall.Signal, timeout int) error {
+	if err := syscall.Kill(pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	if waitProcess(pid, startTime, timeout) {
+		return nil
+	}
+
+	logrus.Infof("process %d did not exit within %ds after %s, killing it", pid, timeout, sig)
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	waitProcess(pid, startTime, -1)
+	return nil
+}
+
+// waitExitRecorded 等待负责容器的进程记录退出码，该进程不存在或者 waitRecordGrace 内没有记录时直接返回
+//
+// 记录退出码时会清空 PID，因此以 PID 是否变化判断是否已经记录
+func waitExitRecorded(containerInfo *container.Info) {
+	deadline := time.Now().Add(waitRecordGrace)
+	for container.ProcessAlive(containerInfo.SupervisorPid, containerInfo.SupervisorStartTime) && time.Now().Before(deadline) {
+		info, err := state.Load(containerInfo.Id)
+		if err != nil || info.Pid != containerInfo.Pid {
+			return
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
+
+// waitProcess 等待进程退出，timeout 秒内退出时返回 true，timeout 为负数时一直等待
+func waitProcess(pid int, startTime uint64, timeout int) bool {
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+	for container.ProcessAlive(pid, startTime) {
+		if timeout >= 0 && time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
+	}
+	return true
+}
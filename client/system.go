@@ -0,0 +1,282 @@
+package client
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/network"
+	"github.com/NatsuiroGinga/mydocker/state"
+	"github.com/NatsuiroGinga/mydocker/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// danglingImage 没有指定镜像名 commit 时以容器 id 作为镜像名，这样的镜像即悬空镜像
+var danglingImage = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// pruneItem prune 时用于过滤的对象属性，创建时间未知时为零值，视为足够早
+type pruneItem struct {
+	created time.Time
+	labels  map[string]string
+}
+
+// pruneFilters prune 支持的过滤条件
+var pruneFilters = filterFuncs[*pruneItem]{
+	"until": func(item *pruneItem, value string) bool {
+		until, err := parseUntil(value)
+		return err == nil && item.created.Before(until)
+	},
+	"label": func(item *pruneItem, value string) bool { return matchLabel(item.labels, value) },
+}
+
+// parseUntil 解析 until 过滤条件，可以是 24h 这样的时长，也可以是 2006-01-02 15:04:05 或者 RFC3339 格式的时间
+func parseUntil(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateTime, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseTime 解析容器信息中 2006-01-02 15:04:05 格式的时间，为空或者格式不对时返回零值
+func parseTime(value string) time.Time {
+	t, _ := time.ParseInLocation(time.DateTime, value, time.Local)
+	return t
+}
+
+// diskUsage 统计镜像、容器、数据卷占用的磁盘空间
+/*
+容器的大小分为三部分：可写层、从镜像解压出来的 lower 目录、日志文件。
+
+数据卷只统计由 mydocker 创建的目录，用户自己指定的宿主机目录不属于 mydocker，
+可能很大甚至是根目录，因此不统计。
+*/
+func diskUsage() (*DiskUsage, error) {
+	containers, err := listContainers(&ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	images, err := listImages()
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := container.ListVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &DiskUsage{}
+	for _, image := range images {
+		usage.Images = append(usage.Images, &ImageUsage{
+			Name:       image.Name,
+			Size:       imageSize(image.Name),
+			Containers: countContainers(containers, func(info *container.Info) bool { return info.Image == image.Name }),
+		})
+	}
+	for _, info := range containers {
+		usage.Containers = append(usage.Containers, containerUsage(info))
+	}
+	for _, volume := range volumes {
+		size, err := utils.DirSize(volume.Path)
+		if err != nil {
+			logrus.Warnf("get size of volume %s failed: %v", volume.Path, err)
+		}
+		usage.Volumes = append(usage.Volumes, &VolumeUsage{
+			Path:       volume.Path,
+			Size:       size,
+			Containers: countContainers(containers, func(info *container.Info) bool { return usesVolume(info, volume.Path) }),
+			Managed:    true,
+		})
+	}
+	return usage, nil
+}
+
+func containerUsage(info *container.Info) *ContainerUsage {
+	usage := &ContainerUsage{Id: info.Id, Name: info.Name, Image: info.Image, Status: info.Status}
+	upper, _ := utils.DirSize(utils.GetUpper(info.Id))
+	work, _ := utils.DirSize(utils.GetWorker(info.Id))
+	usage.Size = upper + work
+	usage.LowerSize, _ = utils.DirSize(utils.GetLower(info.Id))
+	if fi, err := os.Stat(containerLogPath(info.Id)); err == nil {
+		usage.LogSize = fi.Size()
+	}
+	return usage
+}
+
+func imageSize(name string) int64 {
+	var size int64
+	for _, file := range []string{utils.GetImage(name), utils.GetImageConfig(name)} {
+		if fi, err := os.Stat(file); err == nil {
+			size += fi.Size()
+		}
+	}
+	return size
+}
+
+func countContainers(containers []*container.Info, fn func(info *container.Info) bool) int {
+	count := 0
+	for _, info := range containers {
+		if fn(info) {
+			count++
+		}
+	}
+	return count
+}
+
+// usesVolume 容器是否挂载了宿主机目录 hostPath
+func usesVolume(info *container.Info, hostPath string) bool {
+	if info.Volume == "" {
+		return false
+	}
+	path, err := container.VolumeHostPath(info.Volume)
+	return err == nil && path == hostPath
+}
+
+// prune 清理不再使用的容器、网络、镜像和数据卷
+/*
+按照依赖关系依次清理，前面删除的容器不再占用后面的网络、镜像和数据卷：
+
+1）已经停止的容器，运行中的容器不会被删除
+
+2）没有被剩余容器使用的网络
+
+3）没有被剩余容器使用的镜像，没有指定 All 时只删除悬空镜像
+
+4）指定了 Volumes 时，删除没有被剩余容器使用、由 mydocker 创建的数据卷
+
+单个对象删除失败时打印警告并跳过，不影响其它对象。
+*/
+func prune(opts *PruneOptions) (*PruneReport, error) {
+	if opts == nil {
+		opts = &PruneOptions{}
+	}
+	if err := validatePruneFilters(opts.Filters); err != nil {
+		return nil, err
+	}
+	containers, err := listContainers(&ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PruneReport{}
+	remaining := pruneContainers(containers, opts.Filters, report)
+	if err = pruneNetworks(remaining, opts.Filters, report); err != nil {
+		return report, err
+	}
+	if err = pruneImages(remaining, opts, report); err != nil {
+		return report, err
+	}
+	if opts.Volumes {
+		if err = pruneVolumes(remaining, opts.Filters, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// validatePruneFilters 检查过滤条件的 key 和 until 的值是否合法
+func validatePruneFilters(filters Filters) error {
+	if err := validateFilters(filters, pruneFilters); err != nil {
+		return err
+	}
+	for _, value := range filters["until"] {
+		if _, err := parseUntil(value); err != nil {
+			return invalidParameter("invalid filter until=%s, must be a duration like 24h or a timestamp", value)
+		}
+	}
+	return nil
+}
+
+// pruneContainers 删除已经停止的容器，返回剩余的容器
+func pruneContainers(containers []*container.Info, filters Filters, report *PruneReport) []*container.Info {
+	remaining := make([]*container.Info, 0, len(containers))
+	for _, info := range containers {
+		item := &pruneItem{created: parseTime(info.CreatedTime), labels: info.Labels}
+		if activeContainer(info) || !matchFilters(item, filters, pruneFilters) {
+			remaining = append(remaining, info)
+			continue
+		}
+		usage := containerUsage(info)
+		if err := removeContainer(info.Id, false); err != nil {
+			logrus.Warnf("remove container %s failed: %v", info.Id, err)
+			remaining = append(remaining, info)
+			continue
+		}
+		report.Containers = append(report.Containers, info.Id)
+		report.SpaceReclaimed += usage.Size + usage.LowerSize + usage.LogSize
+	}
+	return remaining
+}
+
+// pruneNetworks 删除没有容器使用的网络
+func pruneNetworks(remaining []*container.Info, filters Filters, report *PruneReport) error {
+	networks, err := network.ListNetwork()
+	if err != nil {
+		return err
+	}
+	for _, nw := range networks {
+		used := slices.ContainsFunc(remaining, func(info *container.Info) bool { return info.NetworkName == nw.Name })
+		item := &pruneItem{created: parseTime(nw.Created), labels: nw.Labels}
+		if used || !matchFilters(item, filters, pruneFilters) {
+			continue
+		}
+		if err = removeNetwork(nw.Name); err != nil {
+			logrus.Warnf("remove network %s failed: %v", nw.Name, err)
+			continue
+		}
+		report.Networks = append(report.Networks, nw.Name)
+	}
+	return nil
+}
+
+// pruneImages 删除没有容器使用的镜像，没有指定 All 时只删除容器已经不存在的悬空镜像
+func pruneImages(remaining []*container.Info, opts *PruneOptions, report *PruneReport) error {
+	images, err := listImages()
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		used := slices.ContainsFunc(remaining, func(info *container.Info) bool { return info.Image == image.Name })
+		if used || (!opts.All && !(danglingImage.MatchString(image.Name) && !state.Exists(image.Name))) {
+			continue
+		}
+		item := &pruneItem{created: parseTime(image.Created), labels: image.Labels}
+		if !matchFilters(item, opts.Filters, pruneFilters) {
+			continue
+		}
+		size := imageSize(image.Name)
+		if err = removeImage(image.Name); err != nil {
+			logrus.Warnf("remove image %s failed: %v", image.Name, err)
+			continue
+		}
+		report.Images = append(report.Images, image.Name)
+		report.SpaceReclaimed += size
+	}
+	return nil
+}
+
+// pruneVolumes 删除没有容器使用、由 mydocker 创建的数据卷，数据卷没有标签，指定了 label 过滤条件时不会删除
+func pruneVolumes(remaining []*container.Info, filters Filters, report *PruneReport) error {
+	volumes, err := container.ListVolumes()
+	if err != nil {
+		return err
+	}
+	for _, volume := range volumes {
+		used := slices.ContainsFunc(remaining, func(info *container.Info) bool { return usesVolume(info, volume.Path) })
+		if used || !matchFilters(&pruneItem{created: volume.Created}, filters, pruneFilters) {
+			continue
+		}
+		size, _ := utils.DirSize(volume.Path)
+		if err = container.RemoveVolume(volume.Path); err != nil {
+			logrus.Warnf("remove volume %s failed: %v", volume.Path, err)
+			continue
+		}
+		report.Volumes = append(report.Volumes, volume.Path)
+		report.SpaceReclaimed += size
+	}
+	return nil
+}
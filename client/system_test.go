@@ -0,0 +1,55 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUntil(t *testing.T) {
+	until, err := parseUntil("24h")
+	if err != nil || time.Since(until) < 24*time.Hour-time.Minute {
+		t.Fatalf("unexpected until %v, %v", until, err)
+	}
+	until, err = parseUntil("2024-01-02 03:04:05")
+	if err != nil || until.Year() != 2024 || until.Hour() != 3 {
+		t.Fatalf("unexpected until %v, %v", until, err)
+	}
+	if _, err = parseUntil("2024-01-02T03:04:05Z"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = parseUntil("yesterday"); err == nil {
+		t.Fatal("expected error for invalid until")
+	}
+}
+
+func TestPruneFilters(t *testing.T) {
+	if err := validatePruneFilters(Filters{"until": {"1h"}, "label": {"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, filters := range []Filters{{"until": {"soon"}}, {"status": {"exited"}}} {
+		if err := validatePruneFilters(filters); err == nil {
+			t.Fatalf("expected error for %v", filters)
+		}
+	}
+
+	old := &pruneItem{created: time.Now().Add(-48 * time.Hour), labels: map[string]string{"team": "infra"}}
+	recent := &pruneItem{created: time.Now()}
+	unknown := &pruneItem{} // 创建时间未知的对象视为足够早
+	filters := Filters{"until": {"24h"}}
+	if !matchFilters(old, filters, pruneFilters) || matchFilters(recent, filters, pruneFilters) || !matchFilters(unknown, filters, pruneFilters) {
+		t.Fatal("unexpected until match")
+	}
+	filters = Filters{"label": {"team=infra"}}
+	if !matchFilters(old, filters, pruneFilters) || matchFilters(recent, filters, pruneFilters) {
+		t.Fatal("unexpected label match")
+	}
+}
+
+func TestDanglingImage(t *testing.T) {
+	if !danglingImage.MatchString("e45df509543d6eb377221999d8c0693ebb4242d22c0253b33bafc24390d818d1") {
+		t.Fatal("expected image named after container id to be dangling")
+	}
+	if danglingImage.MatchString("busybox") || danglingImage.MatchString("e45df509543d") {
+		t.Fatal("expected named image not to be dangling")
+	}
+}
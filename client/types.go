@@ -0,0 +1,165 @@
+package client
+
+import (
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/network"
+)
+
+// ContainerConfig 创建容器的配置，也是 daemon 创建容器接口的请求体
+type ContainerConfig struct {
+	Image         string                   `json:"image"`
+	Cmd           []string                 `json:"cmd"`
+	Name          string                   `json:"name"`
+	Env           []string                 `json:"env"`
+	Volume        string                   `json:"volume"` // 数据卷，例如 /etc/conf:/etc/conf
+	Network       string                   `json:"network"`
+	PortMapping   []string                 `json:"portMapping"` // 端口映射，例如 8080:80
+	Resources     *resource.ResourceConfig `json:"resources"`
+	RestartPolicy container.RestartPolicy  `json:"restartPolicy"`
+	AutoRemove    bool                     `json:"autoRemove"` // 容器退出后自动删除，即 mydocker run --rm
+	Labels        map[string]string        `json:"labels"`
+	StopSignal    string                   `json:"stopSignal"`   // mydocker stop 时发送的信号，默认为 SIGTERM
+	CgroupParent  string                   `json:"cgroupParent"` // 容器 cgroup 的父 cgroup，默认为 DefaultCgroupParent，systemd 驱动下为 slice 名
+	CgroupDriver  string                   `json:"cgroupDriver"` // cgroup 驱动，cgroupfs 或 systemd，默认为 cgroupfs
+}
+
+// StopOptions 停止容器的选项
+type StopOptions struct {
+	Signal  string `json:"signal"`  // 停止信号，为空时使用创建容器时指定的 StopSignal，都没有指定时为 SIGTERM
+	Timeout *int   `json:"timeout"` // 等待容器退出的秒数，超时后发送 SIGKILL，nil 时为 DefaultStopTimeout，负数时一直等待
+}
+
+// ListOptions 列出容器的选项
+type ListOptions struct {
+	All     bool    `json:"all"`     // 是否包含已经停止的容器
+	Filters Filters `json:"filters"` // 过滤条件
+	Last    int     `json:"last"`    // 大于 0 时只返回最近创建的 Last 个容器，包括已经停止的容器
+}
+
+// ContainerDetail 容器的详细信息，即 mydocker inspect 的输出
+type ContainerDetail struct {
+	*container.Info
+	CgroupPaths map[string]string `json:"cgroupPaths"` // 容器 cgroup 在宿主机上的绝对路径，key 为 subsystem 名称
+	Mounts      []*Mount          `json:"mounts"`
+}
+
+// Mount 容器的挂载点
+type Mount struct {
+	Type        string `json:"type"`        // overlay、bind 等
+	Source      string `json:"source"`      // 宿主机上的路径
+	Destination string `json:"destination"` // 容器中的路径
+}
+
+// ExecResult 在容器中执行命令的结果
+type ExecResult struct {
+	ExitCode int    `json:"exitCode"`
+	Output   string `json:"output"` // 命令的 stdout 和 stderr
+}
+
+// ContainerStats 容器在某一时刻的资源使用情况，即 mydocker stats 的数据来源
+/*
+CPU 使用时间、网络和块设备 IO 都是累计值，需要用相邻两次的差值除以 Read 的间隔计算速率，见 CPUPercent
+*/
+type ContainerStats struct {
+	Id   string    `json:"id"`
+	Name string    `json:"name"`
+	Read time.Time `json:"read"` // 读取的时间
+	resource.Stats
+	// 内存限制，没有限制时为宿主机的内存总量，因此总是大于 0
+	MemoryLimit uint64                            `json:"memoryLimit"`
+	Networks    map[string]network.InterfaceStats `json:"networks"` // 容器中每块网卡的流量，key 为网卡名
+}
+
+// NetworkConfig 创建网络的配置
+type NetworkConfig struct {
+	Name   string            `json:"name"`
+	Driver string            `json:"driver"`
+	Subnet string            `json:"subnet"` // 网段，例如 192.168.0.0/24
+	Labels map[string]string `json:"labels"`
+}
+
+// Network 网络信息
+type Network struct {
+	Name    string            `json:"name"`
+	Driver  string            `json:"driver"`
+	Subnet  string            `json:"subnet"` // 网段，IP 为网关地址，例如 192.168.0.1/24
+	Labels  map[string]string `json:"labels"`
+	Created string            `json:"created"`
+}
+
+// Image 镜像信息
+type Image struct {
+	Name    string            `json:"name"`
+	Path    string            `json:"path"` // 镜像 tar 包的路径
+	Size    int64             `json:"size"`
+	Created string            `json:"created"`
+	Labels  map[string]string `json:"labels"` // commit 时指定的标签
+}
+
+// ReconcileReport Reconcile 修正的容器和清理的资源
+type ReconcileReport struct {
+	Containers []string `json:"containers"` // 进程已经不存在、被标记为退出的容器 id
+	Networks   []string `json:"networks"`   // 断开了遗留网络连接的容器 id
+	Endpoints  []string `json:"endpoints"`  // 删除的 veth
+	Workspaces []string `json:"workspaces"` // 删除的 overlay 目录对应的容器 id
+	Cgroups    []string `json:"cgroups"`    // 删除的 cgroup 路径
+}
+
+// DiskUsage mydocker 占用的磁盘空间，即 mydocker system df 的输出
+type DiskUsage struct {
+	Images     []*ImageUsage     `json:"images"`
+	Containers []*ContainerUsage `json:"containers"`
+	Volumes    []*VolumeUsage    `json:"volumes"`
+}
+
+// ImageUsage 镜像占用的磁盘空间
+type ImageUsage struct {
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`       // 镜像 tar 包和元数据的大小
+	Containers int    `json:"containers"` // 使用该镜像的容器数，包括已经停止的容器
+}
+
+// ContainerUsage 容器占用的磁盘空间
+type ContainerUsage struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+	Status    string `json:"status"`
+	Size      int64  `json:"size"`      // 可写层 upper 和 work 目录的大小
+	LowerSize int64  `json:"lowerSize"` // 从镜像解压出来的 lower 目录的大小
+	LogSize   int64  `json:"logSize"`   // 日志文件的大小
+}
+
+// VolumeUsage 数据卷占用的磁盘空间
+type VolumeUsage struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Containers int    `json:"containers"` // 挂载该数据卷的容器数，包括已经停止的容器
+	Managed    bool   `json:"managed"`    // 是否由 mydocker 创建，只有由 mydocker 创建的数据卷才会被 prune 删除
+}
+
+// PruneOptions 清理的选项
+type PruneOptions struct {
+	All     bool    `json:"all"`     // 删除全部没有被容器使用的镜像，否则只删除以容器 id 命名的悬空镜像
+	Volumes bool    `json:"volumes"` // 同时删除没有被容器使用、由 mydocker 创建的数据卷
+	Filters Filters `json:"filters"` // 支持 until 和 label
+}
+
+// EventsOptions mydocker events 的选项
+type EventsOptions struct {
+	Since   string  `json:"since"`   // 只返回这个时间之后的事件，可以是 10m 这样的时长，也可以是时间戳，为空时只返回新发生的事件
+	Until   string  `json:"until"`   // 只返回这个时间之前的事件，为空时持续等待新的事件
+	Filters Filters `json:"filters"` // 支持 type、event、container、network、image
+}
+
+// PruneReport 清理的对象和释放的空间
+type PruneReport struct {
+	Containers     []string `json:"containers"`
+	Networks       []string `json:"networks"`
+	Images         []string `json:"images"`
+	Volumes        []string `json:"volumes"`
+	SpaceReclaimed int64    `json:"spaceReclaimed"` // 单位为字节
+}
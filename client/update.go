@@ -0,0 +1,120 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/container"
+)
+
+// updateResources 修改容器的资源限制，update 中为零值的项保持不变
+/*
+1）将 update 合并到容器记录的资源限制中，并检查合并后的限制
+
+2）容器的 cgroup 还存在时，通过 CgroupManager.Set 写入新的限制。没有 swap 时内存限制不能低于当前使用量，
+否则内核只能通过 OOM killer 杀死容器中的进程来满足限制
+
+3）将新的限制记录到容器信息中，已经停止的容器只修改记录，重启时使用新的限制
+
+和 pause 一样，与其他容器共用 cgroup 时会同时修改其他容器的限制，这种情况直接拒绝
+*/
+func updateResources(containerId string, update *resource.ResourceConfig) error {
+	if err := checkCgroupExclusive(containerId, "update"); err != nil {
+		return err
+	}
+	_, err := updateContainer(containerId, func(info *container.Info) error {
+		merged := info.Resources.Merge(update)
+		if err := merged.Validate(); err != nil {
+			return &Error{Kind: ErrInvalidParameter, Err: err}
+		}
+		if cgroupAlive(info) {
+			if info.CgroupPath == "" {
+				return conflict("container %s has no cgroup", containerId)
+			}
+			manager := cgroups.NewManager(info.CgroupDriver, info.CgroupPath)
+			if update.MemoryLimit != "" {
+				if err := checkMemoryUsage(manager, merged); err != nil {
+					return err
+				}
+			}
+			if err := manager.Set(merged); err != nil {
+				return errors.Join(err, fmt.Errorf("update cgroup %s failed", info.CgroupPath))
+			}
+		}
+		info.Resources = merged
+		return nil
+	})
+	return err
+}
+
+// cgroupAlive 判断容器的 cgroup 是否存在，重启中的容器在重新启动时才创建 cgroup
+func cgroupAlive(info *container.Info) bool {
+	switch info.Status {
+	case container.CREATED, container.RUNNING, container.PAUSED:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkMemoryUsage 没有 swap 可用时，新的内存限制不能低于 cgroup 当前使用的内存
+func checkMemoryUsage(manager cgroups.CgroupManager, res *resource.ResourceConfig) error {
+	limit, err := resource.ParseMemory(res.MemoryLimit)
+	if err != nil || limit == resource.MemoryUnlimited {
+		return err
+	}
+	swapOff := false
+	if res.MemorySwap != "" {
+		swap, err := resource.ParseMemory(res.MemorySwap)
+		if err != nil {
+			return err
+		}
+		swapOff = swap == limit
+	}
+	if !swapOff {
+		total, err := hostMeminfo("SwapTotal")
+		if err != nil {
+			return err
+		}
+		swapOff = total == 0
+	}
+	if !swapOff {
+		return nil
+	}
+
+	usage, err := manager.MemoryUsage()
+	if err != nil {
+		return errors.Join(err, errors.New("read memory usage failed"))
+	}
+	if uint64(limit) < usage {
+		return conflict("memory limit %s is below current usage %d bytes and swap is disabled", res.MemoryLimit, usage)
+	}
+	return nil
+}
+
+// hostMeminfo 从 /proc/meminfo 中读取宿主机的内存信息，例如 MemTotal、SwapTotal，单位是字节
+func hostMeminfo(key string) (uint64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 形如 SwapTotal:       2097148 kB
+		if value, ok := strings.CutPrefix(scanner.Text(), key+":"); ok {
+			kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+			return kb * 1024, err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s not found in /proc/meminfo", key)
+}
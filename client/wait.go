@@ -0,0 +1,50 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+)
+
+const (
+	waitPollInterval = 100 * time.Millisecond // mydocker wait 轮询容器状态的间隔
+	waitRecordGrace  = time.Second            // 容器进程退出后等待父进程记录退出码的时间
+)
+
+// waitContainer 阻塞直到容器退出，返回容器进程的退出码
+/*
+容器进程的父进程(前台运行的 mydocker run 或者 shim)在进程退出后会把状态置为 exited 并记录退出码，
+这里轮询容器信息直到状态变为 exited 或者 stopped。
+
+如果容器进程已经不存在，但状态一直没有变为 exited，说明父进程也已经不在了，没有进程为它记录退出码，返回错误。
+*/
+func waitContainer(containerId string) (int, error) {
+	var exitedAt time.Time
+	for {
+		containerInfo, err := lookupContainer(containerId)
+		if err != nil {
+			return 0, err
+		}
+
+		// 正在等待重启的容器也已经退出过一次了，和 docker wait 一样返回这次的退出码。
+		// 没有父进程记录退出码的容器被 mydocker stop 停止后为 stopped 状态，退出码为 ExitCodeUnknown
+		switch containerInfo.Status {
+		case container.Exit, container.RESTARTING, container.STOP:
+			return containerInfo.ExitCode, nil
+		}
+
+		// 进程退出后父进程还需要释放网络等资源才会记录退出码，给它留一点时间
+		pid, _ := strconv.Atoi(containerInfo.Pid)
+		if !container.ProcessExists(pid) {
+			if exitedAt.IsZero() {
+				exitedAt = time.Now()
+			} else if time.Since(exitedAt) > waitRecordGrace {
+				return 0, fmt.Errorf("container %s exited, exit code not recorded", containerId)
+			}
+		}
+
+		time.Sleep(waitPollInterval)
+	}
+}
@@ -3,6 +3,7 @@ package constant
 const (
 	Perm0777 = 0777 // 用户具、组用户和其它用户都有读/写/执行权限
 	Perm0755 = 0755 // 用户具有读/写/执行权限，组用户和其它用户具有读写权限；
+	Perm0660 = 0660 // 用户和组用户具有读写权限，其它用户没有权限；
 	Perm0644 = 0644 // 用户具有读写权限，组用户和其它用户具有只读权限；
 	Perm0622 = 0622 // 用户具有读/写权限，组用户和其它用户具只写权限；
 )
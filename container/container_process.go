@@ -1,41 +1,70 @@
 package container
 
 import (
+	"errors"
 	"fmt"
-	"hash/fnv"
 	"os"
 	"os/exec"
-	"strconv"
 	"syscall"
-	"time"
 
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
 	"github.com/NatsuiroGinga/mydocker/constant"
 	"github.com/NatsuiroGinga/mydocker/utils"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 )
 
 const (
+	CREATED       = "created"
 	RUNNING       = "running"
+	RESTARTING    = "restarting"
+	PAUSED        = "paused"
 	STOP          = "stopped"
 	Exit          = "exited"
 	InfoLoc       = "/var/lib/mydocker/containers/"
 	InfoLocFormat = InfoLoc + "%s/"
 	ConfigName    = "config.json"
 	LogFile       = "%s-json.log"
-	MetaFile      = "/var/lib/mydocker/containers/meta.json"
 )
 
 type Info struct {
-	Pid         string   `json:"pid"`         // 容器的 init 进程在宿主机上的PID
-	Id          string   `json:"id"`          // 容器 ID
-	Name        string   `json:"name"`        // 容器名
-	Command     string   `json:"command"`     // 容器内 init 运行命令
-	CreatedTime string   `json:"createTime"`  // 创建时间
-	Status      string   `json:"status"`      // 容器的状态
-	Volume      string   `json:"volume"`      // 容器挂载的 volume
-	NetworkName string   `json:"networkName"` // 容器所在的网络
-	PortMapping []string `json:"portmapping"` // 端口映射
-	IP          string   `json:"ip"`          // ip地址
+	Pid         string            `json:"pid"`         // 容器的 init 进程在宿主机上的PID
+	StartTime   uint64            `json:"startTime"`   // init 进程的启动时间，和 Pid 一起判断进程是否还是原来的进程
+	Id          string            `json:"id"`          // 容器 ID
+	Name        string            `json:"name"`        // 容器名
+	Command     string            `json:"command"`     // 容器内 init 运行命令
+	Args        []string          `json:"args"`        // 容器内 init 运行命令的参数，daemon 启动时重新启动容器需要
+	Image       string            `json:"image"`       // 创建容器使用的镜像
+	Env         []string          `json:"env"`         // 用户指定的环境变量
+	CreatedTime string            `json:"createTime"`  // 创建时间
+	Status      string            `json:"status"`      // 容器的状态
+	Volume      string            `json:"volume"`      // 容器挂载的 volume
+	NetworkName string            `json:"networkName"` // 容器所在的网络
+	PortMapping []string          `json:"portmapping"` // 端口映射
+	IP          string            `json:"ip"`          // ip地址
+	Bundle      string            `json:"bundle"`      // OCI bundle 目录, 通过 mydocker create --bundle 创建的容器才有
+	Labels      map[string]string `json:"labels"`      // 用户指定的标签
+	ExitCode    int               `json:"exitCode"`    // 容器进程的退出码，被信号杀死时为 128+信号值
+	FinishedAt  string            `json:"finishedAt"`  // 容器进程退出的时间
+	OOMKilled   bool              `json:"oomKilled"`   // 容器进程是否被 OOM killer 杀死
+
+	// 本次运行中内存达到限制、触发 OOM killer 的次数和被杀死的进程数，被杀死的不一定是 init 进程，容器可能继续运行
+	OOMCount     uint64 `json:"oomCount"`
+	OOMKillCount uint64 `json:"oomKillCount"`
+
+	Resources    *resource.ResourceConfig `json:"resources"`    // 资源限制，没有限制时为 nil
+	CgroupPath   string                   `json:"cgroupPath"`   // 容器 cgroup 相对于 cgroup 根目录的路径
+	CgroupDriver string                   `json:"cgroupDriver"` // 创建 cgroup 使用的驱动，为空时为 cgroupfs
+
+	RestartPolicy   RestartPolicy `json:"restartPolicy"`   // 重启策略
+	RestartCount    int           `json:"restartCount"`    // 按照重启策略重启的次数
+	ManuallyStopped bool          `json:"manuallyStopped"` // 是否被 mydocker stop 停止，停止的容器不会再被重启
+	StopSignal      string        `json:"stopSignal"`      // mydocker stop 时发送的信号，为空时为 SIGTERM
+
+	// 等待容器进程退出并按照重启策略重启容器的进程，即前台运行的 mydocker run 或者 shim，
+	// 它不存在时 restarting 状态的容器不会再被重启
+	SupervisorPid       int    `json:"supervisorPid"`
+	SupervisorStartTime uint64 `json:"supervisorStartTime"`
 }
 
 // NewParentProcess 创建并返回一个新进程. 注意: 在本函数内进程尚未启动
@@ -46,14 +75,20 @@ type Info struct {
 3.下面的clone参数就是去fork出来一个新进程，并且使用了namespace隔离新创建的进程和外部环境。
 4.如果用户指定了-it参数，就需要把当前进程的输入输出导入到标准输入输出上
 */
-func NewParentProcess(tty bool, containerId, imageName, volume string, envs []string) (*exec.Cmd, *os.File) {
-	// 创建匿名管道用于传递参数，将readPipe作为子进程的ExtraFiles，子进程从readPipe中读取参数
-	// 父进程中则通过writePipe将参数写入管道
-	readPipe, writePipe, err := os.Pipe()
+func NewParentProcess(tty bool, containerId string) (*exec.Cmd, *os.File) {
+	// 创建 socketpair 用于传递参数，将 childPipe 作为子进程的ExtraFiles，子进程从 childPipe 中读取参数并回写初始化结果
+	// 父进程中则通过 parentPipe 将参数写入，并等待子进程初始化完成
+	parentPipe, childPipe, err := newSyncPipe()
 	if err != nil {
 		logrus.Errorf("New pipe error %v", err)
 		return nil, nil
 	}
+	// exec fifo 用于阻塞 init 进程，直到 mydocker start
+	fifo, err := CreateExecFifo(containerId)
+	if err != nil {
+		logrus.Errorf("NewParentProcess %v", err)
+		return nil, nil
+	}
 
 	cmd := exec.Command("/proc/self/exe", "init")
 	cmd.SysProcAttr = &syscall.SysProcAttr{
@@ -65,40 +100,132 @@ func NewParentProcess(tty bool, containerId, imageName, volume string, envs []st
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 	} else { // 对于后台运行容器，将 stdout、stderr 重定向到日志文件中，便于后续查看
-		dirPath := fmt.Sprintf(InfoLocFormat, containerId)
-		if err := os.MkdirAll(dirPath, constant.Perm0622); err != nil {
-			logrus.Errorf("NewParentProcess mkdir %s error %v", dirPath, err)
-			return nil, nil
-		}
-		stdLogFilePath := dirPath + GetLogfile(containerId)
-		stdLogFile, err := os.Create(stdLogFilePath)
+		stdLogFile, err := newLogFile(containerId)
 		if err != nil {
-			logrus.Errorf("NewParentProcess create file %s error %v", stdLogFilePath, err)
+			logrus.Errorf("NewParentProcess %v", err)
 			return nil, nil
 		}
-		cmd.Stdout = stdLogFile
-		cmd.Stderr = stdLogFile
+		// 容器进程不直接持有日志文件，而是由 exec 创建管道，父进程(shim)负责把输出写入日志文件，
+		// cmd.Wait 会等到容器的输出全部写完才返回
+		cmd.Stdout = &logWriter{stdLogFile}
+		cmd.Stderr = cmd.Stdout
 	}
 
-	// 指定 cmd 的工作目录为我们前面准备好的用于存放busybox rootfs的目录
-	NewWorkSpace(containerId, imageName, volume)
+	// 指定 cmd 的工作目录为调用方通过 NewWorkSpace 准备好的用于存放busybox rootfs的目录
+	cmd.ExtraFiles = []*os.File{childPipe, fifo}
+	cmd.Dir = utils.GetMerged(containerId)
+
+	return cmd, parentPipe
+}
 
-	cmd.Env = append(os.Environ(), envs...)
-	cmd.ExtraFiles = []*os.File{readPipe}
-	if len(envs) != 0 {
-		cmd.Env = append(cmd.Env, envs...)
+// NewBundleParentProcess 根据 OCI bundle 创建 init 进程. 注意: 在本函数内进程尚未启动
+/*
+和 NewParentProcess 的区别在于：
+
+1）namespace 由 config.json 中的 linux.namespaces 决定，而不是写死的 clone flags
+
+2）rootfs 直接使用 bundle 中的 root.path，不再准备 overlayfs
+
+3）命令、环境变量、挂载等配置由父进程通过 BundleInitConfig 生成后经 sync socket 发送给 init 进程
+*/
+func NewBundleParentProcess(containerId, bundle string, spec *specs.Spec) (*exec.Cmd, *os.File, error) {
+	cloneFlags, err := SpecCloneFlags(spec)
+	if err != nil {
+		return nil, nil, err
 	}
-	cmd.Dir = utils.GetMerged(containerId)
 
-	return cmd, writePipe
+	cmd := exec.Command("/proc/self/exe", "init")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: cloneFlags,
+	}
+	if SpecHasNamespace(spec, specs.UserNamespace) {
+		for _, m := range spec.Linux.UIDMappings {
+			cmd.SysProcAttr.UidMappings = append(cmd.SysProcAttr.UidMappings,
+				syscall.SysProcIDMap{ContainerID: int(m.ContainerID), HostID: int(m.HostID), Size: int(m.Size)})
+		}
+		for _, m := range spec.Linux.GIDMappings {
+			cmd.SysProcAttr.GidMappings = append(cmd.SysProcAttr.GidMappings,
+				syscall.SysProcIDMap{ContainerID: int(m.ContainerID), HostID: int(m.HostID), Size: int(m.Size)})
+		}
+	}
+
+	// create 之后 mydocker 进程就退出了，因此容器的输出统一重定向到日志文件中
+	stdLogFile, err := newLogFile(containerId)
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd.Stdout = stdLogFile
+	cmd.Stderr = stdLogFile
+
+	parentPipe, childPipe, err := newSyncPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	fifo, err := CreateExecFifo(containerId)
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd.ExtraFiles = []*os.File{childPipe, fifo}
+	cmd.Dir = SpecRootfs(bundle, spec)
+
+	return cmd, parentPipe, nil
+}
+
+// defaultMounts 镜像容器默认挂载的文件系统
+var defaultMounts = []specs.Mount{
+	{
+		// mount /proc，以便容器内通过 ps 等命令查看进程信息
+		Destination: "/proc",
+		Type:        "proc",
+		Source:      "proc",
+		Options:     []string{"noexec", "nosuid", "nodev"},
+	},
+	{
+		// tmpfs 是基于内存的文件系统，使用 RAM、swap 分区来存储
+		Destination: "/dev",
+		Type:        "tmpfs",
+		Source:      "tmpfs",
+		Options:     []string{"nosuid", "strictatime", "mode=755"},
+	},
+}
+
+// NewInitConfig 根据命令行参数生成镜像容器的 InitConfig
+//
+// 环境变量在宿主机环境变量的基础上追加 -e 指定的部分，hostname 默认为容器 id 的前 12 位
+func NewInitConfig(containerId string, comArray, envs []string) *InitConfig {
+	return &InitConfig{
+		Args:     comArray,
+		Env:      append(os.Environ(), envs...),
+		Cwd:      "/",
+		Hostname: ShortID(containerId),
+		Rootfs:   utils.GetMerged(containerId),
+		Mounts:   defaultMounts,
+	}
+}
+
+// newLogFile 打开容器的日志文件 /var/lib/mydocker/containers/{containerID}/{containerID}-json.log
+//
+// 以追加的方式打开，容器重启后保留之前的输出
+func newLogFile(containerId string) (*os.File, error) {
+	dirPath := fmt.Sprintf(InfoLocFormat, containerId)
+	if err := os.MkdirAll(dirPath, constant.Perm0622); err != nil {
+		return nil, errors.Join(err, fmt.Errorf("mkdir %s failed", dirPath))
+	}
+	stdLogFilePath := dirPath + GetLogfile(containerId)
+	stdLogFile, err := os.OpenFile(stdLogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, constant.Perm0644)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("open file %s failed", stdLogFilePath))
+	}
+	return stdLogFile, nil
+}
+
+// logWriter 包装容器的日志文件，使 exec.Cmd 不会把文件直接交给子进程
+type logWriter struct {
+	file *os.File
 }
 
-// GenerateContainerID 根据容器名生成容器id
-func GenerateContainerID(seed string) string {
-	generator := fnv.New32()
-	generator.Write([]byte(seed))
-	generator.Write([]byte(time.Now().String()))
-	return strconv.Itoa(int(generator.Sum32()))
+func (w *logWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
 }
 
 // GetLogfile build logfile name by containerId
@@ -0,0 +1,112 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/constant"
+	"golang.org/x/sys/unix"
+)
+
+// ExecFifoName 容器 create 之后、start 之前用于阻塞 init 进程的 fifo 文件名
+const ExecFifoName = "exec.fifo"
+
+// GetExecFifoPath 返回容器 exec fifo 的路径
+func GetExecFifoPath(containerId string) string {
+	return path.Join(fmt.Sprintf(InfoLocFormat, containerId), ExecFifoName)
+}
+
+// CreateExecFifo 创建 exec fifo 并以 O_PATH 方式打开，作为 ExtraFiles 传递给 init 进程
+/*
+create 和 start 之间的同步方式和 runc 一样：
+
+1）create 时创建 fifo，init 进程完成初始化后以写方式打开它，由于没有读端，open 会一直阻塞
+
+2）start 时以读方式打开 fifo，init 进程的 open 返回，写入一个字节后 exec 用户进程
+*/
+func CreateExecFifo(containerId string) (*os.File, error) {
+	dirPath := fmt.Sprintf(InfoLocFormat, containerId)
+	if err := os.MkdirAll(dirPath, constant.Perm0622); err != nil {
+		return nil, errors.Join(err, fmt.Errorf("mkdir %s failed", dirPath))
+	}
+
+	fifoPath := GetExecFifoPath(containerId)
+	if err := unix.Mkfifo(fifoPath, constant.Perm0622); err != nil {
+		return nil, errors.Join(err, fmt.Errorf("mkfifo %s failed", fifoPath))
+	}
+
+	fd, err := unix.Open(fifoPath, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("open fifo %s failed", fifoPath))
+	}
+	return os.NewFile(uintptr(fd), fifoPath), nil
+}
+
+// waitExecFifo 在 init 进程中调用，阻塞直到 mydocker start 打开 fifo 的读端
+//
+// 由于 pivot_root 之后已经看不到宿主机上的 fifo 路径，因此通过 /proc/self/fd 重新打开继承来的 O_PATH fd
+func waitExecFifo(fd int) error {
+	fifoPath := fmt.Sprintf("/proc/self/fd/%d", fd)
+	fifo, err := os.OpenFile(fifoPath, os.O_WRONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("open exec fifo %s failed", fifoPath))
+	}
+	defer fifo.Close()
+
+	if _, err = fifo.Write([]byte("0")); err != nil {
+		return errors.Join(err, errors.New("write exec fifo failed"))
+	}
+	syscall.Close(fd)
+	return nil
+}
+
+// ReleaseExecFifo 打开 fifo 的读端，让阻塞在 fifo 上的 init 进程继续执行用户命令
+//
+// 如果 init 进程在打开 fifo 之前就已经退出，open 会永远阻塞，因此这里同时轮询进程是否存活
+func ReleaseExecFifo(containerId string, pid int) error {
+	fifoPath := GetExecFifoPath(containerId)
+
+	type result struct {
+		file *os.File
+		err  error
+	}
+	opened := make(chan result, 1)
+	go func() {
+		f, err := os.OpenFile(fifoPath, os.O_RDONLY, 0)
+		opened <- result{f, err}
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var fifo *os.File
+	for fifo == nil {
+		select {
+		case r := <-opened:
+			if r.err != nil {
+				return errors.Join(r.err, fmt.Errorf("open exec fifo %s failed", fifoPath))
+			}
+			fifo = r.file
+		case <-ticker.C:
+			if !ProcessExists(pid) {
+				return fmt.Errorf("container init process %d exited before start", pid)
+			}
+		}
+	}
+	defer fifo.Close()
+
+	content, err := io.ReadAll(fifo)
+	if err != nil {
+		return errors.Join(err, errors.New("read exec fifo failed"))
+	}
+	if len(content) == 0 {
+		return errors.New("container init process exited before start")
+	}
+
+	return os.Remove(fifoPath)
+}
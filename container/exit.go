@@ -0,0 +1,23 @@
+package container
+
+import (
+	"os"
+	"syscall"
+)
+
+// ExitCodeUnknown 容器进程已经不存在，但是没有记录到退出码，例如宿主机重启或者 shim 进程被杀死
+const ExitCodeUnknown = 255
+
+// ExitCode 根据进程的退出状态计算容器的退出码
+//
+// 与 shell 的约定一致：正常退出时为进程的退出码，被信号杀死时为 128+信号值
+func ExitCode(state *os.ProcessState) int {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return state.ExitCode()
+	}
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
@@ -0,0 +1,69 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+)
+
+// RunHooks 依次执行 OCI hooks，容器的 state 以 json 格式通过 stdin 传递给 hook
+/*
+按照 runtime-spec 的约定:
+
+1）hook.Path 为可执行文件的绝对路径，hook.Args 和 execve 的 argv 语义相同，即 Args[0] 为程序名
+
+2）hook.Timeout 为超时时间，单位秒，超时后 hook 进程会被 kill
+
+3）任意一个 hook 执行失败都会返回错误，后续 hook 不再执行
+*/
+func RunHooks(hooks []specs.Hook, state *specs.State) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return errors.Join(err, errors.New("marshal container state failed"))
+	}
+
+	for _, hook := range hooks {
+		if err = runHook(hook, stateBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runHook(hook specs.Hook, stateBytes []byte) error {
+	ctx := context.Background()
+	if hook.Timeout != nil && *hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(*hook.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, hook.Path)
+	if len(hook.Args) > 0 {
+		cmd.Args = hook.Args
+	}
+	cmd.Env = hook.Env
+	cmd.Stdin = bytes.NewReader(stateBytes)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	logrus.Infof("run hook: %s", cmd.String())
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("hook %s timed out after %ds", hook.Path, *hook.Timeout)
+		}
+		return errors.Join(err, fmt.Errorf("hook %s failed: %s", hook.Path, stderr.String()))
+	}
+	return nil
+}
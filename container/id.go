@@ -0,0 +1,97 @@
+package container
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+)
+
+// 容器 id 的长度，和 docker 一样为 32 字节随机数的 16 进制表示
+const (
+	idBytes    = 32
+	shortIDLen = 12
+)
+
+// GenerateContainerID 生成 64 位 16 进制的随机容器 id
+/*
+和 docker 一样，前 12 位全部是数字的 id 会被丢弃重新生成，避免短 id 被当作数字处理；
+生成的 id 在容器信息目录中已经存在时也重新生成，保证不会和已有的容器冲突。
+*/
+func GenerateContainerID() string {
+	b := make([]byte, idBytes)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			panic(err) // crypto/rand 失败说明系统环境有问题，无法继续
+		}
+		id := hex.EncodeToString(b)
+		if _, err := strconv.ParseInt(ShortID(id), 10, 64); err == nil {
+			continue
+		}
+		if _, err := os.Stat(fmt.Sprintf(InfoLocFormat, id)); err == nil {
+			continue
+		}
+		return id
+	}
+}
+
+// ValidateID 检查用户指定的容器 id 是否合法，id 会用来拼接容器信息和 cgroup 的路径，规则和容器名相同
+func ValidateID(id string) error {
+	if !validName.MatchString(id) {
+		return fmt.Errorf("invalid container id %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", id)
+	}
+	return nil
+}
+
+// ShortID 返回容器 id 的前 12 位，用于展示和容器的 hostname
+func ShortID(id string) string {
+	if len(id) <= shortIDLen {
+		return id
+	}
+	return id[:shortIDLen]
+}
+
+// GenerateName 为没有指定 --name 的容器生成形如 focused_turing 的容器名
+//
+// retry 大于 0 时说明之前生成的名称已经被使用了，在末尾追加随机数字
+func GenerateName(retry int) string {
+	name := fmt.Sprintf("%s_%s", randomItem(adjectives), randomItem(surnames))
+	if retry > 0 {
+		name += strconv.Itoa(int(randomInt(10)))
+	}
+	return name
+}
+
+func randomItem(items []string) string {
+	return items[randomInt(int64(len(items)))]
+}
+
+func randomInt(n int64) int64 {
+	i, err := rand.Int(rand.Reader, big.NewInt(n))
+	if err != nil {
+		panic(err)
+	}
+	return i.Int64()
+}
+
+// adjectives、surnames 生成容器名使用的形容词和科学家的姓
+var (
+	adjectives = []string{
+		"admiring", "affectionate", "agitated", "amazing", "angry", "awesome", "blissful", "bold",
+		"brave", "busy", "charming", "clever", "cool", "dazzling", "determined", "eager",
+		"ecstatic", "elegant", "epic", "focused", "friendly", "gallant", "gifted", "goofy",
+		"happy", "hopeful", "inspiring", "jolly", "keen", "kind", "laughing", "loving",
+		"modest", "nervous", "nice", "optimistic", "peaceful", "pensive", "quirky", "relaxed",
+		"serene", "sharp", "stoic", "sweet", "tender", "trusting", "vibrant", "wizardly",
+		"xenodochial", "youthful", "zealous",
+	}
+	surnames = []string{
+		"archimedes", "babbage", "bell", "bohr", "curie", "darwin", "dijkstra", "einstein",
+		"euclid", "euler", "faraday", "fermi", "feynman", "gauss", "goodall", "hawking",
+		"heisenberg", "hopper", "hypatia", "kepler", "knuth", "lamport", "lovelace", "maxwell",
+		"mendel", "newton", "noether", "pascal", "pasteur", "planck", "ritchie", "shannon",
+		"tesla", "thompson", "torvalds", "turing", "wozniak", "yalow",
+	}
+)
@@ -0,0 +1,53 @@
+package container
+
+import (
+	"encoding/hex"
+	"regexp"
+	"testing"
+)
+
+func TestGenerateContainerID(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := GenerateContainerID()
+		if len(id) != 64 {
+			t.Fatalf("expected 64 characters, got %q", id)
+		}
+		if _, err := hex.DecodeString(id); err != nil {
+			t.Fatalf("expected hex id, got %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicated id %s", id)
+		}
+		seen[id] = true
+		if short := ShortID(id); len(short) != 12 || short != id[:12] {
+			t.Fatalf("unexpected short id %q of %q", short, id)
+		}
+	}
+	if ShortID("abc") != "abc" {
+		t.Fatal("short ids should be kept as is")
+	}
+}
+
+func TestGenerateName(t *testing.T) {
+	pattern := regexp.MustCompile(`^[a-z]+_[a-z]+$`)
+	if name := GenerateName(0); !pattern.MatchString(name) || ValidateName(name) != nil {
+		t.Fatalf("unexpected name %q", name)
+	}
+	if name := GenerateName(1); !regexp.MustCompile(`^[a-z]+_[a-z]+[0-9]$`).MatchString(name) {
+		t.Fatalf("unexpected name %q", name)
+	}
+}
+
+func TestValidateID(t *testing.T) {
+	for _, id := range []string{"c0", "my-bundle_1.0"} {
+		if err := ValidateID(id); err != nil {
+			t.Fatalf("%s should be valid: %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "..", "../../x", "a/b", ".hidden"} {
+		if err := ValidateID(id); err == nil {
+			t.Fatalf("%q should be invalid", id)
+		}
+	}
+}
@@ -3,14 +3,13 @@ package container
 import (
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"syscall"
 
-	"github.com/NatsuiroGinga/mydocker/utils"
+	"github.com/NatsuiroGinga/mydocker/constant"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,92 +18,153 @@ import (
 这里的init函数是在容器内部执行的，也就是说，代码执行到这里后，容器所在的进程其实就已经创建出来了，
 这是本容器执行的第一一个进程。
 使用mount先去挂载proc文件系统，以便后面通过ps等系统命令去查看当前进程资源的情况。
+
+所有配置都通过 sync socket 上的 InitConfig 传递，初始化过程中的任何错误都会回传给父进程，
+由父进程决定 mydocker run/create 的退出码，而不是只打印在容器日志里。
 */
 func RunContainerInitProcess() error {
-	// 从 pipe 中读取命令
-	cmdArray := readUserCommand()
-	if len(cmdArray) == 0 {
-		return errors.New("run container get user command error, cmdArray is nil")
+	/*
+		uintptr(3）就是指 index 为3的文件描述符，也就是传递进来的 socketpair 的另一端，至于为什么是3，具体解释如下：
+		因为每个进程默认都会有3个文件描述符，分别是标准输入、标准输出、标准错误。这3个是子进程一创建的时候就会默认带着的，
+		前面通过ExtraFiles方式带过来的 sync socket 理所当然地就成为了第4个。
+		在进程中可以通过index方式读取对应的文件，比如
+		index0：标准输入
+		index1：标准输出
+		index2：标准错误
+		index3：带过来的第一个FD，也就是 sync socket
+		index4：带过来的第二个FD，也就是 exec fifo
+		由于可以带多个FD过来，所以这里的3就不是固定的了。
+		比如像这样：cmd.ExtraFiles = []*os.File{a,b,c,readPipe} 这里带了4个文件过来，分别的index就是3,4,5,6
+		那么我们的 readPipe 就是 index6,读取时就要像这样：pipe := os.NewFile(uintptr(6), "pipe")
+	*/
+	syncPipe := os.NewFile(uintptr(syncFdIndex), "pipe")
+	// 父进程设置好 cgroup 和网络之后才会发送配置，因此读到配置之前 init 进程什么都不做
+	config, err := readInitConfig(syncPipe)
+	if err != nil {
+		reportError(syncPipe, err)
+		return err
 	}
-	// 挂载文件系统
-	setUpMount()
 
-	path, err := exec.LookPath(cmdArray[0])
+	path, err := initContainer(config)
 	if err != nil {
-		logrus.Errorf("exec loop path error %v", err)
+		logrus.Errorf("init container error %v", err)
+		reportError(syncPipe, err)
 		return err
 	}
 
-	logrus.Infof("find path [%s]", path)
+	// 通知父进程初始化完成，然后阻塞在 exec fifo 上等待 mydocker start
+	if err = notifyReady(syncPipe); err != nil {
+		return err
+	}
+	if err = waitExecFifo(execFifoFdIndex); err != nil {
+		return err
+	}
+
+	if err = setUser(config.User); err != nil {
+		return err
+	}
 
-	if err := syscall.Exec(path, cmdArray, os.Environ()); err != nil {
+	if err = syscall.Exec(path, config.Args, config.Env); err != nil {
 		logrus.Errorf("%s", "RunContainerInitProcess exec :"+err.Error())
+		return err
 	}
 	return nil
 }
 
-const fdIndex = 3
+// initContainer 按照 InitConfig 初始化容器，返回用户命令的绝对路径
+/*
+1）按照 mounts 挂载文件系统，然后 pivot_root 到 rootfs
 
-// 子进程读数据, 子进程启动后，首先要找到前面通过ExtraFiles 传递过来的 readPipe FD，然后才是数据读取
-//
-// 1）获取 readPipe FD
-//
-// 2）读取数据
-func readUserCommand() []string {
+2）设置 hostname、rlimits 和工作目录
 
-	/*
-		uintptr(3）就是指 index 为3的文件描述符，也就是传递进来的管道的另一端，至于为什么是3，具体解释如下：
-		因为每个进程默认都会有3个文件描述符，分别是标准输入、标准输出、标准错误。这3个是子进程一创建的时候就会默认带着的，
-		前面通过ExtraFiles方式带过来的 readPipe 理所当然地就成为了第4个。
-		在进程中可以通过index方式读取对应的文件，比如
-		index0：标准输入
-		index1：标准输出
-		index2：标准错误
-		index3：带过来的第一个FD，也就是readPipe
-		由于可以带多个FD过来，所以这里的3就不是固定的了。
-		比如像这样：cmd.ExtraFiles = []*os.File{a,b,c,readPipe} 这里带了4个文件过来，分别的index就是3,4,5,6
-		那么我们的 readPipe 就是 index6,读取时就要像这样：pipe := os.NewFile(uintptr(6), "pipe")
-	*/
-	pipe := os.NewFile(uintptr(fdIndex), "pipe")
-	msg, err := io.ReadAll(pipe)
-	if err != nil {
-		logrus.Errorf("init read pipe error %v", err)
-		return nil
+3）替换环境变量并查找用户命令，命令不存在时在这里就返回错误，而不是等到 start 之后
+*/
+func initContainer(config *InitConfig) (string, error) {
+	if err := setUpMount(config); err != nil {
+		return "", err
 	}
 
-	msgStr := utils.Bytes2String(msg)
-	return strings.Split(msgStr, " ")
+	if config.Hostname != "" {
+		if err := syscall.Sethostname([]byte(config.Hostname)); err != nil {
+			return "", errors.Join(err, fmt.Errorf("set hostname %s failed", config.Hostname))
+		}
+	}
+
+	for _, rlimit := range config.Rlimits {
+		if err := setRlimit(rlimit); err != nil {
+			return "", err
+		}
+	}
+
+	if config.Cwd != "" {
+		if err := os.MkdirAll(config.Cwd, constant.Perm0755); err != nil {
+			return "", errors.Join(err, fmt.Errorf("mkdir cwd %s failed", config.Cwd))
+		}
+		if err := syscall.Chdir(config.Cwd); err != nil {
+			return "", errors.Join(err, fmt.Errorf("chdir to %s failed", config.Cwd))
+		}
+	}
+
+	// exec.LookPath 依赖 PATH 环境变量，因此这里先把环境变量替换成容器的
+	os.Clearenv()
+	for _, env := range config.Env {
+		if k, v, ok := strings.Cut(env, "="); ok {
+			os.Setenv(k, v)
+		}
+	}
+
+	path, err := exec.LookPath(config.Args[0])
+	if err != nil {
+		return "", errors.Join(err, fmt.Errorf("exec look path %s failed", config.Args[0]))
+	}
+	logrus.Infof("find path [%s]", path)
+	return path, nil
 }
 
 /*
 Init 挂载点
 */
-func setUpMount() {
-	pwd, err := os.Getwd()
-	if err != nil {
-		logrus.Errorf("Get current location error %v", err)
-
-	}
-	logrus.Infof("Current location is %s", pwd)
+func setUpMount(config *InitConfig) error {
+	rootfs := config.Rootfs
+	logrus.Infof("Current location is %s", rootfs)
 
 	// systemd 加入linux之后, mount namespace 就变成 shared by default, 所以你必须显示
 	// 声明你要这个新的mount namespace独立。
 	// 如果不先做 private mount，会导致挂载事件外泄，后续执行 pivotRoot 会出现 invalid argument 错误
-	syscall.Mount("", "/", "", syscall.MS_PRIVATE|syscall.MS_REC, "")
+	propagation := uintptr(syscall.MS_PRIVATE | syscall.MS_REC)
+	if config.RootfsPropagation != "" {
+		_, propagation, _ = parseMountOptions([]string{config.RootfsPropagation})
+	}
+	if err := syscall.Mount("", "/", "", propagation, ""); err != nil {
+		return errors.Join(err, errors.New("make / private failed"))
+	}
 
-	err = pivotRoot(pwd)
-	if err != nil {
-		logrus.Errorf("pivotRoot failed,detail: %v", err)
-		return
+	// 必须先把 rootfs bind 到自身，后续的挂载才会出现在新的 rootfs 挂载点下
+	if err := bindRootfs(rootfs); err != nil {
+		return err
 	}
 
-	// mount /proc
-	defaultMountFlags := syscall.MS_NOEXEC | syscall.MS_NOSUID | syscall.MS_NODEV
-	_ = syscall.Mount("proc", "/proc", "proc", uintptr(defaultMountFlags), "")
-	// 由于前面 pivotRoot 切换了 rootfs，因此这里重新 mount 一下 /dev 目录
-	// tmpfs 是基于 件系 使用 RAM、swap 分区来存储。
+	// 挂载 /proc、/dev 等文件系统
 	// 不挂载 /dev，会导致容器内部无法访问和使用许多设备，这可能导致系统无法正常工作
-	syscall.Mount("tmpfs", "/dev", "tmpfs", syscall.MS_NOSUID|syscall.MS_STRICTATIME, "mode=755")
+	for _, m := range config.Mounts {
+		if err := mountEntry(rootfs, m); err != nil {
+			return err
+		}
+	}
+	if err := bindDefaultDevices(rootfs); err != nil {
+		return err
+	}
+
+	if err := pivotRoot(rootfs); err != nil {
+		return errors.Join(err, errors.New("pivotRoot failed"))
+	}
+
+	if config.Readonly {
+		if err := syscall.Mount("", "/", "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+			return errors.Join(err, errors.New("remount rootfs readonly failed"))
+		}
+	}
+	return nil
 }
 
 /*
@@ -114,13 +174,19 @@ NOTE：PivotRoot调用有限制，newRoot和oldRoot不能在同一个文件系
 
 bind mount是把相同的内容换了一个挂载点的挂载方法
 */
-func pivotRoot(root string) error {
+func bindRootfs(root string) error {
 	if err := syscall.Mount(root, root, "bind", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
 		return errors.Join(err, errors.New("mount rootfs to itself"))
 	}
+	return nil
+}
+
+// pivotRoot 将 root 切换为新的根目录，调用前需要先通过 bindRootfs 把 root 变成一个挂载点
+func pivotRoot(root string) error {
 	// 创建 rootfs/.pivot_root 目录用于存储 old_root
 	pivotDir := filepath.Join(root, ".pivot_root")
-	if err := os.Mkdir(pivotDir, 0777); err != nil {
+	// 容器重启时上一次的 .pivot_root 目录可能还在，已存在不算错误
+	if err := os.Mkdir(pivotDir, 0777); err != nil && !os.IsExist(err) {
 		return err
 	}
 	// 执行pivot_root调用,将系统rootfs切换到新的rootfs,
@@ -0,0 +1,140 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups"
+	"github.com/NatsuiroGinga/mydocker/constant"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// defaultDevices 容器 /dev 下默认需要的设备，从宿主机 bind mount 进来
+var defaultDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom", "/dev/tty"}
+
+// mountEntry 挂载 InitConfig 中的一个 mount，destination 是相对于容器 rootfs 的路径
+func mountEntry(rootfs string, m specs.Mount) error {
+	dest := filepath.Join(rootfs, m.Destination)
+	flags, propagation, data := parseMountOptions(m.Options)
+
+	if flags&syscall.MS_BIND != 0 {
+		if err := createMountPoint(m.Source, dest); err != nil {
+			return err
+		}
+		if err := syscall.Mount(m.Source, dest, "bind", flags&(syscall.MS_BIND|syscall.MS_REC), ""); err != nil {
+			return errors.Join(err, fmt.Errorf("bind mount %s to %s failed", m.Source, dest))
+		}
+		// bind mount 时 ro 等选项会被忽略，需要再 remount 一次才能生效
+		if flags&^(syscall.MS_BIND|syscall.MS_REC) != 0 {
+			if err := syscall.Mount("", dest, "", flags|syscall.MS_REMOUNT, ""); err != nil {
+				return errors.Join(err, fmt.Errorf("remount %s failed", dest))
+			}
+		}
+	} else {
+		if err := os.MkdirAll(dest, constant.Perm0755); err != nil {
+			return errors.Join(err, fmt.Errorf("mkdir %s failed", dest))
+		}
+		source, mountType := m.Source, m.Type
+		// runc spec 生成的 config.json 中 cgroup 挂载类型为 cgroup，在 cgroup v2 下需要改成 cgroup2
+		if mountType == "cgroup" && cgroups.IsCgroup2UnifiedMode() {
+			source, mountType, data = "cgroup2", "cgroup2", ""
+		}
+		if err := syscall.Mount(source, dest, mountType, flags, data); err != nil {
+			return errors.Join(err, fmt.Errorf("mount %s(%s) to %s failed", source, mountType, dest))
+		}
+	}
+
+	if propagation != 0 {
+		if err := syscall.Mount("", dest, "", propagation, ""); err != nil {
+			return errors.Join(err, fmt.Errorf("set propagation of %s failed", dest))
+		}
+	}
+	return nil
+}
+
+// createMountPoint 创建 bind mount 的目标，源是文件时目标也需要是文件
+func createMountPoint(source, dest string) error {
+	stat, err := os.Stat(source)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("stat mount source %s failed", source))
+	}
+	if stat.IsDir() {
+		return os.MkdirAll(dest, constant.Perm0755)
+	}
+	if err = os.MkdirAll(filepath.Dir(dest), constant.Perm0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE, constant.Perm0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// bindDefaultDevices 将宿主机的常用设备 bind mount 到容器的 /dev 下
+func bindDefaultDevices(rootfs string) error {
+	for _, device := range defaultDevices {
+		dest := filepath.Join(rootfs, device)
+		if err := createMountPoint(device, dest); err != nil {
+			return err
+		}
+		if err := syscall.Mount(device, dest, "bind", syscall.MS_BIND, ""); err != nil {
+			return errors.Join(err, fmt.Errorf("bind device %s failed", device))
+		}
+	}
+	return nil
+}
+
+// setRlimit 设置进程的资源限制，例如 RLIMIT_NOFILE
+func setRlimit(rlimit specs.POSIXRlimit) error {
+	resource, ok := rlimitTypes[rlimit.Type]
+	if !ok {
+		return fmt.Errorf("unknown rlimit type %s", rlimit.Type)
+	}
+	limit := &unix.Rlimit{Cur: rlimit.Soft, Max: rlimit.Hard}
+	if err := unix.Setrlimit(resource, limit); err != nil {
+		return errors.Join(err, fmt.Errorf("set rlimit %s failed", rlimit.Type))
+	}
+	return nil
+}
+
+var rlimitTypes = map[string]int{
+	"RLIMIT_AS":         unix.RLIMIT_AS,
+	"RLIMIT_CORE":       unix.RLIMIT_CORE,
+	"RLIMIT_CPU":        unix.RLIMIT_CPU,
+	"RLIMIT_DATA":       unix.RLIMIT_DATA,
+	"RLIMIT_FSIZE":      unix.RLIMIT_FSIZE,
+	"RLIMIT_LOCKS":      unix.RLIMIT_LOCKS,
+	"RLIMIT_MEMLOCK":    unix.RLIMIT_MEMLOCK,
+	"RLIMIT_MSGQUEUE":   unix.RLIMIT_MSGQUEUE,
+	"RLIMIT_NICE":       unix.RLIMIT_NICE,
+	"RLIMIT_NOFILE":     unix.RLIMIT_NOFILE,
+	"RLIMIT_NPROC":      unix.RLIMIT_NPROC,
+	"RLIMIT_RSS":        unix.RLIMIT_RSS,
+	"RLIMIT_RTPRIO":     unix.RLIMIT_RTPRIO,
+	"RLIMIT_RTTIME":     unix.RLIMIT_RTTIME,
+	"RLIMIT_SIGPENDING": unix.RLIMIT_SIGPENDING,
+	"RLIMIT_STACK":      unix.RLIMIT_STACK,
+}
+
+// setUser 切换到 process.user 指定的用户
+func setUser(user specs.User) error {
+	gids := make([]int, 0, len(user.AdditionalGids))
+	for _, gid := range user.AdditionalGids {
+		gids = append(gids, int(gid))
+	}
+	if err := syscall.Setgroups(gids); err != nil {
+		return errors.Join(err, errors.New("setgroups failed"))
+	}
+	if err := syscall.Setgid(int(user.GID)); err != nil {
+		return errors.Join(err, fmt.Errorf("setgid %d failed", user.GID))
+	}
+	if err := syscall.Setuid(int(user.UID)); err != nil {
+		return errors.Join(err, fmt.Errorf("setuid %d failed", user.UID))
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/constant"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// NameIndexLoc 容器名索引目录，每个文件以容器名命名，内容为使用该名称的容器 id
+	NameIndexLoc = "/var/lib/mydocker/names/"
+	// staleNameTimeout 占用容器名后超过这个时间还没有创建出容器，认为容器创建失败了
+	staleNameTimeout = time.Minute
+	// nameIndexReady 已经为引入索引之前创建的容器补全过索引，容器名不能以 . 开头，不会和索引文件冲突
+	nameIndexReady = ".ready"
+)
+
+var (
+	// ErrNameInUse 容器名已经被其它容器使用
+	ErrNameInUse = errors.New("container name is already in use")
+
+	// validName 合法的容器名，和 docker 相同
+	validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// ValidateName 检查容器名是否合法，容器名会作为索引的文件名，因此不能包含 / 等字符
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid container name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	return nil
+}
+
+// ReserveName 为容器 containerId 占用容器名
+/*
+对索引目录加 flock 排他锁，检查和写入索引文件在同一把锁内完成，同时创建多个同名容器时只有一个能成功。
+
+容器名在容器信息写入之前就被占用，因此索引文件已经存在而对应的容器不存在时，
+可能是容器正在创建，也可能是异常退出遗留下来的。超过 staleNameTimeout 的才认为是遗留的并直接覆盖，
+其它情况返回 ErrNameInUse。
+
+引入索引之前创建的容器没有索引文件，第一次使用索引时通过 list 读取已有的容器补全索引。
+*/
+func ReserveName(name, containerId string, list func() ([]*Info, error)) error {
+	if err := os.MkdirAll(NameIndexLoc, constant.Perm0755); err != nil {
+		return errors.Join(err, fmt.Errorf("mkdir %s failed", NameIndexLoc))
+	}
+	unlock, err := lockNameIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err = backfillNames(list); err != nil {
+		return errors.Join(err, errors.New("backfill name index failed"))
+	}
+
+	indexFile := path.Join(NameIndexLoc, name)
+	content, err := os.ReadFile(indexFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Join(err, fmt.Errorf("read name index %s failed", indexFile))
+	}
+	if err == nil {
+		owner := strings.TrimSpace(string(content))
+		if owner != containerId && !staleName(indexFile, owner) {
+			return fmt.Errorf("%w: %q is used by container %s", ErrNameInUse, name, owner)
+		}
+	}
+	return os.WriteFile(indexFile, []byte(containerId), constant.Perm0644)
+}
+
+// backfillNames 为引入索引之前创建的容器补全索引，完成后写入 nameIndexReady 标记，调用方需要持有索引目录的锁
+//
+// 多个旧容器重名时只有第一个写入索引，其余的仍然可以通过容器 id 访问
+func backfillNames(list func() ([]*Info, error)) error {
+	marker := path.Join(NameIndexLoc, nameIndexReady)
+	if _, err := os.Stat(marker); err == nil {
+		return nil
+	}
+	containers, err := list()
+	if err != nil {
+		return err
+	}
+	for _, info := range containers {
+		if ValidateName(info.Name) != nil {
+			continue
+		}
+		if owner, err := LookupName(info.Name); err != nil || owner != "" {
+			continue
+		}
+		indexFile := path.Join(NameIndexLoc, info.Name)
+		if err = os.WriteFile(indexFile, []byte(info.Id), constant.Perm0644); err != nil {
+			return errors.Join(err, fmt.Errorf("write name index %s failed", indexFile))
+		}
+	}
+	return os.WriteFile(marker, nil, constant.Perm0644)
+}
+
+// lockNameIndex 对索引目录加 flock 排他锁，返回解锁函数
+func lockNameIndex() (func(), error) {
+	fd, err := unix.Open(NameIndexLoc, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: NameIndexLoc, Err: err}
+	}
+	if err = unix.Flock(fd, unix.LOCK_EX); err != nil {
+		unix.Close(fd)
+		return nil, &fs.PathError{Op: "flock", Path: NameIndexLoc, Err: err}
+	}
+	return func() { unix.Close(fd) }, nil
+}
+
+// staleName 索引文件对应的容器不存在，并且已经超过了创建容器需要的时间
+func staleName(indexFile, owner string) bool {
+	if _, err := os.Stat(fmt.Sprintf(InfoLocFormat, owner)); err == nil {
+		return false
+	}
+	stat, err := os.Stat(indexFile)
+	return err == nil && time.Since(stat.ModTime()) > staleNameTimeout
+}
+
+// LookupName 返回使用容器名 name 的容器 id，没有容器使用该名称时返回空字符串
+func LookupName(name string) (string, error) {
+	content, err := os.ReadFile(path.Join(NameIndexLoc, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	containerId := strings.TrimSpace(string(content))
+	// 容器已经被删除时索引无效
+	if _, err = os.Stat(fmt.Sprintf(InfoLocFormat, containerId)); err != nil {
+		return "", nil
+	}
+	return containerId, nil
+}
+
+// ReleaseName 释放容器 containerId 占用的容器名，容器名已经被其它容器占用时不做处理
+func ReleaseName(name, containerId string) {
+	unlock, err := lockNameIndex()
+	if err != nil { // 索引目录不存在时也没有需要释放的容器名
+		return
+	}
+	defer unlock()
+	indexFile := path.Join(NameIndexLoc, name)
+	content, err := os.ReadFile(indexFile)
+	if err != nil || strings.TrimSpace(string(content)) != containerId {
+		return
+	}
+	os.Remove(indexFile)
+}
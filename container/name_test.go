@@ -0,0 +1,16 @@
+package container
+
+import "testing"
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"web", "web-1", "my_container.v2", "1"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-web", "../etc", "a/b", "web 1"} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
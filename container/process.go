@@ -0,0 +1,74 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ProcessExists 判断 pid 对应的进程是否存在，僵尸进程视为不存在
+func ProcessExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	if err := syscall.Kill(pid, 0); err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	fields, err := procStat(pid)
+	if err != nil {
+		return false
+	}
+	return fields[0] != "Z"
+}
+
+// ProcessStartTime 返回进程的启动时间，单位为系统启动后的时钟滴答数
+//
+// pid 被回收后分配给新的进程时启动时间会不同，和 pid 一起记录下来就可以唯一确定一个进程
+func ProcessStartTime(pid int) (uint64, error) {
+	fields, err := procStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	// starttime 是 /proc/[pid]/stat 的第 22 个字段，fields 从第 3 个字段 state 开始
+	const startTimeField = 22 - 3
+	if len(fields) <= startTimeField {
+		return 0, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	return strconv.ParseUint(fields[startTimeField], 10, 64)
+}
+
+// ProcessAlive 判断启动时间为 startTime 的进程 pid 是否仍然存在
+//
+// startTime 为 0 时只判断 pid 是否存在，兼容没有记录启动时间的容器
+func ProcessAlive(pid int, startTime uint64) bool {
+	if !ProcessExists(pid) {
+		return false
+	}
+	if startTime == 0 {
+		return true
+	}
+	current, err := ProcessStartTime(pid)
+	return err == nil && current == startTime
+}
+
+// procStat 读取 /proc/[pid]/stat，返回 comm 之后的字段
+//
+// /proc/[pid]/stat 的格式为 pid (comm) state ...，comm 中可能含有空格，因此从最后一个 ')' 之后开始解析
+func procStat(pid int) ([]string, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+	i := strings.LastIndexByte(string(stat), ')')
+	if i < 0 {
+		return nil, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	return fields, nil
+}
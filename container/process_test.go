@@ -0,0 +1,27 @@
+package container
+
+import (
+	"os"
+	"testing"
+)
+
+func TestProcessAlive(t *testing.T) {
+	pid := os.Getpid()
+	startTime, err := ProcessStartTime(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if startTime == 0 {
+		t.Fatal("expected non-zero start time")
+	}
+	if !ProcessAlive(pid, startTime) || !ProcessAlive(pid, 0) {
+		t.Fatal("expected current process to be alive")
+	}
+	// pid 被复用时启动时间不同
+	if ProcessAlive(pid, startTime+1) {
+		t.Fatal("expected process with different start time to be dead")
+	}
+	if ProcessAlive(0, 0) {
+		t.Fatal("expected pid 0 to be dead")
+	}
+}
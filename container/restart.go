@@ -0,0 +1,112 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 重启策略，与 docker run --restart 一致
+const (
+	RestartNo            = "no"             // 不自动重启
+	RestartOnFailure     = "on-failure"     // 退出码非 0 时重启，可以通过 on-failure:N 限制重启次数
+	RestartAlways        = "always"         // 总是重启，被 mydocker stop 停止后 daemon 启动时仍会重新启动
+	RestartUnlessStopped = "unless-stopped" // 总是重启，被 mydocker stop 停止后不再重新启动
+)
+
+// 重启前等待时间的初始值和上限，每次重启等待时间翻倍
+const (
+	restartDelayMin = 100 * time.Millisecond
+	restartDelayMax = time.Minute
+	// 容器运行超过 restartResetUptime 后再退出，认为之前的故障已经恢复，等待时间重新从 restartDelayMin 开始
+	restartResetUptime = 10 * time.Second
+)
+
+// RestartPolicy 容器的重启策略
+type RestartPolicy struct {
+	Name              string `json:"name"`
+	MaximumRetryCount int    `json:"maximumRetryCount"` // 只对 on-failure 有效，0 表示不限制
+}
+
+// ParseRestartPolicy 解析 --restart 参数，格式为 no、always、unless-stopped、on-failure[:N]
+func ParseRestartPolicy(policy string) (RestartPolicy, error) {
+	if policy == "" {
+		return RestartPolicy{Name: RestartNo}, nil
+	}
+
+	name, count, hasCount := strings.Cut(policy, ":")
+	switch name {
+	case RestartNo, RestartAlways, RestartUnlessStopped:
+		if hasCount {
+			return RestartPolicy{}, fmt.Errorf("maximum retry count cannot be used with restart policy %s", name)
+		}
+		return RestartPolicy{Name: name}, nil
+	case RestartOnFailure:
+		restartPolicy := RestartPolicy{Name: name}
+		if hasCount {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return RestartPolicy{}, fmt.Errorf("invalid maximum retry count %q", count)
+			}
+			restartPolicy.MaximumRetryCount = n
+		}
+		return restartPolicy, nil
+	default:
+		return RestartPolicy{}, fmt.Errorf("invalid restart policy %q", policy)
+	}
+}
+
+// String 返回 --restart 参数格式的重启策略
+func (p RestartPolicy) String() string {
+	if p.Name == "" {
+		return RestartNo
+	}
+	if p.Name == RestartOnFailure && p.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", p.Name, p.MaximumRetryCount)
+	}
+	return p.Name
+}
+
+// ShouldRestart 判断容器退出后是否需要重启
+//
+// 被 mydocker stop 停止的容器无论哪种策略都不会重启
+func (p RestartPolicy) ShouldRestart(exitCode, restartCount int, manuallyStopped bool) bool {
+	if manuallyStopped {
+		return false
+	}
+	switch p.Name {
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0 && (p.MaximumRetryCount == 0 || restartCount < p.MaximumRetryCount)
+	default:
+		return false
+	}
+}
+
+// ShouldRestore 判断 daemon 启动时是否需要重新启动已经退出的容器
+/*
+宿主机重启或者 shim 被杀死后没有进程再负责重启容器，由 daemon 启动时重新启动，和 docker 一样：
+
+always 的容器即使被 mydocker stop 停止过也会重新启动，unless-stopped 的容器被 mydocker stop 停止过时不会重新启动，
+on-failure 和 no 的容器不会重新启动
+*/
+func (p RestartPolicy) ShouldRestore(manuallyStopped bool) bool {
+	switch p.Name {
+	case RestartAlways:
+		return true
+	case RestartUnlessStopped:
+		return !manuallyStopped
+	default:
+		return false
+	}
+}
+
+// NextRestartDelay 根据上一次的等待时间和容器本次运行的时长计算下一次重启前的等待时间
+func NextRestartDelay(prev, uptime time.Duration) time.Duration {
+	if prev == 0 || uptime >= restartResetUptime {
+		return restartDelayMin
+	}
+	return min(prev*2, restartDelayMax)
+}
@@ -0,0 +1,89 @@
+package container
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	for _, policy := range []string{"no", "always", "unless-stopped", "on-failure", "on-failure:3"} {
+		p, err := ParseRestartPolicy(policy)
+		if err != nil {
+			t.Fatalf("parse %s: %v", policy, err)
+		}
+		if p.String() != policy {
+			t.Fatalf("expected %s, got %s", policy, p.String())
+		}
+	}
+
+	for _, policy := range []string{"sometimes", "always:3", "unless-stopped:1", "on-failure:x", "on-failure:-1"} {
+		if _, err := ParseRestartPolicy(policy); err == nil {
+			t.Fatalf("expected error for %s", policy)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	onFailure := RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 2}
+	if onFailure.ShouldRestart(0, 0, false) {
+		t.Fatal("on-failure should not restart on success")
+	}
+	if !onFailure.ShouldRestart(1, 1, false) {
+		t.Fatal("on-failure should restart before reaching the retry count")
+	}
+	if onFailure.ShouldRestart(1, 2, false) {
+		t.Fatal("on-failure should stop after reaching the retry count")
+	}
+
+	always := RestartPolicy{Name: RestartAlways}
+	if !always.ShouldRestart(0, 100, false) {
+		t.Fatal("always should restart")
+	}
+	if always.ShouldRestart(0, 0, true) {
+		t.Fatal("manually stopped container should not restart")
+	}
+
+	unlessStopped := RestartPolicy{Name: RestartUnlessStopped}
+	if !unlessStopped.ShouldRestart(1, 100, false) {
+		t.Fatal("unless-stopped should restart")
+	}
+	if unlessStopped.ShouldRestart(0, 0, true) {
+		t.Fatal("manually stopped container should not restart")
+	}
+}
+
+func TestShouldRestore(t *testing.T) {
+	tests := []struct {
+		policy          string
+		manuallyStopped bool
+		want            bool
+	}{
+		{RestartAlways, false, true},
+		{RestartAlways, true, true},
+		{RestartUnlessStopped, false, true},
+		{RestartUnlessStopped, true, false},
+		{RestartOnFailure, false, false},
+		{RestartNo, false, false},
+	}
+	for _, tt := range tests {
+		if got := (RestartPolicy{Name: tt.policy}).ShouldRestore(tt.manuallyStopped); got != tt.want {
+			t.Errorf("%s.ShouldRestore(%v) = %v, want %v", tt.policy, tt.manuallyStopped, got, tt.want)
+		}
+	}
+}
+
+func TestNextRestartDelay(t *testing.T) {
+	delay := NextRestartDelay(0, 0)
+	if delay != restartDelayMin {
+		t.Fatalf("unexpected first delay %v", delay)
+	}
+	if delay = NextRestartDelay(delay, time.Second); delay != 2*restartDelayMin {
+		t.Fatalf("delay should double, got %v", delay)
+	}
+	if delay = NextRestartDelay(restartDelayMax, time.Second); delay != restartDelayMax {
+		t.Fatalf("delay should not exceed %v, got %v", restartDelayMax, delay)
+	}
+	if delay = NextRestartDelay(restartDelayMax, time.Minute); delay != restartDelayMin {
+		t.Fatalf("delay should be reset after a long run, got %v", delay)
+	}
+}
@@ -1,11 +1,15 @@
 package container
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
+	"path"
 
 	"github.com/NatsuiroGinga/mydocker/utils"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
 // NewWorkSpace Create an Overlay2 filesystem as container root workspace
@@ -28,14 +32,37 @@ func NewWorkSpace(containerID, imageName, volume string) {
 	*/
 	// 如果指定了volume则还需要mount volume
 	if volume != "" {
-		mntPath := utils.GetMerged(containerID)
-		hostPath, containerPath, err := volumeExtract(volume)
-		if err != nil {
-			logrus.Errorf("extract volume failed，maybe volume parameter input is not correct，detail:%v", err)
-			return
-		}
-		mountVolume(mntPath, hostPath, containerPath)
+		mountWorkSpaceVolume(containerID, volume)
+	}
+}
+
+// RestoreWorkSpace 宿主机重启后 overlayfs 和 volume 都已经被卸载，重新启动已有的容器前重新挂载
+//
+// merged 目录和上级目录在同一个设备上说明没有挂载，仍然挂载着时不做处理
+func RestoreWorkSpace(containerID, volume string) {
+	mntPath := utils.GetMerged(containerID)
+	var mnt, parent unix.Stat_t
+	if err := unix.Stat(mntPath, &mnt); err != nil {
+		logrus.Errorf("stat %s error %v", mntPath, err)
+		return
+	}
+	if err := unix.Stat(path.Dir(mntPath), &parent); err == nil && mnt.Dev != parent.Dev {
+		return
+	}
+	mountOverlayFS(containerID)
+	if volume != "" {
+		mountWorkSpaceVolume(containerID, volume)
+	}
+}
+
+// mountWorkSpaceVolume 解析 volume 并 bind mount 到容器的 merged 目录中
+func mountWorkSpaceVolume(containerID, volume string) {
+	hostPath, containerPath, err := volumeExtract(volume)
+	if err != nil {
+		logrus.Errorf("extract volume failed，maybe volume parameter input is not correct，detail:%v", err)
+		return
 	}
+	mountVolume(utils.GetMerged(containerID), hostPath, containerPath)
 }
 
 /*
@@ -152,3 +179,21 @@ func mountOverlayFS(containerID string) {
 		logrus.Errorf("%v", err)
 	}
 }
+
+// RemoveWorkSpace 删除没有容器信息的容器留下的 overlay 目录
+/*
+这类容器的 volume 已经无从得知，因此 merged 使用 MNT_DETACH 卸载，
+其下挂载的 volume 会一起被卸载，之后再删除目录就不会删除 volume 中的数据。
+*/
+func RemoveWorkSpace(containerID string) error {
+	mntPath := utils.GetMerged(containerID)
+	if err := unix.Unmount(mntPath, unix.MNT_DETACH); err != nil &&
+		!errors.Is(err, unix.EINVAL) && !errors.Is(err, unix.ENOENT) { // 没有挂载或者目录不存在
+		return errors.Join(err, fmt.Errorf("umount %s failed", mntPath))
+	}
+	root := utils.GetRoot(containerID)
+	if err := os.RemoveAll(root); err != nil {
+		return errors.Join(err, fmt.Errorf("remove dir %s failed", root))
+	}
+	return nil
+}
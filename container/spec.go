@@ -0,0 +1,276 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// SpecConfigName OCI bundle 中描述容器的配置文件名
+const SpecConfigName = "config.json"
+
+// StatePaused 运行时规范没有定义暂停状态，和 runc 一样使用 paused
+const StatePaused specs.ContainerState = "paused"
+
+// namespaceFlags OCI namespace 类型与 clone flag 的对应关系
+var namespaceFlags = map[specs.LinuxNamespaceType]uintptr{
+	specs.PIDNamespace:     syscall.CLONE_NEWPID,
+	specs.NetworkNamespace: syscall.CLONE_NEWNET,
+	specs.MountNamespace:   syscall.CLONE_NEWNS,
+	specs.IPCNamespace:     syscall.CLONE_NEWIPC,
+	specs.UTSNamespace:     syscall.CLONE_NEWUTS,
+	specs.UserNamespace:    syscall.CLONE_NEWUSER,
+	specs.CgroupNamespace:  syscall.CLONE_NEWCGROUP,
+}
+
+// mountOptionFlags 挂载选项与 mount flag 的对应关系, clear 为 true 表示该选项用于清除对应的 flag
+var mountOptionFlags = map[string]struct {
+	clear bool
+	flag  uintptr
+}{
+	"ro":          {false, syscall.MS_RDONLY},
+	"rw":          {true, syscall.MS_RDONLY},
+	"nosuid":      {false, syscall.MS_NOSUID},
+	"suid":        {true, syscall.MS_NOSUID},
+	"nodev":       {false, syscall.MS_NODEV},
+	"dev":         {true, syscall.MS_NODEV},
+	"noexec":      {false, syscall.MS_NOEXEC},
+	"exec":        {true, syscall.MS_NOEXEC},
+	"sync":        {false, syscall.MS_SYNCHRONOUS},
+	"async":       {true, syscall.MS_SYNCHRONOUS},
+	"noatime":     {false, syscall.MS_NOATIME},
+	"atime":       {true, syscall.MS_NOATIME},
+	"nodiratime":  {false, syscall.MS_NODIRATIME},
+	"diratime":    {true, syscall.MS_NODIRATIME},
+	"relatime":    {false, syscall.MS_RELATIME},
+	"norelatime":  {true, syscall.MS_RELATIME},
+	"strictatime": {false, syscall.MS_STRICTATIME},
+	"mand":        {false, syscall.MS_MANDLOCK},
+	"nomand":      {true, syscall.MS_MANDLOCK},
+	"remount":     {false, syscall.MS_REMOUNT},
+	"bind":        {false, syscall.MS_BIND},
+	"rbind":       {false, syscall.MS_BIND | syscall.MS_REC},
+}
+
+// LoadSpec 读取 bundle 目录下的 config.json 并做基本校验
+func LoadSpec(bundle string) (*specs.Spec, error) {
+	configPath := filepath.Join(bundle, SpecConfigName)
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("read bundle config %s failed", configPath))
+	}
+
+	spec := new(specs.Spec)
+	if err = json.Unmarshal(content, spec); err != nil {
+		return nil, errors.Join(err, fmt.Errorf("unmarshal bundle config %s failed", configPath))
+	}
+
+	if spec.Process == nil || len(spec.Process.Args) == 0 {
+		return nil, errors.New("bundle config has no process args")
+	}
+	if spec.Root == nil || spec.Root.Path == "" {
+		return nil, errors.New("bundle config has no root path")
+	}
+	if spec.Linux == nil {
+		return nil, errors.New("bundle config has no linux section")
+	}
+
+	return spec, nil
+}
+
+// SpecRootfs 返回 bundle 中 rootfs 的绝对路径，root.path 为相对路径时相对于 bundle 目录
+func SpecRootfs(bundle string, spec *specs.Spec) string {
+	if filepath.IsAbs(spec.Root.Path) {
+		return spec.Root.Path
+	}
+	return filepath.Join(bundle, spec.Root.Path)
+}
+
+// BundleInitConfig 根据 config.json 生成发送给 init 进程的 InitConfig
+func BundleInitConfig(bundle string, spec *specs.Spec) *InitConfig {
+	config := &InitConfig{
+		Args:              spec.Process.Args,
+		Env:               spec.Process.Env,
+		Cwd:               spec.Process.Cwd,
+		User:              spec.Process.User,
+		Rootfs:            SpecRootfs(bundle, spec),
+		Readonly:          spec.Root.Readonly,
+		RootfsPropagation: spec.Linux.RootfsPropagation,
+		Mounts:            spec.Mounts,
+		Rlimits:           spec.Process.Rlimits,
+	}
+	// 只有新建了 uts namespace 才能设置 hostname，否则会修改宿主机的 hostname
+	if SpecHasNamespace(spec, specs.UTSNamespace) {
+		config.Hostname = spec.Hostname
+	}
+	return config
+}
+
+// SpecCloneFlags 将 linux.namespaces 转换为 clone flags
+//
+// 目前只支持新建 namespace，加入已有的 namespace(指定了 path) 会返回错误
+func SpecCloneFlags(spec *specs.Spec) (uintptr, error) {
+	var flags uintptr
+	for _, ns := range spec.Linux.Namespaces {
+		flag, ok := namespaceFlags[ns.Type]
+		if !ok {
+			return 0, fmt.Errorf("unknown namespace type %s", ns.Type)
+		}
+		if ns.Path != "" {
+			return 0, fmt.Errorf("joining existing %s namespace %s is not supported", ns.Type, ns.Path)
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
+
+// SpecHasNamespace 判断 spec 中是否声明了某个 namespace
+func SpecHasNamespace(spec *specs.Spec, nsType specs.LinuxNamespaceType) bool {
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == nsType {
+			return true
+		}
+	}
+	return false
+}
+
+// SpecResource 将 linux.resources 转换为 cgroup 使用的 ResourceConfig
+func SpecResource(spec *specs.Spec) *resource.ResourceConfig {
+	res := &resource.ResourceConfig{}
+	resources := spec.Linux.Resources
+	if resources == nil {
+		return res
+	}
+
+	if memory := resources.Memory; memory != nil {
+		if memory.Limit != nil && *memory.Limit > 0 {
+			res.MemoryLimit = strconv.FormatInt(*memory.Limit, 10)
+		}
+		// OCI 中的 swap 与 v1 的 memory.memsw.limit_in_bytes 相同，是内存加 swap 的总限制
+		if memory.Swap != nil && (*memory.Swap > 0 || *memory.Swap == resource.MemoryUnlimited) {
+			res.MemorySwap = strconv.FormatInt(*memory.Swap, 10)
+		}
+		if memory.Reservation != nil && *memory.Reservation > 0 {
+			res.MemoryReservation = strconv.FormatInt(*memory.Reservation, 10)
+		}
+	}
+
+	if cpu := resources.CPU; cpu != nil {
+		if cpu.Quota != nil && *cpu.Quota > 0 {
+			period := uint64(100000)
+			if cpu.Period != nil && *cpu.Period > 0 {
+				period = *cpu.Period
+			}
+			// CpuCfsQuota 的单位是百分比
+			res.CpuCfsQuota = int(uint64(*cpu.Quota) * 100 / period)
+		}
+		if cpu.Shares != nil && *cpu.Shares > 0 {
+			res.CpuShare = strconv.FormatUint(*cpu.Shares, 10)
+		}
+		res.CpuSet = cpu.Cpus
+		res.CpuSetMems = cpu.Mems
+	}
+
+	if resources.Pids != nil {
+		res.PidsLimit = resources.Pids.Limit
+	}
+
+	if blockIO := resources.BlockIO; blockIO != nil {
+		if blockIO.Weight != nil {
+			res.BlkioWeight = *blockIO.Weight
+		}
+		throttles := func(devices []specs.LinuxThrottleDevice) []resource.ThrottleDevice {
+			var result []resource.ThrottleDevice
+			for _, d := range devices {
+				result = append(result, resource.ThrottleDevice{Major: d.Major, Minor: d.Minor, Rate: d.Rate})
+			}
+			return result
+		}
+		res.BlkioDeviceReadBps = throttles(blockIO.ThrottleReadBpsDevice)
+		res.BlkioDeviceWriteBps = throttles(blockIO.ThrottleWriteBpsDevice)
+		res.BlkioDeviceReadIOps = throttles(blockIO.ThrottleReadIOPSDevice)
+		res.BlkioDeviceWriteIOps = throttles(blockIO.ThrottleWriteIOPSDevice)
+	}
+
+	return res
+}
+
+// SpecCgroupPath 返回容器使用的 cgroup 路径，未指定 cgroupsPath 时使用容器 id
+func SpecCgroupPath(spec *specs.Spec, containerId string) string {
+	if spec.Linux.CgroupsPath != "" {
+		return strings.TrimPrefix(spec.Linux.CgroupsPath, "/")
+	}
+	return containerId
+}
+
+// parseMountOptions 将 OCI mount options 拆分为 mount flags 和 data
+//
+// 能识别的选项转换为 flag，其余选项原样拼接为 data，例如 mode=755,size=65536k
+func parseMountOptions(options []string) (flags uintptr, propagation uintptr, data string) {
+	var dataOpts []string
+	for _, opt := range options {
+		if f, ok := mountOptionFlags[opt]; ok {
+			if f.clear {
+				flags &^= f.flag
+			} else {
+				flags |= f.flag
+			}
+			continue
+		}
+		switch opt {
+		case "private":
+			propagation |= syscall.MS_PRIVATE
+		case "rprivate":
+			propagation |= syscall.MS_PRIVATE | syscall.MS_REC
+		case "slave":
+			propagation |= syscall.MS_SLAVE
+		case "rslave":
+			propagation |= syscall.MS_SLAVE | syscall.MS_REC
+		case "shared":
+			propagation |= syscall.MS_SHARED
+		case "rshared":
+			propagation |= syscall.MS_SHARED | syscall.MS_REC
+		default:
+			dataOpts = append(dataOpts, opt)
+		}
+	}
+	return flags, propagation, strings.Join(dataOpts, ",")
+}
+
+// BundleState 根据容器信息生成 OCI 规范中的 state，用于 mydocker state 以及传递给 hooks
+//
+// created/running 状态的容器如果 init 进程已经不存在，则认为容器已经 stopped
+func BundleState(info *Info, spec *specs.Spec) *specs.State {
+	state := &specs.State{
+		Version: specs.Version,
+		ID:      info.Id,
+		Bundle:  info.Bundle,
+	}
+	if spec != nil {
+		state.Annotations = spec.Annotations
+	}
+
+	pid, _ := strconv.Atoi(info.Pid)
+	switch {
+	case info.Status == CREATED && ProcessExists(pid):
+		state.Status = specs.StateCreated
+	case info.Status == RUNNING && ProcessExists(pid):
+		state.Status = specs.StateRunning
+	case info.Status == PAUSED && ProcessExists(pid):
+		state.Status = StatePaused
+	default:
+		state.Status = specs.StateStopped
+	}
+	if state.Status != specs.StateStopped {
+		state.Pid = pid
+	}
+	return state
+}
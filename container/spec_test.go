@@ -0,0 +1,60 @@
+package container
+
+import (
+	"syscall"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestParseMountOptions(t *testing.T) {
+	flags, propagation, data := parseMountOptions([]string{"nosuid", "strictatime", "mode=755", "size=65536k", "rprivate"})
+	if flags != syscall.MS_NOSUID|syscall.MS_STRICTATIME {
+		t.Fatalf("unexpected flags %#x", flags)
+	}
+	if propagation != syscall.MS_PRIVATE|syscall.MS_REC {
+		t.Fatalf("unexpected propagation %#x", propagation)
+	}
+	if data != "mode=755,size=65536k" {
+		t.Fatalf("unexpected data %s", data)
+	}
+
+	flags, _, _ = parseMountOptions([]string{"ro", "rw"})
+	if flags != 0 {
+		t.Fatalf("rw should clear ro, got %#x", flags)
+	}
+}
+
+func TestSpecCloneFlags(t *testing.T) {
+	spec := &specs.Spec{Linux: &specs.Linux{Namespaces: []specs.LinuxNamespace{
+		{Type: specs.PIDNamespace}, {Type: specs.MountNamespace}, {Type: specs.UTSNamespace},
+	}}}
+	flags, err := SpecCloneFlags(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags != syscall.CLONE_NEWPID|syscall.CLONE_NEWNS|syscall.CLONE_NEWUTS {
+		t.Fatalf("unexpected clone flags %#x", flags)
+	}
+
+	spec.Linux.Namespaces = append(spec.Linux.Namespaces, specs.LinuxNamespace{Type: specs.NetworkNamespace, Path: "/var/run/netns/test"})
+	if _, err = SpecCloneFlags(spec); err == nil {
+		t.Fatal("joining namespace by path should fail")
+	}
+}
+
+func TestSpecResource(t *testing.T) {
+	limit, swap, quota, period := int64(100*1024*1024), int64(-1), int64(50000), uint64(100000)
+	weight := uint16(300)
+	spec := &specs.Spec{Linux: &specs.Linux{Resources: &specs.LinuxResources{
+		Memory:  &specs.LinuxMemory{Limit: &limit, Swap: &swap},
+		CPU:     &specs.LinuxCPU{Quota: &quota, Period: &period, Cpus: "0-1", Mems: "0"},
+		Pids:    &specs.LinuxPids{Limit: 20},
+		BlockIO: &specs.LinuxBlockIO{Weight: &weight},
+	}}}
+	res := SpecResource(spec)
+	if res.MemoryLimit != "104857600" || res.MemorySwap != "-1" || res.CpuCfsQuota != 50 || res.CpuSet != "0-1" ||
+		res.CpuSetMems != "0" || res.PidsLimit != 20 || res.BlkioWeight != 300 {
+		t.Fatalf("unexpected resource config %#v", res)
+	}
+}
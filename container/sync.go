@@ -0,0 +1,168 @@
+package container
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// init 进程通过 ExtraFiles 继承的 fd，0、1、2 为标准输入输出，ExtraFiles 从 3 开始
+const (
+	syncFdIndex     = 3 // 与父进程通信的 sync socket
+	execFifoFdIndex = 4 // exec fifo
+)
+
+// InitProtocolVersion 父进程与 init 进程之间通信协议的版本，双方版本不一致时 init 进程直接报错退出
+const InitProtocolVersion = 1
+
+// maxSyncMessageSize 单条消息的最大长度，防止读到错误的长度前缀时分配过大的内存
+const maxSyncMessageSize = 1 << 20
+
+// sync socket 上传递的消息类型
+const (
+	syncTypeConfig = "config" // 父进程 -> init 进程，携带 InitConfig
+	syncTypeReady  = "ready"  // init 进程 -> 父进程，初始化完成
+	syncTypeError  = "error"  // init 进程 -> 父进程，初始化失败及原因
+)
+
+// InitConfig 父进程发送给 init 进程的全部配置，init 进程按照它初始化容器并执行用户命令
+type InitConfig struct {
+	Args              []string            `json:"args"`              // 用户命令，与 execve 的 argv 语义相同
+	Env               []string            `json:"env"`               // 用户命令的环境变量
+	Cwd               string              `json:"cwd"`               // 用户命令的工作目录
+	Hostname          string              `json:"hostname"`          // 容器的 hostname，为空则不设置
+	User              specs.User          `json:"user"`              // 执行用户命令的用户
+	Rootfs            string              `json:"rootfs"`            // 容器 rootfs 在宿主机上的路径
+	Readonly          bool                `json:"readonly"`          // rootfs 是否只读
+	RootfsPropagation string              `json:"rootfsPropagation"` // / 的挂载传播属性
+	Mounts            []specs.Mount       `json:"mounts"`            // pivot_root 之前需要挂载到 rootfs 下的文件系统
+	Rlimits           []specs.POSIXRlimit `json:"rlimits"`           // 资源限制
+}
+
+// syncMessage sync socket 上传递的消息，编码为 json 后加上 4 字节大端序的长度前缀
+type syncMessage struct {
+	Version int         `json:"version"`
+	Type    string      `json:"type"`
+	Config  *InitConfig `json:"config,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// newSyncPipe 创建父子进程之间双向通信的 socketpair
+/*
+和单向的 os.Pipe 不同，socketpair 两端都可以读写，create 的流程为：
+
+1）父进程启动 init 进程，设置好 cgroup 和网络之后，通过 sync socket 发送 config 消息
+
+2）init 进程读到 config 后才开始挂载文件系统等初始化工作，完成后回复 ready，失败则回复 error
+
+3）父进程读到 ready 后才认为容器 create 成功，读到 error 或者 EOF 说明 init 进程初始化失败退出了
+*/
+func newSyncPipe() (parent *os.File, child *os.File, err error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, errors.Join(err, errors.New("create sync socketpair failed"))
+	}
+	return os.NewFile(uintptr(fds[0]), "sync-parent"), os.NewFile(uintptr(fds[1]), "sync-child"), nil
+}
+
+// writeSyncMessage 写入一条带长度前缀的消息
+func writeSyncMessage(w io.Writer, msg *syncMessage) error {
+	msg.Version = InitProtocolVersion
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("marshal %s message failed", msg.Type))
+	}
+
+	buf := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	copy(buf[4:], payload)
+	if _, err = w.Write(buf); err != nil {
+		return errors.Join(err, fmt.Errorf("write %s message failed", msg.Type))
+	}
+	return nil
+}
+
+// readSyncMessage 读取一条带长度前缀的消息，并校验协议版本
+func readSyncMessage(r io.Reader) (*syncMessage, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length > maxSyncMessageSize {
+		return nil, fmt.Errorf("sync message too large: %d bytes", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errors.Join(err, errors.New("read sync message payload failed"))
+	}
+
+	msg := new(syncMessage)
+	if err := json.Unmarshal(payload, msg); err != nil {
+		return nil, errors.Join(err, errors.New("unmarshal sync message failed"))
+	}
+	if msg.Version != InitProtocolVersion {
+		return nil, fmt.Errorf("init protocol version mismatch: want %d, got %d", InitProtocolVersion, msg.Version)
+	}
+	return msg, nil
+}
+
+// SendInitConfig 父进程通过 sync socket 将 InitConfig 发送给 init 进程
+func SendInitConfig(syncPipe *os.File, config *InitConfig) error {
+	return writeSyncMessage(syncPipe, &syncMessage{Type: syncTypeConfig, Config: config})
+}
+
+// WaitInitReady 父进程等待 init 进程完成初始化，init 进程回复的错误原样返回
+func WaitInitReady(syncPipe *os.File) error {
+	msg, err := readSyncMessage(syncPipe)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("init process exited before ready")
+		}
+		return err
+	}
+
+	switch msg.Type {
+	case syncTypeReady:
+		return nil
+	case syncTypeError:
+		return fmt.Errorf("container init failed: %s", msg.Error)
+	default:
+		return fmt.Errorf("unexpected %s message from init process", msg.Type)
+	}
+}
+
+// readInitConfig init 进程读取父进程发送的 InitConfig
+func readInitConfig(syncPipe *os.File) (*InitConfig, error) {
+	msg, err := readSyncMessage(syncPipe)
+	if err != nil {
+		return nil, errors.Join(err, errors.New("read init config failed"))
+	}
+	if msg.Type != syncTypeConfig || msg.Config == nil {
+		return nil, fmt.Errorf("unexpected %s message from parent process", msg.Type)
+	}
+	if len(msg.Config.Args) == 0 {
+		return nil, errors.New("init config has no args")
+	}
+	return msg.Config, nil
+}
+
+// notifyReady init 进程通知父进程初始化完成
+func notifyReady(syncPipe *os.File) error {
+	if err := writeSyncMessage(syncPipe, &syncMessage{Type: syncTypeReady}); err != nil {
+		return err
+	}
+	return syncPipe.Close()
+}
+
+// reportError init 进程将初始化失败的原因发送给父进程
+func reportError(syncPipe *os.File, initErr error) error {
+	return writeSyncMessage(syncPipe, &syncMessage{Type: syncTypeError, Error: initErr.Error()})
+}
@@ -0,0 +1,81 @@
+package container
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSyncConfigRoundTrip(t *testing.T) {
+	parent, child, err := newSyncPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+	defer child.Close()
+
+	sent := &InitConfig{
+		Args:     []string{"sh", "-c", "echo hello world"},
+		Env:      []string{"PATH=/bin"},
+		Cwd:      "/tmp",
+		Hostname: "box",
+	}
+	if err = SendInitConfig(parent, sent); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readInitConfig(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Args) != 3 || got.Args[2] != "echo hello world" {
+		t.Fatalf("args with spaces should be preserved, got %q", got.Args)
+	}
+	if got.Cwd != "/tmp" || got.Hostname != "box" {
+		t.Fatalf("unexpected config %#v", got)
+	}
+}
+
+func TestWaitInitReadyReportsError(t *testing.T) {
+	parent, child, err := newSyncPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+
+	if err = reportError(child, errors.New("pivot_root failed")); err != nil {
+		t.Fatal(err)
+	}
+	child.Close()
+
+	err = WaitInitReady(parent)
+	if err == nil || !strings.Contains(err.Error(), "pivot_root failed") {
+		t.Fatalf("expected init error to be reported, got %v", err)
+	}
+}
+
+func TestWaitInitReadyEOF(t *testing.T) {
+	parent, child, err := newSyncPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Close()
+	child.Close()
+
+	if err = WaitInitReady(parent); err == nil {
+		t.Fatal("expected error when init process exits without reply")
+	}
+}
+
+func TestReadSyncMessageVersionMismatch(t *testing.T) {
+	payload := []byte(`{"version":999,"type":"ready"}`)
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, uint32(len(payload)))
+	buf.Write(payload)
+
+	if _, err := readSyncMessage(buf); err == nil {
+		t.Fatal("expected version mismatch error")
+	}
+}
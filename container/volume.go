@@ -1,11 +1,17 @@
 package container
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/NatsuiroGinga/mydocker/constant"
 	"github.com/sirupsen/logrus"
@@ -39,9 +45,11 @@ func volumeExtract(volume string) (sourcePath, destinationPath string, err error
 */
 // mountVolume 使用 bind mount 挂载 volume
 func mountVolume(mntPath, hostPath, containerPath string) {
-	// 创建宿主机目录
+	// 创建宿主机目录，由 mydocker 创建的目录记录下来，mydocker system prune --volumes 时可以删除
 	if err := os.Mkdir(hostPath, constant.Perm0777); err != nil {
 		logrus.Infof("mkdir parent dir %s error. %v", hostPath, err)
+	} else if err = recordVolume(hostPath); err != nil {
+		logrus.Warnf("record volume %s error. %v", hostPath, err)
 	}
 	// 拼接出对应的容器目录在宿主机上的的位置，并创建对应目录
 	containerPathInHost := path.Join(mntPath, containerPath)
@@ -70,3 +78,82 @@ func umountVolume(mntPath, containerPath string) {
 		logrus.Errorf("Umount volume failed. %v", err)
 	}
 }
+
+// VolumeIndexLoc 数据卷索引目录，记录由 mydocker 创建的宿主机目录，文件名为目录路径的 sha256，内容为目录路径
+/*
+数据卷就是宿主机上的目录，用户事先创建好的目录属于用户，mydocker 不会删除，
+只有挂载时不存在、由 mydocker 创建的目录才会被 prune 清理。
+*/
+const VolumeIndexLoc = "/var/lib/mydocker/volumes/"
+
+// Volume 由 mydocker 创建的数据卷
+type Volume struct {
+	Path    string    // 宿主机上的绝对路径
+	Created time.Time // 创建时间
+}
+
+// VolumeHostPath 返回 -v 参数中宿主机目录的绝对路径
+func VolumeHostPath(volume string) (string, error) {
+	hostPath, _, err := volumeExtract(volume)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(hostPath)
+}
+
+func volumeIndexFile(hostPath string) string {
+	sum := sha256.Sum256([]byte(hostPath))
+	return path.Join(VolumeIndexLoc, hex.EncodeToString(sum[:]))
+}
+
+func recordVolume(hostPath string) error {
+	hostPath, err := filepath.Abs(hostPath)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(VolumeIndexLoc, constant.Perm0755); err != nil {
+		return errors.Join(err, fmt.Errorf("mkdir %s failed", VolumeIndexLoc))
+	}
+	return os.WriteFile(volumeIndexFile(hostPath), []byte(hostPath), constant.Perm0644)
+}
+
+// ListVolumes 返回由 mydocker 创建的全部数据卷，目录已经被用户删除的会被忽略
+func ListVolumes() ([]*Volume, error) {
+	entries, err := os.ReadDir(VolumeIndexLoc)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("read dir %s failed", VolumeIndexLoc))
+	}
+	volumes := make([]*Volume, 0, len(entries))
+	for _, entry := range entries {
+		content, err := os.ReadFile(path.Join(VolumeIndexLoc, entry.Name()))
+		if err != nil {
+			continue
+		}
+		hostPath := string(content)
+		if _, err = os.Stat(hostPath); err != nil {
+			continue
+		}
+		// 目录的修改时间会随着写入变化，索引文件的修改时间才是创建时间
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		volumes = append(volumes, &Volume{Path: hostPath, Created: info.ModTime()})
+	}
+	return volumes, nil
+}
+
+// RemoveVolume 删除由 mydocker 创建的数据卷，不是由 mydocker 创建的目录不会删除
+func RemoveVolume(hostPath string) error {
+	indexFile := volumeIndexFile(hostPath)
+	if _, err := os.Stat(indexFile); err != nil {
+		return fmt.Errorf("volume %s is not created by mydocker", hostPath)
+	}
+	if err := os.RemoveAll(hostPath); err != nil {
+		return errors.Join(err, fmt.Errorf("remove volume %s failed", hostPath))
+	}
+	return os.Remove(indexFile)
+}
@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"context"
+	"io"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/client"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+)
+
+// Backend daemon 对外提供的全部操作
+/*
+server 将 HTTP 请求转换为对 Backend 的调用，daemon 中的 Backend 就是 *client.Client；
+Client 则通过 HTTP 请求实现 Backend，因此 mydocker 的命令行既可以直接在本进程中操作容器，
+也可以作为 daemon 的客户端，两者的代码完全一样。
+
+返回的错误通过 client.ErrNotFound 等区分类型，server 会返回对应的 HTTP 状态码。
+*/
+type Backend interface {
+	// Create 创建容器，返回容器 id，容器的 init 进程阻塞直到 Start
+	Create(config *client.ContainerConfig) (string, error)
+	// Start 启动 created 状态的容器
+	Start(id string) error
+	// Stop 停止容器，超时后发送 SIGKILL
+	Stop(id string, opts *client.StopOptions) error
+	// Kill 向容器的 init 进程发送信号
+	Kill(id, signal string) error
+	// Pause 暂停容器中的全部进程
+	Pause(id string) error
+	// Unpause 恢复被暂停的容器
+	Unpause(id string) error
+	// Update 修改容器的资源限制，res 中为零值的项保持不变
+	Update(id string, res *resource.ResourceConfig) error
+	// Remove 删除容器，force 为 true 时先停止运行中的容器
+	Remove(id string, force bool) error
+	// List 按照创建时间从新到旧列出满足条件的容器
+	List(opts *client.ListOptions) ([]*container.Info, error)
+	// Inspect 返回容器的详细信息
+	Inspect(id string) (*client.ContainerDetail, error)
+	// Logs 返回容器的日志
+	Logs(id string) (io.ReadCloser, error)
+	// Stats 返回容器当前的资源使用情况
+	Stats(id string) (*client.ContainerStats, error)
+	// Wait 等待容器退出并返回退出码
+	Wait(id string) (int, error)
+	// Exec 在容器中执行命令并返回输出
+	Exec(id string, cmd []string) (*client.ExecResult, error)
+	// Events 按时间顺序对满足条件的事件调用 fn，没有指定 until 时持续等待新的事件，直到 ctx 结束或者 fn 返回错误
+	Events(ctx context.Context, opts *client.EventsOptions, fn func(event *events.Event) error) error
+
+	// NetworkCreate 创建网络
+	NetworkCreate(config *client.NetworkConfig) error
+	// NetworkList 列出满足过滤条件的网络
+	NetworkList(filters client.Filters) ([]*client.Network, error)
+	// NetworkInspect 返回网络信息
+	NetworkInspect(name string) (*client.Network, error)
+	// NetworkRemove 删除网络
+	NetworkRemove(name string) error
+
+	// ImageList 列出全部镜像
+	ImageList() ([]*client.Image, error)
+	// ImageInspect 返回镜像信息
+	ImageInspect(name string) (*client.Image, error)
+	// Commit 将容器的 rootfs 保存为镜像
+	Commit(containerId, imageName string, labels map[string]string) error
+
+	// Reconcile 修正 init 进程已经不存在的容器的状态，gc 为 true 时还会清理遗留的资源
+	Reconcile(gc bool) (*client.ReconcileReport, error)
+	// DiskUsage 返回镜像、容器和数据卷占用的磁盘空间
+	DiskUsage() (*client.DiskUsage, error)
+	// Prune 删除已经停止的容器、没有使用的网络、镜像和数据卷
+	Prune(opts *client.PruneOptions) (*client.PruneReport, error)
+}
+
+var _ Backend = (*client.Client)(nil)
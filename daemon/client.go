@@ -0,0 +1,359 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/client"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+)
+
+// Client 通过 unix socket 访问 daemon 的 HTTP API，实现了 Backend，用法和 client.Client 相同
+type Client struct {
+	http *http.Client
+}
+
+// NewClient 创建连接到 socketPath 的 Client
+func NewClient(socketPath string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &Client{http: &http.Client{Transport: transport}}
+}
+
+// Ping 检查 daemon 是否可用
+func (c *Client) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://mydocker/_ping", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+func (c *Client) Create(config *client.ContainerConfig) (string, error) {
+	resp := new(ContainerCreateResponse)
+	if err := c.do(http.MethodPost, "/containers/create", nil, config, resp); err != nil {
+		return "", err
+	}
+	return resp.Id, nil
+}
+
+func (c *Client) Start(id string) error {
+	return c.do(http.MethodPost, "/containers/"+id+"/start", nil, nil, nil)
+}
+
+func (c *Client) Stop(id string, opts *client.StopOptions) error {
+	query := url.Values{}
+	if opts != nil {
+		if opts.Signal != "" {
+			query.Set("signal", opts.Signal)
+		}
+		if opts.Timeout != nil {
+			query.Set("t", strconv.Itoa(*opts.Timeout))
+		}
+	}
+	return c.do(http.MethodPost, "/containers/"+id+"/stop", query, nil, nil)
+}
+
+func (c *Client) Kill(id, signal string) error {
+	query := url.Values{"signal": {signal}}
+	return c.do(http.MethodPost, "/containers/"+id+"/kill", query, nil, nil)
+}
+
+func (c *Client) Pause(id string) error {
+	return c.do(http.MethodPost, "/containers/"+id+"/pause", nil, nil, nil)
+}
+
+func (c *Client) Unpause(id string) error {
+	return c.do(http.MethodPost, "/containers/"+id+"/unpause", nil, nil, nil)
+}
+
+func (c *Client) Update(id string, res *resource.ResourceConfig) error {
+	return c.do(http.MethodPost, "/containers/"+id+"/update", nil, res, nil)
+}
+
+func (c *Client) Remove(id string, force bool) error {
+	query := url.Values{"force": {strconv.FormatBool(force)}}
+	return c.do(http.MethodDelete, "/containers/"+id, query, nil, nil)
+}
+
+func (c *Client) List(opts *client.ListOptions) ([]*container.Info, error) {
+	var containers []*container.Info
+	query := url.Values{}
+	if opts != nil {
+		query.Set("all", strconv.FormatBool(opts.All))
+		if opts.Last > 0 {
+			query.Set("limit", strconv.Itoa(opts.Last))
+		}
+		if err := setJSONQuery(query, "filters", opts.Filters); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.do(http.MethodGet, "/containers/json", query, nil, &containers); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
+
+func (c *Client) Inspect(id string) (*client.ContainerDetail, error) {
+	detail := new(client.ContainerDetail)
+	if err := c.do(http.MethodGet, "/containers/"+id+"/json", nil, nil, detail); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+func (c *Client) Logs(id string) (io.ReadCloser, error) {
+	resp, err := c.request(http.MethodGet, "/containers/"+id+"/logs", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) Wait(id string) (int, error) {
+	resp := new(ContainerWaitResponse)
+	if err := c.do(http.MethodPost, "/containers/"+id+"/wait", nil, nil, resp); err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+func (c *Client) Stats(id string) (*client.ContainerStats, error) {
+	stats := new(client.ContainerStats)
+	if err := c.do(http.MethodGet, "/containers/"+id+"/stats", nil, nil, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (c *Client) Exec(id string, cmd []string) (*client.ExecResult, error) {
+	resp := new(client.ExecResult)
+	if err := c.do(http.MethodPost, "/containers/"+id+"/exec", nil, &ExecRequest{Cmd: cmd}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Client) NetworkCreate(config *client.NetworkConfig) error {
+	return c.do(http.MethodPost, "/networks/create", nil, config, nil)
+}
+
+func (c *Client) NetworkList(filters client.Filters) ([]*client.Network, error) {
+	var networks []*client.Network
+	query := url.Values{}
+	if err := setJSONQuery(query, "filters", filters); err != nil {
+		return nil, err
+	}
+	if err := c.do(http.MethodGet, "/networks", query, nil, &networks); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
+
+func (c *Client) NetworkInspect(name string) (*client.Network, error) {
+	nw := new(client.Network)
+	if err := c.do(http.MethodGet, "/networks/"+name, nil, nil, nw); err != nil {
+		return nil, err
+	}
+	return nw, nil
+}
+
+func (c *Client) NetworkRemove(name string) error {
+	return c.do(http.MethodDelete, "/networks/"+name, nil, nil, nil)
+}
+
+func (c *Client) ImageList() ([]*client.Image, error) {
+	var images []*client.Image
+	if err := c.do(http.MethodGet, "/images/json", nil, nil, &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (c *Client) ImageInspect(name string) (*client.Image, error) {
+	image := new(client.Image)
+	if err := c.do(http.MethodGet, "/images/"+name+"/json", nil, nil, image); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+func (c *Client) Commit(containerId, imageName string, labels map[string]string) error {
+	query := url.Values{"container": {containerId}, "repo": {imageName}}
+	if err := setJSONQuery(query, "labels", labels); err != nil {
+		return err
+	}
+	return c.do(http.MethodPost, "/commit", query, nil, nil)
+}
+
+func (c *Client) Reconcile(gc bool) (*client.ReconcileReport, error) {
+	report := new(client.ReconcileReport)
+	query := url.Values{"gc": {strconv.FormatBool(gc)}}
+	if err := c.do(http.MethodPost, "/system/reconcile", query, nil, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (c *Client) DiskUsage() (*client.DiskUsage, error) {
+	usage := new(client.DiskUsage)
+	if err := c.do(http.MethodGet, "/system/df", nil, nil, usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (c *Client) Prune(opts *client.PruneOptions) (*client.PruneReport, error) {
+	report := new(client.PruneReport)
+	query := url.Values{}
+	if opts != nil {
+		query.Set("all", strconv.FormatBool(opts.All))
+		query.Set("volumes", strconv.FormatBool(opts.Volumes))
+		if err := setJSONQuery(query, "filters", opts.Filters); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.do(http.MethodPost, "/system/prune", query, nil, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (c *Client) Events(ctx context.Context, opts *client.EventsOptions, fn func(event *events.Event) error) error {
+	query := url.Values{}
+	if opts != nil {
+		if opts.Since != "" {
+			query.Set("since", opts.Since)
+		}
+		if opts.Until != "" {
+			query.Set("until", opts.Until)
+		}
+		if err := setJSONQuery(query, "filters", opts.Filters); err != nil {
+			return err
+		}
+	}
+	resp, err := c.requestContext(ctx, http.MethodGet, "/events", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		event := new(events.Event)
+		if err = decoder.Decode(event); err != nil {
+			// ctx 结束时连接被关闭，不是错误
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return errors.Join(err, errors.New("decode event failed"))
+		}
+		if err = fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// setJSONQuery 将 v 编码为 json 作为 query 参数，v 为空时不设置
+func setJSONQuery[T ~map[string]V, V any](query url.Values, key string, v T) error {
+	if len(v) == 0 {
+		return nil
+	}
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	query.Set(key, string(content))
+	return nil
+}
+
+// do 发送请求，body 不为 nil 时编码为 json 作为请求体，out 不为 nil 时将响应体解码到 out 中
+func (c *Client) do(method, path string, query url.Values, body, out any) error {
+	resp, err := c.request(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Join(err, fmt.Errorf("decode response of %s %s failed", method, path))
+	}
+	return nil
+}
+
+func (c *Client) request(method, path string, query url.Values, body any) (*http.Response, error) {
+	return c.requestContext(context.Background(), method, path, query, body)
+}
+
+// requestContext 发送请求，ctx 结束时关闭连接，用于持续输出的响应
+func (c *Client) requestContext(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Join(err, fmt.Errorf("encode request of %s %s failed", method, path))
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	// unix socket 不需要 host，这里的 host 只是为了组成合法的 url
+	u := url.URL{Scheme: "http", Host: "mydocker", Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, errors.Join(err, errors.New("cannot connect to the mydocker daemon"))
+	}
+	return resp, nil
+}
+
+// checkResponse 将错误响应转换为 *client.Error，调用方可以和直接使用 client 包时一样通过 errors.Is 判断错误类型
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	defer resp.Body.Close()
+	errResp := new(ErrorResponse)
+	if err := json.NewDecoder(resp.Body).Decode(errResp); err != nil || errResp.Message == "" {
+		errResp.Message = resp.Status
+	}
+	return &client.Error{Kind: errorKind(resp.StatusCode), Err: errors.New(errResp.Message)}
+}
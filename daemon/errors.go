@@ -0,0 +1,41 @@
+package daemon
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/NatsuiroGinga/mydocker/client"
+)
+
+// StatusCode 返回错误对应的 HTTP 状态码，没有分类的错误为 500
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, client.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, client.ErrInvalidParameter):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorKind 根据 HTTP 状态码还原错误类型，和 StatusCode 相反
+func errorKind(status int) error {
+	switch status {
+	case http.StatusNotFound:
+		return client.ErrNotFound
+	case http.StatusConflict:
+		return client.ErrConflict
+	case http.StatusBadRequest:
+		return client.ErrInvalidParameter
+	default:
+		return nil
+	}
+}
+
+// invalidParameter 请求参数错误，返回 400
+func invalidParameter(err error) error {
+	return &client.Error{Kind: client.ErrInvalidParameter, Err: err}
+}
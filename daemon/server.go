@@ -0,0 +1,449 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/client"
+	"github.com/NatsuiroGinga/mydocker/constant"
+	"github.com/NatsuiroGinga/mydocker/events"
+	"github.com/sirupsen/logrus"
+)
+
+// Server 将 Backend 以类似 Docker Engine API 的形式通过 HTTP 暴露出来
+type Server struct {
+	backend Backend
+	mux     *http.ServeMux
+}
+
+// NewServer 创建 Server 并注册全部路由
+func NewServer(backend Backend) *Server {
+	s := &Server{backend: backend, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("GET /_ping", s.ping)
+
+	s.mux.HandleFunc("POST /containers/create", s.containerCreate)
+	s.mux.HandleFunc("GET /containers/json", s.containerList)
+	s.mux.HandleFunc("GET /containers/{id}/json", s.containerInspect)
+	s.mux.HandleFunc("POST /containers/{id}/start", s.containerStart)
+	s.mux.HandleFunc("POST /containers/{id}/stop", s.containerStop)
+	s.mux.HandleFunc("POST /containers/{id}/kill", s.containerKill)
+	s.mux.HandleFunc("POST /containers/{id}/pause", s.containerPause)
+	s.mux.HandleFunc("POST /containers/{id}/unpause", s.containerUnpause)
+	s.mux.HandleFunc("POST /containers/{id}/update", s.containerUpdate)
+	s.mux.HandleFunc("POST /containers/{id}/wait", s.containerWait)
+	s.mux.HandleFunc("GET /containers/{id}/stats", s.containerStats)
+	s.mux.HandleFunc("GET /containers/{id}/logs", s.containerLogs)
+	s.mux.HandleFunc("POST /containers/{id}/exec", s.containerExec)
+	s.mux.HandleFunc("DELETE /containers/{id}", s.containerRemove)
+
+	s.mux.HandleFunc("GET /events", s.events)
+
+	s.mux.HandleFunc("POST /networks/create", s.networkCreate)
+	s.mux.HandleFunc("GET /networks", s.networkList)
+	s.mux.HandleFunc("GET /networks/{name}", s.networkInspect)
+	s.mux.HandleFunc("DELETE /networks/{name}", s.networkRemove)
+
+	s.mux.HandleFunc("GET /images/json", s.imageList)
+	s.mux.HandleFunc("GET /images/{name}/json", s.imageInspect)
+	s.mux.HandleFunc("POST /commit", s.imageCommit)
+
+	s.mux.HandleFunc("POST /system/reconcile", s.systemReconcile)
+	s.mux.HandleFunc("GET /system/df", s.systemDiskUsage)
+	s.mux.HandleFunc("POST /system/prune", s.systemPrune)
+
+	return s
+}
+
+// ServeHTTP 实现 http.Handler，便于通过 httptest 测试
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logrus.Debugf("%s %s", r.Method, r.URL)
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe 在 unix socket 上提供服务，收到 SIGINT、SIGTERM 后停止
+/*
+socket 文件已经存在时，如果能连上说明已经有 daemon 在运行，直接返回错误；
+连不上说明是上次异常退出遗留的，删除后重新监听。
+*/
+func (s *Server) ListenAndServe(socketPath string) error {
+	if _, err := os.Stat(socketPath); err == nil {
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return fmt.Errorf("another daemon is listening on %s", socketPath)
+		}
+		if err = os.Remove(socketPath); err != nil {
+			return errors.Join(err, fmt.Errorf("remove stale socket %s failed", socketPath))
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("listen on %s failed", socketPath))
+	}
+	defer os.Remove(socketPath)
+	if err = os.Chmod(socketPath, constant.Perm0660); err != nil {
+		listener.Close()
+		return errors.Join(err, fmt.Errorf("chmod %s failed", socketPath))
+	}
+
+	server := &http.Server{Handler: s}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-stop
+		logrus.Infof("received %s, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	logrus.Infof("daemon is listening on %s", socketPath)
+	if err = server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) ping(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+func (s *Server) containerCreate(w http.ResponseWriter, r *http.Request) {
+	req := new(client.ContainerConfig)
+	if !readJSON(w, r, req) {
+		return
+	}
+	id, err := s.backend.Create(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, &ContainerCreateResponse{Id: id})
+}
+
+func (s *Server) containerList(w http.ResponseWriter, r *http.Request) {
+	opts := &client.ListOptions{All: boolValue(r, "all")}
+	query := r.URL.Query()
+	if limit := query.Get("limit"); limit != "" {
+		last, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(w, invalidParameter(fmt.Errorf("invalid limit %s", limit)))
+			return
+		}
+		opts.Last = last
+	}
+	if !jsonQuery(w, r, "filters", &opts.Filters) {
+		return
+	}
+	containers, err := s.backend.List(opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, containers)
+}
+
+func (s *Server) containerInspect(w http.ResponseWriter, r *http.Request) {
+	detail, err := s.backend.Inspect(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, detail)
+}
+
+func (s *Server) containerStart(w http.ResponseWriter, r *http.Request) {
+	writeNoContent(w, s.backend.Start(r.PathValue("id")))
+}
+
+func (s *Server) containerStop(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := &client.StopOptions{Signal: query.Get("signal")}
+	if t := query.Get("t"); t != "" {
+		timeout, err := strconv.Atoi(t)
+		if err != nil {
+			writeError(w, invalidParameter(fmt.Errorf("invalid timeout %s", t)))
+			return
+		}
+		opts.Timeout = &timeout
+	}
+	writeNoContent(w, s.backend.Stop(r.PathValue("id"), opts))
+}
+
+func (s *Server) containerKill(w http.ResponseWriter, r *http.Request) {
+	writeNoContent(w, s.backend.Kill(r.PathValue("id"), r.URL.Query().Get("signal")))
+}
+
+func (s *Server) containerPause(w http.ResponseWriter, r *http.Request) {
+	writeNoContent(w, s.backend.Pause(r.PathValue("id")))
+}
+
+func (s *Server) containerUnpause(w http.ResponseWriter, r *http.Request) {
+	writeNoContent(w, s.backend.Unpause(r.PathValue("id")))
+}
+
+func (s *Server) containerUpdate(w http.ResponseWriter, r *http.Request) {
+	req := new(resource.ResourceConfig)
+	if !readJSON(w, r, req) {
+		return
+	}
+	writeNoContent(w, s.backend.Update(r.PathValue("id"), req))
+}
+
+func (s *Server) containerWait(w http.ResponseWriter, r *http.Request) {
+	code, err := s.backend.Wait(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &ContainerWaitResponse{StatusCode: code})
+}
+
+func (s *Server) containerStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.backend.Stats(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Server) containerLogs(w http.ResponseWriter, r *http.Request) {
+	logs, err := s.backend.Logs(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer logs.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err = io.Copy(w, logs); err != nil {
+		logrus.Warnf("write logs of container %s failed: %v", r.PathValue("id"), err)
+	}
+}
+
+func (s *Server) containerExec(w http.ResponseWriter, r *http.Request) {
+	req := new(ExecRequest)
+	if !readJSON(w, r, req) {
+		return
+	}
+	if len(req.Cmd) == 0 {
+		writeError(w, invalidParameter(errors.New("missing exec command")))
+		return
+	}
+	resp, err := s.backend.Exec(r.PathValue("id"), req.Cmd)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) containerRemove(w http.ResponseWriter, r *http.Request) {
+	writeNoContent(w, s.backend.Remove(r.PathValue("id"), boolValue(r, "force")))
+}
+
+func (s *Server) networkCreate(w http.ResponseWriter, r *http.Request) {
+	req := new(client.NetworkConfig)
+	if !readJSON(w, r, req) {
+		return
+	}
+	if err := s.backend.NetworkCreate(req); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) networkList(w http.ResponseWriter, r *http.Request) {
+	var filters client.Filters
+	if !jsonQuery(w, r, "filters", &filters) {
+		return
+	}
+	networks, err := s.backend.NetworkList(filters)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, networks)
+}
+
+func (s *Server) networkInspect(w http.ResponseWriter, r *http.Request) {
+	nw, err := s.backend.NetworkInspect(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, nw)
+}
+
+func (s *Server) networkRemove(w http.ResponseWriter, r *http.Request) {
+	writeNoContent(w, s.backend.NetworkRemove(r.PathValue("name")))
+}
+
+func (s *Server) imageList(w http.ResponseWriter, r *http.Request) {
+	images, err := s.backend.ImageList()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, images)
+}
+
+func (s *Server) imageInspect(w http.ResponseWriter, r *http.Request) {
+	image, err := s.backend.ImageInspect(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, image)
+}
+
+func (s *Server) imageCommit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("container") == "" {
+		writeError(w, invalidParameter(errors.New("missing container")))
+		return
+	}
+	var labels map[string]string
+	if !jsonQuery(w, r, "labels", &labels) {
+		return
+	}
+	if err := s.backend.Commit(query.Get("container"), query.Get("repo"), labels); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) systemReconcile(w http.ResponseWriter, r *http.Request) {
+	report, err := s.backend.Reconcile(boolValue(r, "gc"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) systemDiskUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := s.backend.DiskUsage()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+func (s *Server) systemPrune(w http.ResponseWriter, r *http.Request) {
+	opts := &client.PruneOptions{All: boolValue(r, "all"), Volumes: boolValue(r, "volumes")}
+	if !jsonQuery(w, r, "filters", &opts.Filters) {
+		return
+	}
+	report, err := s.backend.Prune(opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// events 以 JSON lines 的形式持续输出事件，直到没有更多事件或者客户端断开连接
+/*
+跟随新事件时可能很久都没有事件，因此先检查参数并立即返回响应头，
+之后每写入一个事件都 flush 一次，客户端不需要等到响应结束才能读到
+*/
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	opts := &client.EventsOptions{Since: r.URL.Query().Get("since"), Until: r.URL.Query().Get("until")}
+	if !jsonQuery(w, r, "filters", &opts.Filters) {
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+	encoder := json.NewEncoder(w)
+	err := s.backend.Events(r.Context(), opts, func(event *events.Event) error {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+		flush()
+		return nil
+	})
+	if err != nil && r.Context().Err() == nil {
+		logrus.Warnf("write events failed: %v", err)
+	}
+}
+
+// boolValue 解析 bool 类型的 query 参数，1、true 等均视为 true
+func boolValue(r *http.Request, key string) bool {
+	value, _ := strconv.ParseBool(r.URL.Query().Get(key))
+	return value
+}
+
+// jsonQuery 解析 json 编码的 query 参数，例如和 Docker Engine API 一样的 filters，参数不存在时不修改 v，失败时直接返回 400
+func jsonQuery(w http.ResponseWriter, r *http.Request, key string, v any) bool {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return true
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		writeError(w, invalidParameter(fmt.Errorf("invalid %s: %v", key, err)))
+		return false
+	}
+	return true
+}
+
+// readJSON 解析 json 请求体，失败时直接返回 400
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, invalidParameter(fmt.Errorf("invalid request body: %v", err)))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Warnf("write response failed: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := StatusCode(err)
+	if status == http.StatusInternalServerError {
+		logrus.Errorf("handle request failed: %v", err)
+	}
+	writeJSON(w, status, &ErrorResponse{Message: err.Error()})
+}
+
+// writeNoContent 没有响应体的请求，成功时返回 204
+func writeNoContent(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,518 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/client"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
+)
+
+// fakeBackend 在内存中保存容器，用于测试 server 和 Client
+type fakeBackend struct {
+	containers map[string]*container.Info
+	killed     string
+	stopped    *client.StopOptions
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{containers: map[string]*container.Info{}}
+}
+
+func (b *fakeBackend) lookup(id string) (*container.Info, error) {
+	info, ok := b.containers[id]
+	if !ok {
+		return nil, &client.Error{Kind: client.ErrNotFound, Err: fmt.Errorf("no such container: %s", id)}
+	}
+	return info, nil
+}
+
+func (b *fakeBackend) Create(config *client.ContainerConfig) (string, error) {
+	if config.Image == "" {
+		return "", &client.Error{Kind: client.ErrInvalidParameter, Err: errors.New("missing image name")}
+	}
+	id := fmt.Sprintf("c%d", len(b.containers))
+	b.containers[id] = &container.Info{Id: id, Name: config.Name, Status: container.CREATED}
+	return id, nil
+}
+
+func (b *fakeBackend) Start(id string) error {
+	info, err := b.lookup(id)
+	if err != nil {
+		return err
+	}
+	info.Status = container.RUNNING
+	return nil
+}
+
+func (b *fakeBackend) Stop(id string, opts *client.StopOptions) error {
+	info, err := b.lookup(id)
+	if err != nil {
+		return err
+	}
+	b.stopped = opts
+	info.Status = container.STOP
+	return nil
+}
+
+func (b *fakeBackend) Kill(id, signal string) error {
+	if _, err := b.lookup(id); err != nil {
+		return err
+	}
+	b.killed = signal
+	return nil
+}
+
+func (b *fakeBackend) Pause(id string) error {
+	info, err := b.lookup(id)
+	if err != nil {
+		return err
+	}
+	if info.Status != container.RUNNING {
+		return &client.Error{Kind: client.ErrConflict, Err: fmt.Errorf("container %s is not running", id)}
+	}
+	info.Status = container.PAUSED
+	return nil
+}
+
+func (b *fakeBackend) Unpause(id string) error {
+	info, err := b.lookup(id)
+	if err != nil {
+		return err
+	}
+	if info.Status != container.PAUSED {
+		return &client.Error{Kind: client.ErrConflict, Err: fmt.Errorf("container %s is not paused", id)}
+	}
+	info.Status = container.RUNNING
+	return nil
+}
+
+func (b *fakeBackend) Update(id string, res *resource.ResourceConfig) error {
+	info, err := b.lookup(id)
+	if err != nil {
+		return err
+	}
+	merged := info.Resources.Merge(res)
+	if err = merged.Validate(); err != nil {
+		return &client.Error{Kind: client.ErrInvalidParameter, Err: err}
+	}
+	info.Resources = merged
+	return nil
+}
+
+func (b *fakeBackend) Remove(id string, force bool) error {
+	info, err := b.lookup(id)
+	if err != nil {
+		return err
+	}
+	if info.Status == container.RUNNING && !force {
+		return &client.Error{Kind: client.ErrConflict, Err: fmt.Errorf("container %s is running", id)}
+	}
+	delete(b.containers, id)
+	return nil
+}
+
+func (b *fakeBackend) List(opts *client.ListOptions) ([]*container.Info, error) {
+	var list []*container.Info
+	for _, info := range b.containers {
+		if opts.All || info.Status == container.RUNNING {
+			if names, ok := opts.Filters["name"]; ok && info.Name != names[0] {
+				continue
+			}
+			list = append(list, info)
+		}
+	}
+	return list, nil
+}
+
+func (b *fakeBackend) Inspect(id string) (*client.ContainerDetail, error) {
+	info, err := b.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return &client.ContainerDetail{Info: info}, nil
+}
+
+func (b *fakeBackend) Logs(id string) (io.ReadCloser, error) {
+	if _, err := b.lookup(id); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader("hello from " + id)), nil
+}
+
+func (b *fakeBackend) Wait(id string) (int, error) {
+	if _, err := b.lookup(id); err != nil {
+		return 0, err
+	}
+	return 3, nil
+}
+
+func (b *fakeBackend) Stats(id string) (*client.ContainerStats, error) {
+	info, err := b.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Status != container.RUNNING {
+		return nil, &client.Error{Kind: client.ErrConflict, Err: fmt.Errorf("container %s is not running", id)}
+	}
+	stats := &client.ContainerStats{Id: id, Name: info.Name, MemoryLimit: 1 << 30}
+	stats.Memory.Usage = 1 << 20
+	stats.Pids.Current = 2
+	return stats, nil
+}
+
+func (b *fakeBackend) Exec(id string, cmd []string) (*client.ExecResult, error) {
+	if _, err := b.lookup(id); err != nil {
+		return nil, err
+	}
+	return &client.ExecResult{Output: strings.Join(cmd, " ")}, nil
+}
+
+// Events 输出 container 类型的事件，没有指定 until 时一直等到 ctx 结束
+func (b *fakeBackend) Events(ctx context.Context, opts *client.EventsOptions, fn func(event *events.Event) error) error {
+	for _, id := range []string{"c0", "c1"} {
+		if err := fn(&events.Event{Type: events.Container, Action: events.Start, Id: id}); err != nil {
+			return err
+		}
+	}
+	if opts.Until == "" {
+		<-ctx.Done()
+	}
+	return nil
+}
+
+func (b *fakeBackend) NetworkCreate(config *client.NetworkConfig) error { return nil }
+
+func (b *fakeBackend) NetworkList(filters client.Filters) ([]*client.Network, error) {
+	nw := &client.Network{Name: "testbr", Driver: "bridge", Subnet: "192.168.0.1/24", Labels: map[string]string{"team": "infra"}}
+	if labels, ok := filters["label"]; ok && labels[0] != "team=infra" {
+		return nil, nil
+	}
+	return []*client.Network{nw}, nil
+}
+
+func (b *fakeBackend) NetworkInspect(name string) (*client.Network, error) {
+	return nil, &client.Error{Kind: client.ErrNotFound, Err: fmt.Errorf("no such network: %s", name)}
+}
+
+func (b *fakeBackend) NetworkRemove(name string) error {
+	return &client.Error{Kind: client.ErrNotFound, Err: fmt.Errorf("no such network: %s", name)}
+}
+
+func (b *fakeBackend) ImageList() ([]*client.Image, error) {
+	return []*client.Image{{Name: "busybox", Size: 1024}}, nil
+}
+
+func (b *fakeBackend) ImageInspect(name string) (*client.Image, error) {
+	if name != "busybox" {
+		return nil, &client.Error{Kind: client.ErrNotFound, Err: fmt.Errorf("no such image: %s", name)}
+	}
+	return &client.Image{Name: name, Size: 1024}, nil
+}
+
+func (b *fakeBackend) Commit(containerId, imageName string, labels map[string]string) error {
+	_, err := b.lookup(containerId)
+	return err
+}
+
+func (b *fakeBackend) Reconcile(gc bool) (*client.ReconcileReport, error) {
+	report := &client.ReconcileReport{Containers: []string{"c0"}}
+	if gc {
+		report.Workspaces = []string{"orphan"}
+	}
+	return report, nil
+}
+
+func (b *fakeBackend) DiskUsage() (*client.DiskUsage, error) {
+	return &client.DiskUsage{Images: []*client.ImageUsage{{Name: "busybox", Size: 1024, Containers: len(b.containers)}}}, nil
+}
+
+func (b *fakeBackend) Prune(opts *client.PruneOptions) (*client.PruneReport, error) {
+	if _, ok := opts.Filters["until"]; ok && opts.Filters["until"][0] != "24h" {
+		return nil, &client.Error{Kind: client.ErrInvalidParameter, Err: errors.New("invalid filter until")}
+	}
+	report := &client.PruneReport{}
+	for id, info := range b.containers {
+		if info.Status == container.Exit {
+			delete(b.containers, id)
+			report.Containers = append(report.Containers, id)
+		}
+	}
+	if opts.All {
+		report.Images = []string{"busybox"}
+	}
+	return report, nil
+}
+
+// newUnixClient 在临时目录的 unix socket 上启动 server，返回连接它的 Client
+func newUnixClient(t *testing.T, backend Backend) *Client {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "mydocker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: NewServer(backend)}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return NewClient(socketPath)
+}
+
+func TestServerRoutes(t *testing.T) {
+	backend := newFakeBackend()
+	backend.containers["c0"] = &container.Info{Id: "c0", Status: container.RUNNING}
+	server := httptest.NewServer(NewServer(backend))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		method, path string
+		body         string
+		status       int
+	}{
+		{http.MethodGet, "/_ping", "", http.StatusOK},
+		{http.MethodGet, "/containers/json", "", http.StatusOK},
+		{http.MethodGet, "/containers/c0/json", "", http.StatusOK},
+		{http.MethodGet, "/containers/missing/json", "", http.StatusNotFound},
+		{http.MethodPost, "/containers/create", `{"image":""}`, http.StatusBadRequest},
+		{http.MethodPost, "/containers/create", `not json`, http.StatusBadRequest},
+		{http.MethodPost, "/containers/c0/exec", `{"cmd":[]}`, http.StatusBadRequest},
+		{http.MethodDelete, "/containers/c0", "", http.StatusConflict},
+		{http.MethodPost, "/containers/c0/kill?signal=KILL", "", http.StatusNoContent},
+		{http.MethodPost, "/containers/c0/stop?t=soon", "", http.StatusBadRequest},
+		{http.MethodPost, "/commit", "", http.StatusBadRequest},
+		{http.MethodGet, "/containers/c0/start", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/networks/nope", "", http.StatusNotFound},
+	} {
+		req, err := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.path, tc.status, resp.StatusCode)
+		}
+	}
+	if backend.killed != "KILL" {
+		t.Errorf("expected signal KILL, got %q", backend.killed)
+	}
+}
+
+func TestClientContainerLifecycle(t *testing.T) {
+	backend := newFakeBackend()
+	cli := newUnixClient(t, backend)
+
+	if err := cli.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	id, err := cli.Create(&client.ContainerConfig{Image: "busybox", Name: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.Start(id); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := cli.Inspect(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "web" || info.Status != container.RUNNING {
+		t.Fatalf("unexpected container %+v", info)
+	}
+	containers, err := cli.List(&client.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 1 || containers[0].Id != id {
+		t.Fatalf("unexpected containers %+v", containers)
+	}
+	containers, err = cli.List(&client.ListOptions{All: true, Filters: client.Filters{"name": {"db"}}})
+	if err != nil || len(containers) != 0 {
+		t.Fatalf("expected no containers named db, got %+v, %v", containers, err)
+	}
+
+	logs, err := cli.Logs(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(logs)
+	logs.Close()
+	if string(content) != "hello from "+id {
+		t.Fatalf("unexpected logs %q", content)
+	}
+	resp, err := cli.Exec(id, []string{"echo", "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Output != "echo hi" {
+		t.Fatalf("unexpected exec output %q", resp.Output)
+	}
+	code, err := cli.Wait(id)
+	if err != nil || code != 3 {
+		t.Fatalf("expected exit code 3, got %d, %v", code, err)
+	}
+
+	if err = cli.Pause(id); err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.Pause(id); !errors.Is(err, client.ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if err = cli.Unpause(id); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := cli.Stats(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Id != id || stats.Memory.Usage != 1<<20 || stats.MemoryLimit != 1<<30 || stats.Pids.Current != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if err = cli.Update(id, &resource.ResourceConfig{MemoryLimit: "512m", Cpus: 1, PidsLimit: 200}); err != nil {
+		t.Fatal(err)
+	}
+	if res := backend.containers[id].Resources; res.MemoryLimit != "512m" || res.Cpus != 1 || res.PidsLimit != 200 {
+		t.Fatalf("unexpected resources %+v", res)
+	}
+	if err = cli.Update(id, &resource.ResourceConfig{MemorySwap: "100m"}); !errors.Is(err, client.ErrInvalidParameter) {
+		t.Fatalf("expected invalid parameter, got %v", err)
+	}
+
+	timeout := 3
+	if err = cli.Stop(id, &client.StopOptions{Signal: "SIGINT", Timeout: &timeout}); err != nil {
+		t.Fatal(err)
+	}
+	if backend.stopped.Signal != "SIGINT" || backend.stopped.Timeout == nil || *backend.stopped.Timeout != 3 {
+		t.Fatalf("unexpected stop options %+v", backend.stopped)
+	}
+	backend.containers[id].Status = container.RUNNING
+
+	if err = cli.Remove(id, false); !errors.Is(err, client.ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if err = cli.Remove(id, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cli.Inspect(id); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	cli := newUnixClient(t, newFakeBackend())
+
+	_, err := cli.Create(&client.ContainerConfig{})
+	if StatusCode(err) != http.StatusBadRequest || err.Error() != "missing image name" {
+		t.Fatalf("expected bad request with the backend message, got %v", err)
+	}
+	if err = cli.NetworkRemove("nope"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	networks, err := cli.NetworkList(nil)
+	if err != nil || len(networks) != 1 || networks[0].Name != "testbr" {
+		t.Fatalf("unexpected networks %+v, %v", networks, err)
+	}
+	networks, err = cli.NetworkList(client.Filters{"label": {"team=web"}})
+	if err != nil || len(networks) != 0 {
+		t.Fatalf("expected no networks with label team=web, got %+v, %v", networks, err)
+	}
+	if _, err = cli.ImageInspect("nope"); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	image, err := cli.ImageInspect("busybox")
+	if err != nil || image.Name != "busybox" {
+		t.Fatalf("unexpected image %+v, %v", image, err)
+	}
+	report, err := cli.Reconcile(true)
+	if err != nil || len(report.Containers) != 1 || len(report.Workspaces) != 1 {
+		t.Fatalf("unexpected reconcile report %+v, %v", report, err)
+	}
+	if _, err = cli.Prune(&client.PruneOptions{Filters: client.Filters{"until": {"x"}}}); !errors.Is(err, client.ErrInvalidParameter) {
+		t.Fatalf("expected invalid parameter, got %v", err)
+	}
+
+	unreachable := NewClient(filepath.Join(t.TempDir(), "none.sock"))
+	if err = unreachable.Ping(); err == nil {
+		t.Fatal("expected ping to fail without a daemon")
+	}
+}
+
+func TestClientSystem(t *testing.T) {
+	backend := newFakeBackend()
+	backend.containers["c0"] = &container.Info{Id: "c0", Status: container.RUNNING}
+	backend.containers["c1"] = &container.Info{Id: "c1", Status: container.Exit}
+	cli := newUnixClient(t, backend)
+
+	usage, err := cli.DiskUsage()
+	if err != nil || len(usage.Images) != 1 || usage.Images[0].Containers != 2 {
+		t.Fatalf("unexpected disk usage %+v, %v", usage, err)
+	}
+	report, err := cli.Prune(&client.PruneOptions{All: true, Filters: client.Filters{"until": {"24h"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Containers) != 1 || report.Containers[0] != "c1" || len(report.Images) != 1 {
+		t.Fatalf("unexpected prune report %+v", report)
+	}
+	if _, ok := backend.containers["c0"]; !ok {
+		t.Fatal("running container should not be pruned")
+	}
+}
+
+func TestClientEvents(t *testing.T) {
+	cli := newUnixClient(t, newFakeBackend())
+
+	var ids []string
+	collect := func(event *events.Event) error {
+		ids = append(ids, event.Id)
+		return nil
+	}
+	if err := cli.Events(context.Background(), &client.EventsOptions{Until: "1s"}, collect); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "c0" || ids[1] != "c1" {
+		t.Fatalf("unexpected events %v", ids)
+	}
+
+	err := cli.Events(context.Background(), &client.EventsOptions{Filters: client.Filters{"type": {"volume"}}}, collect)
+	if !errors.Is(err, client.ErrInvalidParameter) {
+		t.Fatalf("expected invalid parameter, got %v", err)
+	}
+
+	// 跟随新事件时，收到的事件不需要等到响应结束，ctx 结束后正常返回
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- cli.Events(ctx, nil, func(event *events.Event) error {
+			if event.Id == "c1" {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for events")
+	}
+}
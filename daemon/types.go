@@ -0,0 +1,26 @@
+package daemon
+
+// DefaultSocket mydocker daemon 默认监听的 unix socket
+const DefaultSocket = "/run/mydocker.sock"
+
+// 容器、网络、镜像的请求体和响应直接使用 client 包中的类型，这里只定义 HTTP 接口特有的类型
+
+// ContainerCreateResponse POST /containers/create 的响应
+type ContainerCreateResponse struct {
+	Id string `json:"id"`
+}
+
+// ContainerWaitResponse POST /containers/{id}/wait 的响应
+type ContainerWaitResponse struct {
+	StatusCode int `json:"statusCode"`
+}
+
+// ExecRequest POST /containers/{id}/exec 的请求体
+type ExecRequest struct {
+	Cmd []string `json:"cmd"`
+}
+
+// ErrorResponse 请求失败时返回的响应体
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/client"
+	"github.com/NatsuiroGinga/mydocker/daemon"
+	"github.com/NatsuiroGinga/mydocker/events"
+)
+
+// runEvents 打印满足条件的事件，跟随新事件时直到 Ctrl-C 才退出
+func runEvents(backend daemon.Backend, opts *client.EventsOptions, format string) error {
+	var tmpl *template.Template
+	if format != "" && format != "json" {
+		var err error
+		if tmpl, err = newTemplate(format); err != nil {
+			return err
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return backend.Events(ctx, opts, func(event *events.Event) error {
+		return printEvent(event, format, tmpl)
+	})
+}
+
+// printEvent 打印一个事件
+/*
+format 为 json 时打印一行 json，tmpl 不为空时按照模板打印，否则和 docker events 一样打印为：
+
+2006-01-02T15:04:05.000000000+08:00 container die <容器 id> (exitCode=0, image=busybox, name=web)
+*/
+func printEvent(event *events.Event, format string, tmpl *template.Template) error {
+	switch {
+	case format == "json":
+		return json.NewEncoder(os.Stdout).Encode(event)
+	case tmpl != nil:
+		return printTemplate(tmpl, []*events.Event{event})
+	}
+
+	attributes := make([]string, 0, len(event.Attributes))
+	for key, value := range event.Attributes {
+		attributes = append(attributes, key+"="+value)
+	}
+	slices.Sort(attributes)
+	_, err := fmt.Printf("%s %s %s %s (%s)\n", event.Time.Format(time.RFC3339Nano), event.Type, event.Action, event.Id,
+		strings.Join(attributes, ", "))
+	return err
+}
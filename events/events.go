@@ -0,0 +1,169 @@
+// Package events 记录容器和网络的生命周期事件，mydocker events 从这里读取
+/*
+事件以 JSON lines 的形式追加到 /var/lib/mydocker/events/events.log 中，每个事件一行：
+
+1）写入时对文件加 flock 排他锁，并且一次 write 写入整行，同时写入的多个 mydocker 进程不会交错
+
+2）只追加、不修改，读取的一方不需要加锁，记录读到的位置，之后从这个位置继续读取新的事件
+
+3）记录事件失败只打印日志，不影响容器本身的操作
+*/
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/constant"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// 事件的对象类型
+const (
+	Container = "container"
+	Network   = "network"
+)
+
+// 事件的动作，和 docker events 中的名称一致
+const (
+	Create     = "create"     // 创建容器
+	Start      = "start"      // 启动容器，按照重启策略重启时也会记录
+	Die        = "die"        // 容器进程退出
+	OOM        = "oom"        // 容器中发生了 OOM
+	Stop       = "stop"       // mydocker stop 停止容器
+	Destroy    = "destroy"    // mydocker rm 删除容器
+	Connect    = "connect"    // 容器连接到网络
+	Disconnect = "disconnect" // 容器从网络断开
+)
+
+const logName = "events.log"
+
+// followInterval 读到文件末尾后检查新事件的间隔
+const followInterval = 200 * time.Millisecond
+
+// root 保存事件的目录，测试时替换为临时目录
+var root = "/var/lib/mydocker/events"
+
+// Event 一个生命周期事件
+type Event struct {
+	Time       time.Time         `json:"time"`
+	Type       string            `json:"type"`                 // container 或 network
+	Action     string            `json:"action"`               // create、start、die 等
+	Id         string            `json:"id"`                   // 容器 id 或网络名
+	Attributes map[string]string `json:"attributes,omitempty"` // 附加信息，例如容器名、退出码、网络事件中的容器 id
+}
+
+// Log 记录一个事件，失败时只打印日志
+func Log(typ, action, id string, attributes map[string]string) {
+	event := &Event{Time: time.Now(), Type: typ, Action: action, Id: id, Attributes: attributes}
+	if err := write(event); err != nil {
+		logrus.Warnf("record %s %s event of %s failed: %v", typ, action, id, err)
+	}
+}
+
+// write 在排他锁的保护下把事件作为一行追加到文件末尾
+func write(event *Event) error {
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+
+	if err = os.MkdirAll(root, constant.Perm0755); err != nil {
+		return errors.Join(err, fmt.Errorf("mkdir %s failed", root))
+	}
+	file, err := os.OpenFile(path.Join(root, logName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, constant.Perm0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = unix.Flock(int(file.Fd()), unix.LOCK_EX); err != nil {
+		return &fs.PathError{Op: "flock", Path: file.Name(), Err: err}
+	}
+	_, err = file.Write(content)
+	return err
+}
+
+// Watch 依次读取事件并调用 fn，fn 返回错误时停止并返回该错误
+/*
+tail 为 false 时从头读取；为 true 时跳过调用 Watch 之前已经记录的事件，只读取之后新写入的事件。
+
+follow 为 false 时读到文件末尾就返回；否则每隔 followInterval 检查一次新写入的事件，直到 ctx 结束。
+
+正在写入的最后一行可能还不完整，留到下次读取；无法解析的行打印日志后跳过
+*/
+func Watch(ctx context.Context, follow, tail bool, fn func(event *Event) error) error {
+	file, existed, err := openLog(ctx, follow)
+	if file == nil {
+		return err
+	}
+	defer file.Close()
+	// 每个事件都是一次 write 整行写入的，文件末尾一定是完整的行
+	if tail && existed {
+		if _, err = file.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+
+	reader := bufio.NewReader(file)
+	var partial []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		partial = append(partial, line...)
+		if errors.Is(err, io.EOF) {
+			if !follow || !sleep(ctx, followInterval) {
+				return nil
+			}
+			continue
+		}
+
+		event := new(Event)
+		if err = json.Unmarshal(partial, event); err != nil {
+			logrus.Warnf("skip invalid event %q: %v", partial, err)
+		} else if err = fn(event); err != nil {
+			return err
+		}
+		partial = partial[:0]
+	}
+}
+
+// openLog 打开事件文件，文件不存在说明还没有记录过事件，follow 时等待文件被创建
+//
+// 返回的文件为 nil 时不需要继续读取，existed 表示第一次打开时文件就已经存在
+func openLog(ctx context.Context, follow bool) (file *os.File, existed bool, err error) {
+	existed = true
+	for {
+		file, err = os.Open(path.Join(root, logName))
+		if !errors.Is(err, fs.ErrNotExist) {
+			return file, existed, err
+		}
+		existed = false
+		if !follow || !sleep(ctx, followInterval) {
+			return nil, false, nil
+		}
+	}
+}
+
+// sleep 等待 d，ctx 先结束时返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
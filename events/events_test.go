@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func setRoot(t *testing.T) {
+	old := root
+	root = t.TempDir()
+	t.Cleanup(func() { root = old })
+}
+
+func TestLogAndWatch(t *testing.T) {
+	setRoot(t)
+
+	// 还没有记录过事件
+	if err := Watch(context.Background(), false, false, func(*Event) error { return errors.New("unexpected event") }); err != nil {
+		t.Fatal(err)
+	}
+
+	Log(Container, Create, "abc", map[string]string{"name": "web"})
+	Log(Network, Connect, "bridge0", map[string]string{"container": "abc"})
+	var got []*Event
+	err := Watch(context.Background(), false, false, func(event *Event) error {
+		got = append(got, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Action != Create || got[0].Attributes["name"] != "web" ||
+		got[1].Type != Network || got[1].Id != "bridge0" || got[1].Time.IsZero() {
+		t.Fatalf("unexpected events %+v", got)
+	}
+
+	stop := errors.New("stop")
+	err = Watch(context.Background(), false, false, func(*Event) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected stop, got %v", err)
+	}
+}
+
+func TestWatchFollow(t *testing.T) {
+	setRoot(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan *Event, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, true, false, func(event *Event) error {
+			received <- event
+			return nil
+		})
+	}()
+
+	// 文件还不存在时等待创建
+	time.Sleep(2 * followInterval)
+	Log(Container, Start, "abc", nil)
+
+	// 模拟写入了一半的行，写完之前不能被读到
+	file, err := os.OpenFile(path.Join(root, logName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.WriteString(`{"type":"container","action":"die",`)
+	time.Sleep(2 * followInterval)
+	file.WriteString(`"id":"abc"}` + "\n")
+
+	for _, action := range []string{Start, Die} {
+		select {
+		case event := <-received:
+			if event.Action != action || event.Id != "abc" {
+				t.Fatalf("unexpected event %+v, want %s", event, action)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for %s", action)
+		}
+	}
+
+	cancel()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchTail(t *testing.T) {
+	setRoot(t)
+	Log(Container, Create, "old", nil)
+
+	// 不跟随时已有的事件都被跳过
+	if err := Watch(context.Background(), false, true, func(*Event) error { return errors.New("unexpected event") }); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan *Event, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, true, true, func(event *Event) error {
+			received <- event
+			return nil
+		})
+	}()
+
+	// 等待开始监听后再写入新的事件
+	time.Sleep(2 * followInterval)
+	Log(Container, Start, "new", nil)
+
+	select {
+	case event := <-received:
+		if event.Action != Start || event.Id != "new" {
+			t.Fatalf("unexpected event %+v, want only events after watch started", event)
+		}
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for new event")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 0 {
+		t.Fatalf("unexpected extra event %+v", <-received)
+	}
+}
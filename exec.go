@@ -1,80 +0,0 @@
-package main
-
-import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"os/exec"
-	"path"
-	"strings"
-
-	"github.com/NatsuiroGinga/mydocker/container"
-	log "github.com/sirupsen/logrus"
-)
-
-// nsenter里的C代码里已经出现mydocker_pid和mydocker_cmd这两个Key,主要是为了控制是否执行C代码里面的setns.
-const (
-	EnvExecPid = "mydocker_pid"
-	EnvExecCmd = "mydocker_cmd"
-)
-
-// ExecContainer 获取容器进程ID并设置环境变量，然后fork新进程并启动
-//
-// 1. 首先是通过ContainerId 找到进程 PID
-//
-// 2. 然后则是通过 exec 简单 fork 出了一个进程，并把这个进程的标准输入输出都绑定到宿主机的 stdin、stdout、stderr 上。
-func ExecContainer(containerId string, comArray []string) {
-	// 根据传进来的容器名获取对应的PID
-	pid, err := getPidByContainerId(containerId)
-	if err != nil {
-		log.Errorf("Exec container getContainerPidByName %s error %v", containerId, err)
-		return
-	}
-
-	cmd := exec.Command("/proc/self/exe", "exec")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	cmdStr := strings.Join(comArray, " ")
-	log.Infof("container pid：%s command：%s", pid, cmdStr)
-	os.Setenv(EnvExecPid, pid)
-	os.Setenv(EnvExecCmd, cmdStr)
-
-	// 把指定PID进程的环境变量传递给新启动的进程，实现通过exec命令也能查询到容器的环境变量
-	containerEnvs := getEnvsByPid(pid)
-	cmd.Env = append(os.Environ(), containerEnvs...)
-
-	if err = cmd.Run(); err != nil {
-		log.Errorf("Exec container %s error %v", containerId, err)
-	}
-}
-
-func getPidByContainerId(containerId string) (string, error) {
-	// 拼接出记录容器信息的文件路径
-	dirPath := fmt.Sprintf(container.InfoLocFormat, containerId)
-	configFilePath := path.Join(dirPath, container.ConfigName)
-	// 读取内容并解析
-	contentBytes, err := os.ReadFile(configFilePath)
-	if err != nil {
-		return "", err
-	}
-	var containerInfo container.Info
-	if err = json.Unmarshal(contentBytes, &containerInfo); err != nil {
-		return "", err
-	}
-	return containerInfo.Pid, nil
-}
-
-// getEnvsByPid 读取指定PID进程的环境变量
-func getEnvsByPid(pid string) []string {
-	path := fmt.Sprintf("/proc/%s/environ", pid)
-	contentBytes, err := os.ReadFile(path)
-	if err != nil {
-		log.Errorf("Read file %s error %v", path, err)
-		return nil
-	}
-	// env split by \u0000
-	envs := strings.Split(string(contentBytes), "\u0000")
-	return envs
-}
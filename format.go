@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs --format 模板中可以使用的函数，例如 {{json .Mounts}}、{{join .Env ","}}
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		content, err := json.Marshal(v)
+		return string(content), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// newTemplate 解析 --format 指定的 Go 模板
+func newTemplate(format string) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(templateFuncs).Parse(format)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("invalid format %q", format))
+	}
+	return tmpl, nil
+}
+
+// printTemplate 每个对象按照模板打印一行
+func printTemplate[T any](tmpl *template.Template, objects []T) error {
+	for _, object := range objects {
+		if err := tmpl.Execute(os.Stdout, object); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -7,13 +7,14 @@ require github.com/sirupsen/logrus v1.9.3
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.5 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
-	github.com/vishvananda/netns v0.0.4 // indirect
 )
 
 require (
+	github.com/opencontainers/runtime-spec v1.2.0
 	github.com/pkg/errors v0.9.1
 	github.com/spaolacci/murmur3 v1.1.0
 	github.com/urfave/cli v1.22.16
 	github.com/vishvananda/netlink v1.3.0
-	golang.org/x/sys v0.10.0 // indirect
+	github.com/vishvananda/netns v0.0.4
+	golang.org/x/sys v0.10.0
 )
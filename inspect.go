@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/NatsuiroGinga/mydocker/client"
+	"github.com/NatsuiroGinga/mydocker/daemon"
+)
+
+// inspect 支持的对象类型
+const (
+	inspectContainer = "container"
+	inspectNetwork   = "network"
+	inspectImage     = "image"
+)
+
+// inspectObject 根据名称查找容器、网络或镜像
+/*
+没有指定类型时依次按照容器、网络、镜像查找，名称不存在时继续查找下一种类型，
+其它错误直接返回，全部都不存在时返回 ErrNotFound。
+*/
+func inspectObject(backend daemon.Backend, kind, name string) (any, error) {
+	lookups := []struct {
+		kind    string
+		inspect func(string) (any, error)
+	}{
+		{inspectContainer, func(name string) (any, error) { return backend.Inspect(name) }},
+		{inspectNetwork, func(name string) (any, error) { return backend.NetworkInspect(name) }},
+		{inspectImage, func(name string) (any, error) { return backend.ImageInspect(name) }},
+	}
+	for _, lookup := range lookups {
+		if kind != "" && kind != lookup.kind {
+			continue
+		}
+		object, err := lookup.inspect(name)
+		if errors.Is(err, client.ErrNotFound) && kind == "" {
+			continue
+		}
+		return object, err
+	}
+	if kind != "" {
+		return nil, fmt.Errorf("unknown type %s, must be one of container, network and image", kind)
+	}
+	return nil, &client.Error{Kind: client.ErrNotFound, Err: fmt.Errorf("no such object: %s", name)}
+}
+
+// printInspect 打印 inspect 的结果，没有指定 format 时以 json 数组的形式打印，否则每个对象按照模板打印一行
+func printInspect(objects []any, format string) error {
+	if format == "" {
+		content, err := json.MarshalIndent(objects, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+
+	tmpl, err := newTemplate(format)
+	if err != nil {
+		return err
+	}
+	return printTemplate(tmpl, objects)
+}
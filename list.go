@@ -4,88 +4,97 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path"
 	"text/tabwriter"
 
 	"github.com/NatsuiroGinga/mydocker/container"
 	log "github.com/sirupsen/logrus"
 )
 
-// ListContainers 遍历容器信息并打印
-/*
-1. 首先遍历存放容器数据的/var/lib/mydocker/containers/目录，里面每一个子目录都是一个容器。
-
-2. 然后使用 getContainerInfo 方法解析子目录中的 config.json 文件拿到容器信息
+// shortCommandLen ps 默认输出中命令的最大长度，--no-trunc 时不截断
+const shortCommandLen = 20
 
-3. 最后格式化成 table 形式打印出来即可
+// printContainers 按照参数打印容器信息
+/*
+quiet 时只打印容器 id，便于作为其它命令的参数；
+format 为 json 时每个容器打印一行 json，为其它值时作为 Go 模板，每个容器打印一行；
+否则将容器信息格式化成 table 形式打印出来。
 */
-func ListContainers(all bool) {
-	// 读取存放在容器信息目录下的所有文件
-	files, err := os.ReadDir(container.InfoLoc)
-	if err != nil {
-		log.Errorf("read dir %s error %v", container.InfoLoc, err)
-		return
-	}
-
-	containers := make([]*container.Info, 0, len(files))
-
-	for _, file := range files {
-		tmpContainer, err := getContainerInfo(file)
-		if err != nil {
-			log.Errorf("get container info error %v", err)
-			continue
+func printContainers(containers []*container.Info, format string, quiet, noTrunc bool) error {
+	switch {
+	case quiet:
+		for _, item := range containers {
+			fmt.Println(shortId(item.Id, noTrunc))
 		}
-
-		if !all && tmpContainer.Status == container.STOP {
-			continue
+		return nil
+	case format == "json":
+		encoder := json.NewEncoder(os.Stdout)
+		for _, item := range containers {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
 		}
-
-		containers = append(containers, tmpContainer)
+		return nil
+	case format != "":
+		tmpl, err := newTemplate(format)
+		if err != nil {
+			return err
+		}
+		return printTemplate(tmpl, containers)
 	}
+
 	// 使用tabwriter.NewWriter在控制台打印出容器信息
 	// tabwriter 是引用的text/tabwriter类库，用于在控制台打印对齐的表格
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
-	_, err = fmt.Fprint(w, "ID\tNAME\tPID\tIP\tSTATUS\tCOMMAND\tCREATED\n")
+	_, err := fmt.Fprint(w, "ID\tNAME\tPID\tIP\tSTATUS\tRESTARTS\tCOMMAND\tCREATED\n")
 	if err != nil {
 		log.Errorf("Fprint error %v", err)
 	}
 
 	for _, item := range containers {
-		_, err = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
-			item.Id,
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			shortId(item.Id, noTrunc),
 			item.Name,
 			item.Pid,
 			item.IP,
-			item.Status,
-			item.Command,
+			statusText(item),
+			item.RestartCount,
+			truncate(item.Command, shortCommandLen, noTrunc),
 			item.CreatedTime)
 		if err != nil {
 			log.Errorf("Fprint error %v", err)
 		}
 	}
 
-	if err = w.Flush(); err != nil {
-		log.Errorf("Flush error %v", err)
-	}
+	return w.Flush()
 }
 
-func getContainerInfo(file os.DirEntry) (*container.Info, error) {
-	// 根据文件名拼接出完整路径
-	configFileDir := fmt.Sprintf(container.InfoLocFormat, file.Name())
-	configFilePath := path.Join(configFileDir, container.ConfigName)
-	// 读取容器配置文件
-	content, err := os.ReadFile(configFilePath)
-	if err != nil {
-		log.Errorf("read file %s error %v", configFileDir, err)
-		return nil, err
+// shortId 返回 id 的前 12 位，noTrunc 时返回完整的 id
+func shortId(id string, noTrunc bool) string {
+	if noTrunc {
+		return id
 	}
+	return container.ShortID(id)
+}
 
-	info := new(container.Info)
-
-	if err = json.Unmarshal(content, info); err != nil {
-		log.Errorf("json unmarshal error %v", err)
-		return nil, err
+// truncate 将超过 n 个字符的 s 截断，noTrunc 时原样返回
+func truncate(s string, n int, noTrunc bool) string {
+	if noTrunc {
+		return s
+	}
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
 	}
+	return s
+}
 
-	return info, nil
+// statusText 返回 ps 中显示的状态，退出的容器带上退出码，被 OOM killer 杀死的容器还会注明，例如 exited (137, oom killed)
+func statusText(info *container.Info) string {
+	switch {
+	case info.Status != container.Exit:
+		return info.Status
+	case info.OOMKilled:
+		return fmt.Sprintf("%s (%d, oom killed)", info.Status, info.ExitCode)
+	default:
+		return fmt.Sprintf("%s (%d)", info.Status, info.ExitCode)
+	}
 }
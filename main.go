@@ -23,10 +23,27 @@ func main() {
 		runCommand,
 		commitCommand,
 		listCommand,
+		inspectCommand,
 		logCommand,
 		execCommand,
 		stopCommand,
 		removeCommand,
+		createCommand,
+		startCommand,
+		stateCommand,
+		killCommand,
+		pauseCommand,
+		unpauseCommand,
+		updateCommand,
+		statsCommand,
+		eventsCommand,
+		deleteCommand,
+		waitCommand,
+		shimCommand,
+		networkCommand,
+		imagesCommand,
+		daemonCommand,
+		systemCommand,
 	}
 
 	app.Before = func(ctx *cli.Context) error {
@@ -1,84 +1,176 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"text/tabwriter"
 
+	"github.com/NatsuiroGinga/mydocker/cgroups"
 	"github.com/NatsuiroGinga/mydocker/cgroups/resource"
+	"github.com/NatsuiroGinga/mydocker/client"
 	"github.com/NatsuiroGinga/mydocker/container"
-	"github.com/NatsuiroGinga/mydocker/network"
+	"github.com/NatsuiroGinga/mydocker/daemon"
 	"github.com/urfave/cli"
 
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 )
 
+// resourceFlags run、create 和 update 共用的资源限制参数
+var resourceFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "m, memory", // 限制进程内存使用量
+		Usage: "memory limit, e.g.: -m 100m",
+	},
+	cli.IntFlag{
+		Name:  "cpu", // 限制cpu使用率
+		Usage: "cpu quota, e.g.: -cpu 100",
+	},
+	cli.StringFlag{
+		Name:  "cpuset",
+		Usage: "cpu limit, e.g.: -cpuset 2,4",
+	},
+	cli.StringFlag{
+		Name:  "memory-swap",
+		Usage: "total limit of memory plus swap, -1 for unlimited swap, e.g.: --memory-swap 200m",
+	},
+	cli.StringFlag{
+		Name:  "memory-reservation",
+		Usage: "memory soft limit, e.g.: --memory-reservation 50m",
+	},
+	cli.StringFlag{
+		Name:  "cpu-shares",
+		Usage: "relative cpu weight, cpu.weight on cgroup v2, e.g.: --cpu-shares 512",
+	},
+	cli.Float64Flag{
+		Name:  "cpus",
+		Usage: "number of cpus, conflicts with -cpu, e.g.: --cpus 1.5",
+	},
+	cli.StringFlag{
+		Name:  "cpuset-mems",
+		Usage: "memory nodes allowed, e.g.: --cpuset-mems 0-1",
+	},
+	cli.Int64Flag{
+		Name:  "pids-limit",
+		Usage: "max number of processes, -1 for unlimited, e.g.: --pids-limit 100",
+	},
+	cli.UintFlag{
+		Name:  "blkio-weight",
+		Usage: "relative block io weight between 10 and 1000, e.g.: --blkio-weight 300",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-read-bps",
+		Usage: "limit read rate from a device, e.g.: --device-read-bps /dev/sda:1mb",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-write-bps",
+		Usage: "limit write rate to a device, e.g.: --device-write-bps /dev/sda:1mb",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-read-iops",
+		Usage: "limit read operations per second from a device, e.g.: --device-read-iops /dev/sda:1000",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-write-iops",
+		Usage: "limit write operations per second to a device, e.g.: --device-write-iops /dev/sda:1000",
+	},
+}
+
+// containerFlags run 和 create 共用的容器配置参数
+var containerFlags = append([]cli.Flag{
+	cli.StringFlag{ // 数据卷
+		Name:  "v",
+		Usage: "volume, e.g.: -v /etc/conf:/etc/conf",
+	},
+	cli.StringFlag{
+		Name:  "name, ",
+		Usage: "container name, e.g.: --name my_container",
+	},
+	cli.StringSliceFlag{ // 增加 -e flag
+		Name:  "e",
+		Usage: "set environment,e.g. -e name=mydocker",
+	},
+	cli.StringFlag{
+		Name:  "net",
+		Usage: "container network，e.g. -net testbr",
+	},
+	cli.StringSliceFlag{
+		Name:  "p",
+		Usage: "port mapping,e.g. -p 8080:80 -p 30336:3306",
+	},
+	cli.StringFlag{
+		Name:  "restart",
+		Usage: "restart policy to apply when the container exits: no, on-failure[:max-retries], always, unless-stopped",
+		Value: container.RestartNo,
+	},
+	cli.StringFlag{
+		Name:  "cgroup-parent",
+		Usage: "parent cgroup of the container, the container is placed in <cgroup-parent>/<id> (default " + client.DefaultCgroupParent + "), a slice such as " + client.DefaultSystemdSlice + " with the systemd driver",
+	},
+	cli.StringFlag{
+		Name:  "cgroup-driver",
+		Usage: "cgroup driver of the container, " + cgroups.DriverCgroupfs + " or " + cgroups.DriverSystemd,
+		Value: cgroups.DriverCgroupfs,
+	},
+	cli.StringFlag{
+		Name:  "stop-signal",
+		Usage: "signal to stop the container, e.g. --stop-signal SIGINT (default SIGTERM)",
+	},
+	labelFlag,
+	labelFileFlag,
+}, resourceFlags...)
+
+// labelFlag、labelFileFlag 容器、网络和镜像共用的标签参数
+var (
+	labelFlag = cli.StringSliceFlag{
+		Name:  "label, l",
+		Usage: "set metadata, e.g. --label team=infra",
+	}
+	labelFileFlag = cli.StringSliceFlag{
+		Name:  "label-file",
+		Usage: "read in a line delimited file of labels",
+	}
+)
+
+// parseLabels 解析 --label 和 --label-file 参数
+func parseLabels(context *cli.Context) (map[string]string, error) {
+	return client.ParseLabels(context.StringSlice("label"), context.StringSlice("label-file"))
+}
+
 var runCommand = cli.Command{
 	Name: "run",
 	Usage: `Create a container with namespace and cgroups limit.
 			mydocker run -it [command]`,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.BoolFlag{
 			Name:  "it", // 简单起见，这里把 -i 和 -t 参数合并成一个
 			Usage: "enable tty",
 		},
-		cli.StringFlag{
-			Name:  "m", // 限制进程内存使用量
-			Usage: "memory limit, e.g.: -m 100m",
-		},
-		cli.IntFlag{
-			Name:  "cpu", // 限制cpu使用率
-			Usage: "cpu quota, e.g.: -cpu 100",
-		},
-		cli.StringFlag{
-			Name:  "cpuset",
-			Usage: "cpu limit, e.g.: -cpuset 2,4",
-		},
-		cli.StringFlag{ // 数据卷
-			Name:  "v",
-			Usage: "volume, e.g.: -v /etc/conf:/etc/conf",
-		},
-		cli.StringFlag{
-			Name:  "name, ",
-			Usage: "container name, e.g.: --name my_container",
-		},
 		cli.BoolFlag{
 			Name:  "d",
 			Usage: "detach container,run background",
 		},
-		cli.StringSliceFlag{ // 增加 -e flag
-			Name:  "e",
-			Usage: "set environment,e.g. -e name=mydocker",
-		},
-		cli.StringFlag{
-			Name:  "net",
-			Usage: "container network，e.g. -net testbr",
-		},
-		cli.StringSliceFlag{
-			Name:  "p",
-			Usage: "port mapping,e.g. -p 8080:80 -p 30336:3306",
+		cli.BoolFlag{
+			Name:  "rm",
+			Usage: "automatically remove the container when it exits",
 		},
-	},
+	}, containerFlags...),
 	/*
 		这里是run命令执行的真正函数。
 		1.判断参数是否包含command
 		2.获取用户指定的command
-		3.调用Run function去准备启动容器:
+		3.后台运行时通过 Backend 创建并启动容器，打印容器 id
+		4.前台运行时调用Run function去准备启动容器，并以容器进程的退出码退出
 	*/
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) == 0 {
 			return fmt.Errorf("missing container command")
 		}
 
-		var cmdArray []string
-		for _, arg := range context.Args() {
-			cmdArray = append(cmdArray, arg)
-		}
-
-		imageName := cmdArray[0] // 镜像名称
-		cmdArray = cmdArray[1:]
-
 		tty := context.Bool("it")
 		detach := context.Bool("d")
 
@@ -86,34 +178,136 @@ var runCommand = cli.Command{
 			return fmt.Errorf("it and d flag can not both provided")
 		}
 
+		autoRemove := context.Bool("rm")
+
 		logrus.Debugf("detach: %v", detach)
 
 		if !detach { // 如果不是指定后台运行，就默认前台运行
 			tty = true
 		}
 
-		resConf := &resource.ResourceConfig{
-			MemoryLimit: context.String("m"),
-			CpuSet:      context.String("cpuset"),
-			CpuCfsQuota: context.Int("cpu"),
+		config, err := parseContainerFlags(context)
+		if err != nil {
+			return err
+		}
+		config.AutoRemove = autoRemove
+		if detach {
+			backend := newBackend()
+			containerId, err := backend.Create(config)
+			if err != nil {
+				return err
+			}
+			if err = backend.Start(containerId); err != nil {
+				return err
+			}
+			fmt.Println(containerId)
+			return nil
 		}
+		exitCode, err := client.New(client.SelfExe).RunAttached(config)
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			return cli.NewExitError("", exitCode)
+		}
+		return nil
+	},
+}
 
-		logrus.Infof("ResourceConfig: %#v", resConf)
+// parseContainerFlags 解析 run 和 create 共用的参数，第一个参数为镜像名，其余为用户命令
+func parseContainerFlags(context *cli.Context) (*client.ContainerConfig, error) {
+	var cmdArray []string
+	for _, arg := range context.Args() {
+		cmdArray = append(cmdArray, arg)
+	}
 
-		containerName := context.String("name")
+	imageName := cmdArray[0] // 镜像名称
+	cmdArray = cmdArray[1:]
 
-		logrus.Infof("image name: %s", imageName)
+	resConf, err := parseResourceFlags(context)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("ResourceConfig: %#v", resConf)
 
-		logrus.Infof("containerName: %s", containerName)
+	containerName := context.String("name")
 
-		volume := context.String("v")
-		envs := context.StringSlice("e")
+	logrus.Debugf("image name: %s", imageName)
 
-		network := context.String("net")
-		portMapping := context.StringSlice("p")
+	logrus.Debugf("containerName: %s", containerName)
 
-		Run(tty, cmdArray, resConf, containerName, imageName, volume, envs, network, portMapping)
-		return nil
+	restartPolicy, err := container.ParseRestartPolicy(context.String("restart"))
+	if err != nil {
+		return nil, err
+	}
+	labels, err := parseLabels(context)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client.ContainerConfig{
+		Image:         imageName,
+		Cmd:           cmdArray,
+		Resources:     resConf,
+		Name:          containerName,
+		Volume:        context.String("v"),
+		Env:           context.StringSlice("e"),
+		Network:       context.String("net"),
+		PortMapping:   context.StringSlice("p"),
+		RestartPolicy: restartPolicy,
+		StopSignal:    context.String("stop-signal"),
+		CgroupParent:  context.String("cgroup-parent"),
+		CgroupDriver:  context.String("cgroup-driver"),
+		Labels:        labels,
+	}, nil
+}
+
+// parseResourceFlags 解析 resourceFlags 中的资源限制参数，没有指定的项为零值
+func parseResourceFlags(context *cli.Context) (*resource.ResourceConfig, error) {
+	blkioWeight := context.Uint("blkio-weight")
+	if blkioWeight > math.MaxUint16 {
+		return nil, fmt.Errorf("invalid blkio weight %d", blkioWeight)
+	}
+	resConf := &resource.ResourceConfig{
+		MemoryLimit:       context.String("m"),
+		MemorySwap:        context.String("memory-swap"),
+		MemoryReservation: context.String("memory-reservation"),
+		CpuSet:            context.String("cpuset"),
+		CpuSetMems:        context.String("cpuset-mems"),
+		CpuCfsQuota:       context.Int("cpu"),
+		Cpus:              context.Float64("cpus"),
+		CpuShare:          context.String("cpu-shares"),
+		PidsLimit:         context.Int64("pids-limit"),
+		BlkioWeight:       uint16(blkioWeight),
+	}
+	for _, throttle := range []struct {
+		flag    string
+		parse   func(string) (resource.ThrottleDevice, error)
+		devices *[]resource.ThrottleDevice
+	}{
+		{"device-read-bps", resource.ParseThrottleBps, &resConf.BlkioDeviceReadBps},
+		{"device-write-bps", resource.ParseThrottleBps, &resConf.BlkioDeviceWriteBps},
+		{"device-read-iops", resource.ParseThrottleIOps, &resConf.BlkioDeviceReadIOps},
+		{"device-write-iops", resource.ParseThrottleIOps, &resConf.BlkioDeviceWriteIOps},
+	} {
+		for _, spec := range context.StringSlice(throttle.flag) {
+			device, err := throttle.parse(spec)
+			if err != nil {
+				return nil, err
+			}
+			*throttle.devices = append(*throttle.devices, device)
+		}
+	}
+
+	return resConf, nil
+}
+
+var shimCommand = cli.Command{
+	Name:   "shim",
+	Usage:  "Supervise a detached container until it exits. Do not call it outside.",
+	Hidden: true,
+	Action: func(context *cli.Context) error {
+		return client.RunShim()
 	},
 }
 
@@ -131,9 +325,232 @@ var initCommand = cli.Command{
 	},
 }
 
+var createCommand = cli.Command{
+	Name: "create",
+	Usage: `Create a container, the container process waits until start.
+			mydocker create [options] image [command]
+			mydocker create --bundle /path/to/bundle [containerId]`,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "bundle, b",
+			Usage: "path to the OCI bundle directory which contains config.json",
+		},
+	}, containerFlags...),
+	/*
+		1.指定了 bundle 时按照 OCI bundle 创建容器，参数为容器 id
+		2.否则和 run 一样，参数为镜像名和用户命令，创建完成后打印容器 id
+	*/
+	Action: func(context *cli.Context) error {
+		if bundle := context.String("bundle"); bundle != "" {
+			if len(context.Args()) == 0 {
+				return errors.New("missing container id")
+			}
+			return client.New(client.SelfExe).CreateBundle(context.Args().Get(0), bundle)
+		}
+
+		if len(context.Args()) == 0 {
+			return errors.New("missing container command")
+		}
+		config, err := parseContainerFlags(context)
+		if err != nil {
+			return err
+		}
+		containerId, err := newBackend().Create(config)
+		if err != nil {
+			return err
+		}
+		fmt.Println(containerId)
+		return nil
+	},
+}
+
+var startCommand = cli.Command{
+	Name:  "start",
+	Usage: "start a created container, e.g.: mydocker start 1234567890",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) == 0 {
+			return errors.New("missing container id")
+		}
+		return newBackend().Start(context.Args().Get(0))
+	},
+}
+
+var stateCommand = cli.Command{
+	Name:  "state",
+	Usage: "output the OCI state of a container, e.g.: mydocker state 1234567890",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) == 0 {
+			return errors.New("missing container id")
+		}
+		state, err := client.New(client.SelfExe).State(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		content, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	},
+}
+
+var killCommand = cli.Command{
+	Name:  "kill",
+	Usage: "send a signal to the container init process, e.g.: mydocker kill -s SIGKILL 1234567890",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "signal, s",
+			Usage: "signal to send to the container (default SIGTERM)",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) == 0 {
+			return errors.New("missing container id")
+		}
+		// 兼容旧写法 mydocker kill 1234567890 SIGKILL
+		signal := context.String("signal")
+		if signal == "" {
+			signal = context.Args().Get(1)
+		}
+		return newBackend().Kill(context.Args().Get(0), signal)
+	},
+}
+
+var pauseCommand = cli.Command{
+	Name:  "pause",
+	Usage: "pause all processes within a container, e.g.: mydocker pause 1234567890",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) == 0 {
+			return errors.New("missing container id")
+		}
+		return newBackend().Pause(context.Args().Get(0))
+	},
+}
+
+var unpauseCommand = cli.Command{
+	Name:  "unpause",
+	Usage: "unpause all processes within a container, e.g.: mydocker unpause 1234567890",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) == 0 {
+			return errors.New("missing container id")
+		}
+		return newBackend().Unpause(context.Args().Get(0))
+	},
+}
+
+var updateCommand = cli.Command{
+	Name:  "update",
+	Usage: "update resource limits of a container, e.g.: mydocker update --memory 512m --cpus 2 --pids-limit 200 1234567890",
+	Flags: resourceFlags,
+	/*
+		没有指定的限制保持不变，运行中的容器立即生效，新的限制会记录下来，容器重启后仍然生效
+	*/
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) == 0 {
+			return errors.New("missing container id")
+		}
+		res, err := parseResourceFlags(context)
+		if err != nil {
+			return err
+		}
+		return newBackend().Update(context.Args().Get(0), res)
+	},
+}
+
+var statsCommand = cli.Command{
+	Name:  "stats",
+	Usage: "display a live stream of container resource usage, e.g.: mydocker stats --no-stream 1234567890",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "no-stream",
+			Usage: "disable streaming stats and only pull the first result",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "format output using a Go template, or json to print each container as a json line",
+		},
+	},
+	/*
+		没有指定容器时显示所有运行中的容器
+	*/
+	Action: func(context *cli.Context) error {
+		return runStats(newBackend(), context.Args(), context.Bool("no-stream"), context.String("format"))
+	},
+}
+
+var eventsCommand = cli.Command{
+	Name:  "events",
+	Usage: "get real time events of containers and networks, e.g.: mydocker events --since 10m --filter event=die",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "show events created since timestamp or relative time, only show new events if not set, e.g.: 10m, 2006-01-02 15:04:05",
+		},
+		cli.StringFlag{
+			Name:  "until",
+			Usage: "stream events until this timestamp or relative time, keep following new events if not set",
+		},
+		cli.StringSliceFlag{
+			Name:  "filter, f",
+			Usage: "filter output based on conditions: type, event, container, network, image, e.g.: --filter type=container --filter event=oom",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "format output using a Go template, or json to print each event as a json line",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		filters, err := client.ParseFilters(context.StringSlice("filter"))
+		if err != nil {
+			return err
+		}
+		opts := &client.EventsOptions{
+			Since:   context.String("since"),
+			Until:   context.String("until"),
+			Filters: filters,
+		}
+		return runEvents(newBackend(), opts, context.String("format"))
+	},
+}
+
+var deleteCommand = cli.Command{
+	Name:  "delete",
+	Usage: "delete a stopped container and run its poststop hooks, e.g.: mydocker delete 1234567890",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "force, f",
+			Usage: "kill the container if it is still running",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) == 0 {
+			return errors.New("missing container id")
+		}
+		return client.New(client.SelfExe).Delete(context.Args().Get(0), context.Bool("force"))
+	},
+}
+
+var waitCommand = cli.Command{
+	Name:  "wait",
+	Usage: "block until a container stops, then print its exit code, e.g.: mydocker wait 1234567890",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) == 0 {
+			return errors.New("missing container id")
+		}
+		exitCode, err := newBackend().Wait(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		fmt.Println(exitCode)
+		return nil
+	},
+}
+
 var commitCommand = cli.Command{
 	Name:  "commit",
 	Usage: "commit container to image",
+	Flags: []cli.Flag{labelFlag, labelFileFlag},
 	Action: cli.ActionFunc(func(ctx *cli.Context) error {
 		if len(ctx.Args()) == 0 {
 			return fmt.Errorf("missing image name")
@@ -142,26 +559,92 @@ var commitCommand = cli.Command{
 		containerName := ctx.Args().Get(0)
 		imageName := ctx.Args().Get(1)
 
-		return commitContainer(containerName, imageName)
+		labels, err := parseLabels(ctx)
+		if err != nil {
+			return err
+		}
+		return newBackend().Commit(containerName, imageName, labels)
 	}),
 }
 
 var listCommand = cli.Command{
 	Name:  "ps",
-	Usage: "list all the containers",
+	Usage: "list containers, e.g.: mydocker rm $(mydocker ps -aq --filter status=exited)",
+	// 支持 -aq 这样合并在一起的短参数
+	UseShortOptionHandling: true,
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name:  "a",
 			Usage: "show all containers, e.g.: mydocker ps -a",
 		},
+		cli.StringSliceFlag{
+			Name:  "filter, f",
+			Usage: "filter output based on conditions: status, name, network, ancestor, id, label, e.g.: --filter status=exited --filter label=team=infra",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "format output using a Go template, or json to print each container as a json line",
+		},
+		cli.IntFlag{
+			Name:  "last, n",
+			Usage: "show n last created containers (includes all states)",
+		},
+		cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "only display container IDs",
+		},
+		cli.BoolFlag{
+			Name:  "no-trunc",
+			Usage: "don't truncate output",
+		},
 	},
 	Action: cli.ActionFunc(func(ctx *cli.Context) error {
-		all := ctx.Bool("a")
-		ListContainers(all)
-		return nil
+		filters, err := client.ParseFilters(ctx.StringSlice("filter"))
+		if err != nil {
+			return err
+		}
+		containers, err := newBackend().List(&client.ListOptions{
+			All:     ctx.Bool("a"),
+			Filters: filters,
+			Last:    ctx.Int("last"),
+		})
+		if err != nil {
+			return err
+		}
+		return printContainers(containers, ctx.String("format"), ctx.Bool("quiet"), ctx.Bool("no-trunc"))
 	}),
 }
 
+var inspectCommand = cli.Command{
+	Name:  "inspect",
+	Usage: "display detailed information of containers, networks or images, e.g.: mydocker inspect -f '{{.IP}}' 1234567890",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "format the output using the given Go template",
+		},
+		cli.StringFlag{
+			Name:  "type",
+			Usage: "only inspect objects of the given type: container, network or image",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) == 0 {
+			return errors.New("missing container, network or image name")
+		}
+		backend := newBackend()
+		objects := make([]any, 0, len(context.Args()))
+		for _, name := range context.Args() {
+			object, err := inspectObject(backend, context.String("type"), name)
+			if err != nil {
+				return err
+			}
+			objects = append(objects, object)
+		}
+		return printInspect(objects, context.String("format"))
+	},
+}
+
 var logCommand = cli.Command{
 	Name:  "logs",
 	Usage: "print logs of a container",
@@ -169,9 +652,13 @@ var logCommand = cli.Command{
 		if len(ctx.Args()) == 0 {
 			return fmt.Errorf("please input your container name")
 		}
-		containerName := ctx.Args().Get(0)
-		logContainer(containerName)
-		return nil
+		logs, err := newBackend().Logs(ctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		defer logs.Close()
+		_, err = io.Copy(os.Stdout, logs)
+		return err
 	}),
 }
 
@@ -180,8 +667,8 @@ var execCommand = cli.Command{
 	Usage: "exec a command into container, e.g.: mydocker exec 123456789 /bin/sh",
 	Action: cli.ActionFunc(func(ctx *cli.Context) error {
 		// 如果环境变量存在，说明C代码已经运行过了，即setns系统调用已经执行了，这里就直接返回，避免重复执行
-		if os.Getenv(EnvExecPid) != "" {
-			log.Infof("pid callback pid %v", os.Getgid())
+		if os.Getenv(client.EnvExecPid) != "" {
+			log.Debugf("pid callback pid %v", os.Getpid())
 			return nil
 		}
 		// 格式：mydocker exec 容器名字 命令，因此至少会有两个参数
@@ -191,22 +678,44 @@ var execCommand = cli.Command{
 		containerName := ctx.Args().Get(0)
 		// 将除了容器名之外的参数作为命令部分
 		commandArray := ctx.Args().Tail()
-		ExecContainer(containerName, commandArray)
+		// exec 需要把命令的输入输出绑定到当前终端，因此总是在当前进程中执行
+		exitCode, err := client.New(client.SelfExe).ExecAttached(containerName, commandArray, os.Stdin, os.Stdout, os.Stderr)
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			return cli.NewExitError("", exitCode)
+		}
 		return nil
 	}),
 }
 
 var stopCommand = cli.Command{
 	Name:  "stop",
-	Usage: "stop a container,e.g. mydocker stop 1234567890",
+	Usage: "stop a container,e.g. mydocker stop -t 10 1234567890",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "time, t",
+			Usage: "seconds to wait for the container to exit before killing it",
+			Value: client.DefaultStopTimeout,
+		},
+		cli.StringFlag{
+			Name:  "signal, s",
+			Usage: "signal to stop the container (default: the container's stop signal or SIGTERM)",
+		},
+	},
 	Action: cli.ActionFunc(func(ctx *cli.Context) error {
 		// 输入应该是：mydocker stop [containerID]
 		if len(ctx.Args()) == 0 {
 			return errors.New("missing container id")
 		}
 		containerName := ctx.Args().Get(0)
-		stopContainer(containerName)
-		return nil
+		opts := &client.StopOptions{Signal: ctx.String("signal")}
+		if ctx.IsSet("time") {
+			timeout := ctx.Int("time")
+			opts.Timeout = &timeout
+		}
+		return newBackend().Stop(containerName, opts)
 	}),
 }
 
@@ -225,8 +734,7 @@ var removeCommand = cli.Command{
 		}
 		containerId := ctx.Args().Get(0)
 		force := ctx.Bool("f")
-		removeContainer(containerId, force)
-		return nil
+		return newBackend().Remove(containerId, force)
 	}),
 }
 
@@ -246,28 +754,50 @@ var networkCommand = cli.Command{
 					Name:  "subnet",
 					Usage: "subnet cidr",
 				},
+				labelFlag,
+				labelFileFlag,
 			},
 			Action: func(context *cli.Context) error {
 				if len(context.Args()) < 1 {
 					return fmt.Errorf("missing network name")
 				}
-				driver := context.String("driver")
-				subnet := context.String("subnet")
-				name := context.Args()[0]
-
-				err := network.CreateNetwork(driver, subnet, name)
+				labels, err := parseLabels(context)
 				if err != nil {
-					return fmt.Errorf("create network error: %+v", err)
+					return err
 				}
-				return nil
+				return newBackend().NetworkCreate(&client.NetworkConfig{
+					Name:   context.Args()[0],
+					Driver: context.String("driver"),
+					Subnet: context.String("subnet"),
+					Labels: labels,
+				})
 			},
 		},
 		{
 			Name:  "list",
 			Usage: "list container network",
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "filter, f",
+					Usage: "filter output based on conditions: name, driver, label, e.g.: --filter label=team=infra",
+				},
+			},
 			Action: func(context *cli.Context) error {
-				network.ListNetwork()
-				return nil
+				filters, err := client.ParseFilters(context.StringSlice("filter"))
+				if err != nil {
+					return err
+				}
+				networks, err := newBackend().NetworkList(filters)
+				if err != nil {
+					return err
+				}
+				// 通过tabwriter库把信息打印到屏幕上
+				w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+				fmt.Fprint(w, "NAME\tIpRange\tDriver\n")
+				for _, nw := range networks {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", nw.Name, nw.Subnet, nw.Driver)
+				}
+				return w.Flush()
 			},
 		},
 		{
@@ -277,10 +807,148 @@ var networkCommand = cli.Command{
 				if len(context.Args()) < 1 {
 					return fmt.Errorf("missing network name")
 				}
-				err := network.DeleteNetwork(context.Args()[0])
+				return newBackend().NetworkRemove(context.Args()[0])
+			},
+		},
+	},
+}
+
+var imagesCommand = cli.Command{
+	Name:  "images",
+	Usage: "list images, e.g. mydocker images",
+	Action: func(context *cli.Context) error {
+		images, err := newBackend().ImageList()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+		fmt.Fprint(w, "NAME\tSIZE\tCREATED\n")
+		for _, image := range images {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", image.Name, image.Size, image.Created)
+		}
+		return w.Flush()
+	},
+}
+
+var daemonCommand = cli.Command{
+	Name:  "daemon",
+	Usage: "serve the mydocker API on a unix socket, e.g. mydocker daemon --socket /run/mydocker.sock",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "socket",
+			Usage: "unix socket to listen on",
+			Value: daemon.DefaultSocket,
+		},
+		cli.BoolFlag{
+			Name:  "gc",
+			Usage: "remove leaked overlay dirs, cgroups and veths on startup",
+		},
+	},
+	/*
+		daemon 在当前进程中直接操作容器，其它命令检测到 daemon 在运行时会通过 socket 调用它。
+		后台运行的容器仍然由各自的 shim 管理，daemon 退出不影响已经运行的容器。
+
+		启动时先修正宿主机重启等原因导致的过期容器状态，再按照重启策略重新启动已经退出的容器。
+	*/
+	Action: func(context *cli.Context) error {
+		backend := client.New(client.SelfExe)
+		report, err := backend.Reconcile(context.Bool("gc"))
+		if err != nil {
+			log.Warnf("reconcile containers failed: %v", err)
+		} else {
+			log.Infof("reconciled %d containers", len(report.Containers))
+		}
+		if restored, err := backend.Restore(); err != nil {
+			log.Warnf("restore containers failed: %v", err)
+		} else {
+			log.Infof("restored %d containers", len(restored))
+		}
+		return daemon.NewServer(backend).ListenAndServe(context.String("socket"))
+	},
+}
+
+var systemCommand = cli.Command{
+	Name:  "system",
+	Usage: "manage mydocker",
+	Subcommands: []cli.Command{
+		{
+			Name:  "reconcile",
+			Usage: "mark containers whose process is gone as exited, e.g. mydocker system reconcile --gc",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "gc",
+					Usage: "also remove leaked overlay dirs, cgroups, veths and port mappings",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				report, err := newBackend().Reconcile(context.Bool("gc"))
+				if err != nil {
+					return err
+				}
+				printReconcileReport(report)
+				return nil
+			},
+		},
+		{
+			Name:  "df",
+			Usage: "show mydocker disk usage, e.g. mydocker system df -v",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "verbose, v",
+					Usage: "show detailed information on space usage",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "print in json",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				usage, err := newBackend().DiskUsage()
+				if err != nil {
+					return err
+				}
+				if context.String("format") == "json" {
+					return json.NewEncoder(os.Stdout).Encode(usage)
+				}
+				return printDiskUsage(usage, context.Bool("verbose"))
+			},
+		},
+		{
+			Name:                   "prune",
+			Usage:                  "remove stopped containers, unused networks, dangling images and optionally volumes",
+			UseShortOptionHandling: true,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "all, a",
+					Usage: "remove all unused images, not just dangling ones",
+				},
+				cli.BoolFlag{
+					Name:  "volumes",
+					Usage: "also remove unused volumes created by mydocker",
+				},
+				cli.StringSliceFlag{
+					Name:  "filter",
+					Usage: "provide filter values: until, label, e.g. --filter until=24h",
+				},
+				cli.BoolFlag{
+					Name:  "force, f",
+					Usage: "do not prompt for confirmation",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				filters, err := client.ParseFilters(context.StringSlice("filter"))
+				if err != nil {
+					return err
+				}
+				opts := &client.PruneOptions{All: context.Bool("all"), Volumes: context.Bool("volumes"), Filters: filters}
+				if !context.Bool("force") && !confirmPrune(opts) {
+					return nil
+				}
+				report, err := newBackend().Prune(opts)
 				if err != nil {
-					return fmt.Errorf("remove network error: %+v", err)
+					return err
 				}
+				printPruneReport(report)
 				return nil
 			},
 		},
@@ -1,6 +1,9 @@
 package network
 
-import "testing"
+import (
+	"net"
+	"testing"
+)
 
 var testName = "testbridge"
 
@@ -14,8 +17,9 @@ func Test_BridgeCreate(t *testing.T) {
 }
 
 func TestBridgeDelete(t *testing.T) {
+	_, ipRange, _ := net.ParseCIDR("192.168.0.1/24")
 	d := BridgeNetworkDriver{}
-	err := d.Delete(testName)
+	err := d.Delete(&Network{Name: testName, IPRange: ipRange})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -27,12 +31,8 @@ func TestBridgeConnect(t *testing.T) {
 		ID: "testcontainer",
 	}
 
-	n := Network{
-		Name: testName,
-	}
-
 	d := BridgeNetworkDriver{}
-	err := d.Connect(&n, &ep)
+	err := d.Connect(testName, &ep)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -43,12 +43,8 @@ func TestBridgeDisconnect(t *testing.T) {
 		ID: "testcontainer",
 	}
 
-	n := Network{
-		Name: testName,
-	}
-
 	d := BridgeNetworkDriver{}
-	err := d.Disconnect(n, &ep)
+	err := d.Disconnect(ep.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -16,9 +16,11 @@ Network 就是Linux中Bridge的抽象
 网络中会包括这个网络相关的配置，比如网络的容器地址段、网络操作所调用的网络驱动等信息。
 */
 type Network struct {
-	Name    string     // 网络名
-	IPRange *net.IPNet // 地址段
-	Driver  string     // 网络驱动名
+	Name    string            // 网络名
+	IPRange *net.IPNet        // 地址段
+	Driver  string            // 网络驱动名
+	Labels  map[string]string // 用户指定的标签
+	Created string            // 创建时间，格式为 2006-01-02 15:04:05，之前创建的网络没有记录
 }
 
 /*
@@ -3,17 +3,20 @@ package network
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
-	"text/tabwriter"
+	"time"
 
 	"github.com/NatsuiroGinga/mydocker/constant"
 	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/events"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
@@ -25,6 +28,13 @@ var (
 	drivers            = map[string]Driver{}
 )
 
+var (
+	// ErrNetworkNotFound 指定的网络不存在
+	ErrNetworkNotFound = errors.New("network not found")
+	// ErrNetworkAlreadyExists 同名的网络已经存在
+	ErrNetworkAlreadyExists = errors.New("network already exists")
+)
+
 func init() {
 	// 加载网络驱动
 	var bridgeDriver = BridgeNetworkDriver{}
@@ -91,15 +101,14 @@ func (net *Network) load(dumpPath string) error {
 		return err
 	}
 	defer netConfigFile.Close()
-	// 从配置文件中读取网络 配置 json 符串
-	netJson := make([]byte, 2000)
-	n, err := netConfigFile.Read(netJson)
+	// 从配置文件中读取网络 配置 json 符串，标签较多时可能超过一次 Read 的长度，需要完整读取
+	netJson, err := io.ReadAll(netConfigFile)
 	if err != nil {
 		return err
 	}
 
-	err = json.Unmarshal(netJson[:n], net)
-	return errors.Wrapf(err, "unmarshal %s failed", netJson[:n])
+	err = json.Unmarshal(netJson, net)
+	return errors.Wrapf(err, "unmarshal %s failed", netJson)
 }
 
 // LoadFromFile 读取 defaultNetworkPath 目录下的 Network 信息存放到内存中，便于使用
@@ -132,9 +141,23 @@ func loadNetwork() (map[string]*Network, error) {
 }
 
 // CreateNetwork 根据不同 driver 创建 Network
-func CreateNetwork(driver, subnet, name string) error {
+func CreateNetwork(driver, subnet, name string, labels map[string]string) error {
 	// 将网段的字符串转换成net. IPNet的对象
-	_, cidr, _ := net.ParseCIDR(subnet)
+	_, cidr, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return errors.Wrapf(err, "parse subnet %s failed", subnet)
+	}
+	networkDriver, ok := drivers[driver]
+	if !ok {
+		return fmt.Errorf("no Such Driver: %s", driver)
+	}
+	networks, err := loadNetwork()
+	if err != nil {
+		return errors.WithMessage(err, "load network from file failed")
+	}
+	if _, ok = networks[name]; ok {
+		return errors.Wrapf(ErrNetworkAlreadyExists, "network %s", name)
+	}
 	// 通过IPAM分配网关IP，获取到网段中第一个IP作为网关的IP
 	ip, err := ipAllocator.Allocate(cidr)
 	if err != nil {
@@ -143,35 +166,45 @@ func CreateNetwork(driver, subnet, name string) error {
 	cidr.IP = ip
 	// 调用指定的网络驱动创建网络，这里的 drivers 字典是各个网络驱动的实例字典 通过调用网络驱动
 	// Create 方法创建网络，后面会以 Bridge 驱动为例介绍它的实现
-	net, err := drivers[driver].Create(cidr.String(), name)
+	net, err := networkDriver.Create(cidr.String(), name)
 	if err != nil {
+		// 创建失败时释放已经分配的网关 IP，否则同一网段下次分配到的网关会变化
+		if releaseErr := ipAllocator.Release(cidr, &ip); releaseErr != nil {
+			logrus.Warnf("release gateway ip %s failed: %v", ip, releaseErr)
+		}
 		return err
 	}
+	net.Labels = labels
+	net.Created = time.Now().Format(time.DateTime)
 	// 保存网络信息，将网络的信息保存在文件系统中，以便查询和在网络上连接网络端点
 	return net.dump(defaultNetworkPath)
 }
 
-// ListNetwork 打印出当前全部 Network 信息
-func ListNetwork() {
+// ListNetwork 返回当前全部 Network 信息，按网络名排序
+func ListNetwork() ([]*Network, error) {
 	networks, err := loadNetwork()
 	if err != nil {
-		logrus.Errorf("load network from file failed,detail: %v", err)
-		return
+		return nil, errors.WithMessage(err, "load network from file failed")
 	}
-	// 通过tabwriter库把信息打印到屏幕上
-	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
-	fmt.Fprint(w, "NAME\tIpRange\tDriver\n")
+	list := make([]*Network, 0, len(networks))
 	for _, net := range networks {
-		fmt.Fprintf(w, "%s\t%s\t%s\n",
-			net.Name,
-			net.IPRange.String(),
-			net.Driver,
-		)
+		list = append(list, net)
 	}
-	if err = w.Flush(); err != nil {
-		logrus.Errorf("Flush error %v", err)
-		return
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// GetNetwork 根据名字返回 Network 信息
+func GetNetwork(networkName string) (*Network, error) {
+	networks, err := loadNetwork()
+	if err != nil {
+		return nil, errors.WithMessage(err, "load network from file failed")
+	}
+	net, ok := networks[networkName]
+	if !ok {
+		return nil, errors.Wrapf(ErrNetworkNotFound, "no Such Network: %s", networkName)
 	}
+	return net, nil
 }
 
 // DeleteNetwork 根据名字删除 Network
@@ -183,7 +216,7 @@ func DeleteNetwork(networkName string) error {
 	// 网络不存在直接返回一个error
 	net, ok := networks[networkName]
 	if !ok {
-		return fmt.Errorf("no Such Network: %s", networkName)
+		return errors.Wrapf(ErrNetworkNotFound, "no Such Network: %s", networkName)
 	}
 	// 调用IPAM的实例ipAllocator释放网络网关的IP
 	if err = ipAllocator.Release(net.IPRange, &net.IPRange.IP); err != nil {
@@ -230,7 +263,11 @@ func Connect(networkName string, info *container.Info) (net.IP, error) {
 		return ip, err
 	}
 	// 配置端口映射信息，例如 mydocker run -p 8080:80
-	return ip, addPortMapping(ep)
+	if err = addPortMapping(ep); err != nil {
+		return ip, err
+	}
+	events.Log(events.Network, events.Connect, networkName, map[string]string{"container": info.Id, "type": network.Driver})
+	return ip, nil
 }
 
 // Disconnect 将容器中指定网络中移除
@@ -246,6 +283,7 @@ func Disconnect(networkName string, info *container.Info) error {
 	}
 	// veth 从 bridge 解绑并删除 veth-pair 设备对
 	drivers[network.Driver].Disconnect(fmt.Sprintf("%s-%s", info.Id, networkName))
+	events.Log(events.Network, events.Disconnect, networkName, map[string]string{"container": info.Id, "type": network.Driver})
 
 	// 清理端口映射添加的 iptables 规则
 	ep := &Endpoint{
@@ -395,3 +433,46 @@ func configPortMapping(ep *Endpoint, isDelete bool) error {
 	}
 	return err
 }
+
+// RemoveStaleEndpoints 删除挂在 mydocker 网桥上、但不属于 active 中任何容器的 veth，返回删除的 veth 名
+/*
+容器的 veth 以容器 id 的前 5 位命名，容器进程退出后 veth 通常会随着 net namespace 一起销毁，
+shim 进程异常退出或者断开网络失败时才会遗留下来。
+*/
+func RemoveStaleEndpoints(active []*container.Info) ([]string, error) {
+	networks, err := loadNetwork()
+	if err != nil {
+		return nil, errors.WithMessage(err, "load network from file failed")
+	}
+	keep := make(map[string]bool, len(active))
+	for _, info := range active {
+		if len(info.Id) >= 5 {
+			keep[info.Id[:5]] = true
+		}
+	}
+	bridges := map[int]bool{}
+	for _, nw := range networks {
+		if br, err := netlink.LinkByName(nw.Name); err == nil {
+			bridges[br.Attrs().Index] = true
+		}
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, errors.Wrap(err, "list links failed")
+	}
+
+	var removed []string
+	for _, link := range links {
+		attrs := link.Attrs()
+		if link.Type() != "veth" || !bridges[attrs.MasterIndex] || keep[attrs.Name] {
+			continue
+		}
+		// 删除一端时另一端也会被删除
+		if err = netlink.LinkDel(link); err != nil {
+			logrus.Warnf("delete veth [%s] failed: %v", attrs.Name, err)
+			continue
+		}
+		removed = append(removed, attrs.Name)
+	}
+	return removed, nil
+}
@@ -0,0 +1,79 @@
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InterfaceStats 网卡收发的字节数和包数
+type InterfaceStats struct {
+	RxBytes   uint64 `json:"rxBytes"`
+	RxPackets uint64 `json:"rxPackets"`
+	RxErrors  uint64 `json:"rxErrors"`
+	RxDropped uint64 `json:"rxDropped"`
+	TxBytes   uint64 `json:"txBytes"`
+	TxPackets uint64 `json:"txPackets"`
+	TxErrors  uint64 `json:"txErrors"`
+	TxDropped uint64 `json:"txDropped"`
+}
+
+// ReadInterfaceStats 读取进程 pid 所在 network namespace 中除 lo 以外每块网卡的流量统计，key 为网卡名
+/*
+/proc/<pid>/net/dev 展示的是进程所在 network namespace 中的网卡，不需要进入容器的 namespace。
+容器中的网卡就是 veth 在容器一端的设备，它收到的流量就是宿主机一端发出的流量
+*/
+func ReadInterfaceStats(pid int) (map[string]InterfaceStats, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/net/dev", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseNetDev(file)
+}
+
+// parseNetDev 解析 /proc/net/dev，前两行为表头，之后每块网卡一行，形如
+//
+//	eth0:   15062     105    0    0    0     0          0         0    14724     113    0    0    0     0       0          0
+//
+// 冒号后依次为接收的 bytes packets errs drop fifo frame compressed multicast，发送的 bytes packets errs drop ...
+func parseNetDev(r io.Reader) (map[string]InterfaceStats, error) {
+	stats := make(map[string]InterfaceStats)
+	scanner := bufio.NewScanner(r)
+	for line := 0; scanner.Scan(); line++ {
+		if line < 2 {
+			continue
+		}
+		name, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		fields := strings.Fields(counters)
+		if name == "lo" || len(fields) < 12 {
+			continue
+		}
+		values := make([]uint64, 12)
+		for i := range values {
+			value, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid counters of interface %s: %s", name, counters)
+			}
+			values[i] = value
+		}
+		stats[name] = InterfaceStats{
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		}
+	}
+	return stats, scanner.Err()
+}
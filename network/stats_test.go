@@ -0,0 +1,22 @@
+package network
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNetDev(t *testing.T) {
+	content := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1296      16    0    0    0     0          0         0     1296      16    0    0    0     0       0          0
+cif-1a2b3:   15062     105    1    2    0     0          0         0    14724     113    3    4    0     0       0          0
+`
+	stats, err := parseNetDev(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := InterfaceStats{RxBytes: 15062, RxPackets: 105, RxErrors: 1, RxDropped: 2, TxBytes: 14724, TxPackets: 113, TxErrors: 3, TxDropped: 4}
+	if len(stats) != 1 || stats["cif-1a2b3"] != want {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
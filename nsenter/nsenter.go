@@ -9,24 +9,21 @@ package nsenter
 #include <stdlib.h>
 #include <string.h>
 #include <fcntl.h>
+#include <sys/wait.h>
 
 __attribute__((constructor)) void enter_namespace(void) {
    // 这里的代码会在Go运行时启动前执行，它会在单线程的C上下文中运行
 	char *mydocker_pid;
 	mydocker_pid = getenv("mydocker_pid");
-	if (mydocker_pid) {
-		fprintf(stdout, "got mydocker_pid=%s\n", mydocker_pid);
-	} else {
+	if (!mydocker_pid) {
 		// fprintf(stdout, "missing mydocker_pid env skip nsenter");
 		// 如果没有指定PID就不需要继续执行，直接退出
 		return;
 	}
 	char *mydocker_cmd;
 	mydocker_cmd = getenv("mydocker_cmd");
-	if (mydocker_cmd) {
-		fprintf(stdout, "got mydocker_cmd=%s\n", mydocker_cmd);
-	} else {
-		fprintf(stdout, "missing mydocker_cmd env skip nsenter\n");
+	if (!mydocker_cmd) {
+		fprintf(stderr, "missing mydocker_cmd env skip nsenter\n");
 		// 如果没有指定命令也是直接退出
 		return;
 	}
@@ -41,16 +38,18 @@ __attribute__((constructor)) void enter_namespace(void) {
 		sprintf(nspath, "/proc/%s/ns/%s", mydocker_pid, namespaces[i]);
 		int fd = open(nspath, O_RDONLY);
 		// 执行setns系统调用，进入对应namespace
+		// 成功时不输出任何内容，避免和命令本身的输出混在一起
 		if (setns(fd, 0) == -1) {
 			fprintf(stderr, "setns on %s namespace failed: %s\n", namespaces[i], strerror(errno));
-		} else {
-			fprintf(stdout, "setns on %s namespace succeeded\n", namespaces[i]);
 		}
 		close(fd);
 	}
-	// 在进入的Namespace中执行指定命令，然后退出
+	// 在进入的Namespace中执行指定命令，然后以命令的退出码退出
 	int res = system(mydocker_cmd);
-	exit(0);
+	if (res == -1 || !WIFEXITED(res)) {
+		exit(1);
+	}
+	exit(WEXITSTATUS(res));
 	return;
 }
 */
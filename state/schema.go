@@ -0,0 +1,71 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+)
+
+// SchemaVersion 当前 config.json 的版本，等于 migrations 的个数
+var SchemaVersion = len(migrations)
+
+// record config.json 中保存的内容，在容器信息的基础上增加了版本号
+type record struct {
+	SchemaVersion int `json:"schemaVersion"`
+	*container.Info
+}
+
+// migrations 第 i 个函数将版本 i 的记录升级到版本 i+1，记录以 map 的形式传入，可以直接增删字段
+/*
+新增字段的零值就是合适的默认值时不需要 migration，只有字段的含义变化或者需要根据其它字段填充时才需要增加。
+*/
+var migrations = []func(fields map[string]any) error{
+	migrateV0,
+}
+
+// migrateV0 版本 0 是引入版本号之前的记录
+/*
+1）那时所有容器共用 mydocker-cgroup 这个 cgroup，但没有记录下来
+
+2）没有指定容器名时容器名可能为空，统一改为容器 id
+*/
+func migrateV0(fields map[string]any) error {
+	if bundle, _ := fields["bundle"].(string); fields["cgroupPath"] == nil && bundle == "" {
+		fields["cgroupPath"] = "mydocker-cgroup"
+	}
+	if name, _ := fields["name"].(string); name == "" {
+		fields["name"] = fields["id"]
+	}
+	return nil
+}
+
+// decode 解析 config.json，版本低于 SchemaVersion 时依次执行 migrations
+func decode(content []byte) (*container.Info, error) {
+	rec := &record{Info: new(container.Info)}
+	if err := json.Unmarshal(content, rec); err != nil {
+		return nil, err
+	}
+	if rec.SchemaVersion == SchemaVersion {
+		return rec.Info, nil
+	}
+	if rec.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("schema version %d is newer than %d supported by this mydocker", rec.SchemaVersion, SchemaVersion)
+	}
+
+	fields := map[string]any{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+	for version := rec.SchemaVersion; version < SchemaVersion; version++ {
+		if err := migrations[version](fields); err != nil {
+			return nil, fmt.Errorf("migrate schema from version %d failed: %w", version, err)
+		}
+	}
+	content, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	info := new(container.Info)
+	return info, json.Unmarshal(content, info)
+}
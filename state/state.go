@@ -0,0 +1,206 @@
+// Package state 持久化容器信息，所有读写 config.json 的操作都通过这里完成
+/*
+每个容器的信息保存在 /var/lib/mydocker/containers/{containerID}/config.json 中：
+
+1）修改容器信息时对容器目录加 flock 排他锁，同时执行的 stop、rm、shim 等命令不会互相覆盖
+
+2）写入时先写临时文件再 rename，读取的一方要么读到旧的内容，要么读到新的内容，不会读到写了一半的文件
+
+3）config.json 中记录了 schemaVersion，读取旧版本的记录时依次执行 migrations 升级到当前版本
+
+flock 是加在打开的文件上的，同一个进程中重复加锁也会阻塞，因此 Update 的回调中不能再调用本包中修改同一个容器的函数。
+*/
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/constant"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// root 保存容器信息的目录，测试时替换为临时目录
+var root = container.InfoLoc
+
+// Create 记录新创建的容器，补充创建时间和状态，没有指定容器名时使用容器 id 作为容器名
+func Create(info *container.Info) error {
+	if len(info.Name) == 0 {
+		info.Name = info.Id
+	}
+	info.CreatedTime = time.Now().Format(time.DateTime)
+	info.Status = container.CREATED
+	return Save(info)
+}
+
+// Save 在排他锁的保护下写入容器信息，容器目录不存在时创建
+func Save(info *container.Info) error {
+	dir := containerDir(info.Id)
+	if err := os.MkdirAll(dir, constant.Perm0622); err != nil {
+		return errors.Join(err, fmt.Errorf("mkdir %s failed", dir))
+	}
+	unlock, err := lock(info.Id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return write(info)
+}
+
+// Load 读取容器信息，容器不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist)
+//
+// 写入是原子的，读取不需要加锁
+func Load(containerId string) (*container.Info, error) {
+	return read(containerId)
+}
+
+// Update 在排他锁的保护下读取容器信息，交给 fn 修改后写回，fn 返回错误时不写回
+//
+// 返回修改后的容器信息，容器不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist)
+func Update(containerId string, fn func(info *container.Info) error) (*container.Info, error) {
+	unlock, err := lock(containerId)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	info, err := read(containerId)
+	if err != nil {
+		return nil, err
+	}
+	if err = fn(info); err != nil {
+		return nil, err
+	}
+	if err = write(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Delete 在排他锁的保护下删除容器目录，并释放容器占用的容器名，容器不存在时直接返回
+func Delete(containerId string) error {
+	unlock, err := lock(containerId)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if info, err := read(containerId); err == nil {
+		container.ReleaseName(info.Name, containerId)
+	}
+	dir := containerDir(containerId)
+	if err = os.RemoveAll(dir); err != nil {
+		return errors.Join(err, fmt.Errorf("remove dir %s failed", dir))
+	}
+	return nil
+}
+
+// Exists 返回容器目录是否存在，容器正在创建时目录可能已经存在但还没有写入容器信息
+func Exists(containerId string) bool {
+	_, err := os.Stat(containerDir(containerId))
+	return err == nil
+}
+
+// List 返回全部容器的信息，读取失败的容器打印日志后跳过
+func List() ([]*container.Info, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) { // 还没有创建过容器
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("read dir %s failed", root))
+	}
+
+	containers := make([]*container.Info, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := read(entry.Name())
+		if errors.Is(err, fs.ErrNotExist) { // 正在创建或者已经被删除
+			continue
+		}
+		if err != nil {
+			logrus.Errorf("load container %s error %v", entry.Name(), err)
+			continue
+		}
+		containers = append(containers, info)
+	}
+	return containers, nil
+}
+
+func containerDir(containerId string) string {
+	return path.Join(root, containerId)
+}
+
+func configPath(containerId string) string {
+	return path.Join(containerDir(containerId), container.ConfigName)
+}
+
+// lock 对容器目录加 flock 排他锁，返回解锁函数
+func lock(containerId string) (func(), error) {
+	dir := containerDir(containerId)
+	fd, err := unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: dir, Err: err}
+	}
+	if err = unix.Flock(fd, unix.LOCK_EX); err != nil {
+		unix.Close(fd)
+		return nil, &fs.PathError{Op: "flock", Path: dir, Err: err}
+	}
+	return func() { unix.Close(fd) }, nil
+}
+
+// read 读取并按需升级容器信息
+func read(containerId string) (*container.Info, error) {
+	file := configPath(containerId)
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	info, err := decode(content)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("decode %s failed", file))
+	}
+	return info, nil
+}
+
+// write 先写入同目录下的临时文件，fsync 后 rename 为 config.json
+func write(info *container.Info) error {
+	content, err := json.Marshal(&record{SchemaVersion: SchemaVersion, Info: info})
+	if err != nil {
+		return errors.Join(err, errors.New("container info marshal failed"))
+	}
+
+	dir := containerDir(info.Id)
+	tmp, err := os.CreateTemp(dir, "."+container.ConfigName+"-*")
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("create temp file in %s failed", dir))
+	}
+	defer os.Remove(tmp.Name()) // rename 成功后临时文件已经不存在了，这里只是失败时的清理
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("write container info to file %s failed", tmp.Name()))
+	}
+	if err = os.Rename(tmp.Name(), configPath(info.Id)); err != nil {
+		return errors.Join(err, fmt.Errorf("rename %s failed", tmp.Name()))
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/NatsuiroGinga/mydocker/container"
+)
+
+func setRoot(t *testing.T) {
+	old := root
+	root = t.TempDir()
+	t.Cleanup(func() { root = old })
+}
+
+func TestSaveLoadDelete(t *testing.T) {
+	setRoot(t)
+
+	if _, err := Load("abc"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+	if err := Create(&container.Info{Id: "abc", Command: "sh"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := Load("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "abc" || info.Status != container.CREATED || info.CreatedTime == "" || info.Command != "sh" {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	if !Exists("abc") {
+		t.Fatal("expected container to exist")
+	}
+
+	// 写入时使用的临时文件不能残留在容器目录中
+	entries, err := os.ReadDir(path.Join(root, "abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != container.ConfigName {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+
+	if err = Delete("abc"); err != nil {
+		t.Fatal(err)
+	}
+	if Exists("abc") {
+		t.Fatal("expected container to be deleted")
+	}
+	if err = Delete("abc"); err != nil {
+		t.Fatalf("delete missing container: %v", err)
+	}
+	if _, err = Update("abc", func(*container.Info) error { return nil }); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	setRoot(t)
+	if err := Create(&container.Info{Id: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 并发的 Update 互斥执行，不会丢失修改
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Update("abc", func(info *container.Info) error {
+				info.RestartCount++
+				return nil
+			}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	boom := errors.New("boom")
+	if _, err := Update("abc", func(info *container.Info) error {
+		info.RestartCount = 0
+		return boom
+	}); !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+
+	info, err := Load("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.RestartCount != 20 {
+		t.Fatalf("expected restart count 20, got %d", info.RestartCount)
+	}
+}
+
+func TestList(t *testing.T) {
+	setRoot(t)
+	for _, id := range []string{"a", "b"} {
+		if err := Create(&container.Info{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 正在创建的容器只有目录，还没有容器信息
+	if err := os.Mkdir(path.Join(root, "c"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(root, "d"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	containers, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(containers))
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	setRoot(t)
+	legacy := map[string]string{
+		"image":  `{"id":"image","name":"","status":"running"}`,
+		"bundle": `{"id":"bundle","name":"bundle","status":"running","bundle":"/tmp/bundle"}`,
+	}
+	for id, content := range legacy {
+		if err := os.Mkdir(path.Join(root, id), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path.Join(root, id, container.ConfigName), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := Load("image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "image" || info.CgroupPath != "mydocker-cgroup" || info.Status != container.RUNNING {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	info, err = Load("bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CgroupPath != "" {
+		t.Fatalf("unexpected cgroup path %s of bundle container", info.CgroupPath)
+	}
+
+	// 升级后写回的记录带有当前的版本号
+	if _, err = Update("image", func(*container.Info) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path.Join(root, "image", container.ConfigName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &record{Info: new(container.Info)}
+	if _, err = decode(content); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(content, rec); err != nil || rec.SchemaVersion != SchemaVersion {
+		t.Fatalf("unexpected schema version %d, err %v", rec.SchemaVersion, err)
+	}
+}
+
+func TestNewerSchema(t *testing.T) {
+	if _, err := decode([]byte(`{"schemaVersion":1000,"id":"abc"}`)); err == nil {
+		t.Fatal("expected error for newer schema version")
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/NatsuiroGinga/mydocker/client"
+	"github.com/NatsuiroGinga/mydocker/container"
+	"github.com/NatsuiroGinga/mydocker/daemon"
+)
+
+// statsInterval mydocker stats 两次读取之间的间隔
+const statsInterval = time.Second
+
+// statsRow mydocker stats 输出的一行，字段名和 docker stats --format 中的一致
+type statsRow struct {
+	ID       string
+	Name     string
+	CPUPerc  string
+	MemUsage string
+	MemPerc  string
+	NetIO    string
+	BlockIO  string
+	PIDs     string
+}
+
+// newStatsRow 根据相邻两次读取的结果生成一行，prev 为 nil 时 CPU 使用率为 0
+func newStatsRow(prev, cur *client.ContainerStats) *statsRow {
+	usage, memPercent := client.MemoryPercent(cur)
+	var rx, tx uint64
+	for _, stats := range cur.Networks {
+		rx += stats.RxBytes
+		tx += stats.TxBytes
+	}
+	return &statsRow{
+		ID:       container.ShortID(cur.Id),
+		Name:     cur.Name,
+		CPUPerc:  fmt.Sprintf("%.2f%%", client.CPUPercent(prev, cur)),
+		MemUsage: fmt.Sprintf("%s / %s", binarySize(usage), binarySize(cur.MemoryLimit)),
+		MemPerc:  fmt.Sprintf("%.2f%%", memPercent),
+		NetIO:    fmt.Sprintf("%s / %s", humanSize(int64(rx)), humanSize(int64(tx))),
+		BlockIO:  fmt.Sprintf("%s / %s", humanSize(int64(cur.IO.ReadBytes)), humanSize(int64(cur.IO.WriteBytes))),
+		PIDs:     fmt.Sprint(cur.Pids.Current),
+	}
+}
+
+// binarySize 将字节数格式化为 1.5MiB 这样的形式，和 docker stats 一样内存使用 1024 进制
+func binarySize(size uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%.4g%s", value, units[i])
+}
+
+// runStats 每隔 statsInterval 读取一次容器的资源使用情况并打印
+/*
+1）没有指定容器时统计所有 running、paused 的容器，每次刷新时重新获取容器列表，
+容器退出或者被删除后不再显示；指定了容器时，容器不存在或者没有运行直接报错
+
+2）CPU 使用率需要两次读取的差值，因此先读取一次作为基准，statsInterval 之后才开始打印
+
+3）noStream 时只打印一次；否则 table 形式每次清屏后重新打印，json 和模板形式则持续追加输出
+*/
+func runStats(backend daemon.Backend, refs []string, noStream bool, format string) error {
+	var tmpl *template.Template
+	if format != "" && format != "json" {
+		var err error
+		if tmpl, err = newTemplate(format); err != nil {
+			return err
+		}
+	}
+
+	prev := map[string]*client.ContainerStats{}
+	for first := true; ; first = false {
+		ids := refs
+		if len(refs) == 0 {
+			var err error
+			if ids, err = activeContainerIds(backend); err != nil {
+				return err
+			}
+		}
+
+		cur := make(map[string]*client.ContainerStats, len(ids))
+		rows := make([]*statsRow, 0, len(ids))
+		for _, id := range ids {
+			stats, err := backend.Stats(id)
+			if err != nil {
+				// 统计期间退出或者被删除的容器直接跳过
+				gone := errors.Is(err, client.ErrNotFound) || errors.Is(err, client.ErrConflict)
+				if len(refs) > 0 && (first || !gone) {
+					return err
+				}
+				continue
+			}
+			cur[stats.Id] = stats
+			rows = append(rows, newStatsRow(prev[stats.Id], stats))
+		}
+		prev = cur
+
+		if !first {
+			if err := printStats(rows, format, tmpl, !noStream); err != nil {
+				return err
+			}
+			if noStream {
+				return nil
+			}
+		}
+		time.Sleep(statsInterval)
+	}
+}
+
+// activeContainerIds 返回所有 running、paused 状态的容器 id
+func activeContainerIds(backend daemon.Backend) ([]string, error) {
+	containers, err := backend.List(&client.ListOptions{
+		Filters: client.Filters{"status": {container.RUNNING, container.PAUSED}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(containers))
+	for _, item := range containers {
+		ids = append(ids, item.Id)
+	}
+	return ids, nil
+}
+
+// printStats 打印一次统计结果，format 为 json 时每个容器打印一行 json，tmpl 不为空时按照模板打印，
+// 否则以 table 形式打印，clear 为 true 时先清屏
+func printStats(rows []*statsRow, format string, tmpl *template.Template, clear bool) error {
+	switch {
+	case format == "json":
+		encoder := json.NewEncoder(os.Stdout)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	case tmpl != nil:
+		return printTemplate(tmpl, rows)
+	}
+
+	if clear {
+		fmt.Print("\033[2J\033[H")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "ID\tNAME\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tBLOCK I/O\tPIDS\n")
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.ID, row.Name, row.CPUPerc, row.MemUsage, row.MemPerc, row.NetIO, row.BlockIO, row.PIDs)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/NatsuiroGinga/mydocker/client"
+	"github.com/NatsuiroGinga/mydocker/container"
+)
+
+// printReconcileReport 打印 reconcile 修正的容器和清理的资源，每项一行
+func printReconcileReport(report *client.ReconcileReport) {
+	for _, id := range report.Containers {
+		fmt.Printf("container %s: marked as exited\n", container.ShortID(id))
+	}
+	for _, id := range report.Networks {
+		fmt.Printf("container %s: network disconnected\n", container.ShortID(id))
+	}
+	for _, name := range report.Endpoints {
+		fmt.Printf("veth %s: removed\n", name)
+	}
+	for _, id := range report.Workspaces {
+		fmt.Printf("workspace %s: removed\n", container.ShortID(id))
+	}
+	for _, path := range report.Cgroups {
+		fmt.Printf("cgroup %s: removed\n", path)
+	}
+}
+
+// humanSize 将字节数格式化为 1.5MB 这样的形式，和 docker 一样使用 1000 进制
+func humanSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1000 && i < len(units)-1 {
+		value /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%.3g%s", value, units[i])
+}
+
+// printDiskUsage 打印磁盘占用，verbose 时按镜像、容器、数据卷分别列出每一项，否则只打印汇总
+/*
+汇总中的 RECLAIMABLE 为 mydocker system prune --all --volumes 能够释放的空间：
+没有容器使用的镜像、已经停止的容器、没有容器使用的数据卷、已经停止的容器的日志。
+*/
+func printDiskUsage(usage *client.DiskUsage, verbose bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	if !verbose {
+		var images, containers, volumes, logs diskUsageSummary
+		for _, image := range usage.Images {
+			images.add(image.Size, image.Containers > 0)
+		}
+		for _, item := range usage.Containers {
+			active := item.Status != container.STOP && item.Status != container.Exit
+			containers.add(item.Size+item.LowerSize, active)
+			logs.add(item.LogSize, active)
+		}
+		for _, volume := range usage.Volumes {
+			volumes.add(volume.Size, volume.Containers > 0)
+		}
+		fmt.Fprint(w, "TYPE\tTOTAL\tACTIVE\tSIZE\tRECLAIMABLE\n")
+		images.print(w, "Images")
+		containers.print(w, "Containers")
+		volumes.print(w, "Local Volumes")
+		logs.print(w, "Logs")
+		return w.Flush()
+	}
+
+	fmt.Fprint(w, "Images space usage:\n\nNAME\tSIZE\tCONTAINERS\n")
+	for _, image := range usage.Images {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", image.Name, humanSize(image.Size), image.Containers)
+	}
+	fmt.Fprint(w, "\nContainers space usage:\n\nID\tNAME\tIMAGE\tSTATUS\tSIZE\tLOWER SIZE\tLOG SIZE\n")
+	for _, item := range usage.Containers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", container.ShortID(item.Id), item.Name, item.Image, item.Status,
+			humanSize(item.Size), humanSize(item.LowerSize), humanSize(item.LogSize))
+	}
+	fmt.Fprint(w, "\nLocal Volumes space usage:\n\nPATH\tSIZE\tCONTAINERS\n")
+	for _, volume := range usage.Volumes {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", volume.Path, humanSize(volume.Size), volume.Containers)
+	}
+	return w.Flush()
+}
+
+// diskUsageSummary system df 汇总中的一行
+type diskUsageSummary struct {
+	total, active     int
+	size, reclaimable int64
+}
+
+func (s *diskUsageSummary) add(size int64, active bool) {
+	s.total++
+	s.size += size
+	if active {
+		s.active++
+	} else {
+		s.reclaimable += size
+	}
+}
+
+func (s *diskUsageSummary) print(w io.Writer, kind string) {
+	percent := 0
+	if s.size > 0 {
+		percent = int(s.reclaimable * 100 / s.size)
+	}
+	fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s (%d%%)\n", kind, s.total, s.active, humanSize(s.size), humanSize(s.reclaimable), percent)
+}
+
+// printPruneReport 打印 prune 删除的对象和释放的空间
+func printPruneReport(report *client.PruneReport) {
+	sections := []struct {
+		title string
+		items []string
+	}{
+		{"Deleted Containers:", report.Containers},
+		{"Deleted Networks:", report.Networks},
+		{"Deleted Images:", report.Images},
+		{"Deleted Volumes:", report.Volumes},
+	}
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Println(section.title)
+		for _, item := range section.items {
+			fmt.Println(item)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Total reclaimed space: %s\n", humanSize(report.SpaceReclaimed))
+}
+
+// confirmPrune 列出将要删除的对象并等待用户确认，输入 y 时返回 true
+func confirmPrune(opts *client.PruneOptions) bool {
+	items := []string{"all stopped containers", "all networks not used by at least one container"}
+	if opts.All {
+		items = append(items, "all images without at least one container associated to them")
+	} else {
+		items = append(items, "all dangling images")
+	}
+	if opts.Volumes {
+		items = append(items, "all volumes created by mydocker not used by at least one container")
+	}
+	fmt.Println("WARNING! This will remove:")
+	for _, item := range items {
+		fmt.Println("  - " + item)
+	}
+	fmt.Print("\nAre you sure you want to continue? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.EqualFold(strings.TrimSpace(answer), "y")
+}
@@ -1,6 +1,11 @@
 package utils
 
-import "os"
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
 
 // PathExists 判断文件是否存在
 //
@@ -15,3 +20,26 @@ func PathExists(path string) (bool, error) {
 	}
 	return false, err
 }
+
+// DirSize 统计目录下所有普通文件的大小，不跟随符号链接，目录不存在时返回 0
+func DirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) { // 统计期间被删除的文件
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
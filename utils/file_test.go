@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirSize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "b"), make([]byte, 20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 符号链接不计入大小
+	if err := os.Symlink(filepath.Join(dir, "a"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := DirSize(dir)
+	if err != nil || size != 120 {
+		t.Fatalf("expected 120, got %d, %v", size, err)
+	}
+	if size, err = DirSize(filepath.Join(dir, "missing")); err != nil || size != 0 {
+		t.Fatalf("expected 0 for missing dir, got %d, %v", size, err)
+	}
+}
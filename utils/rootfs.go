@@ -17,6 +17,9 @@ func GetRoot(containerID string) string { return RootPath + containerID }
 
 func GetImage(imageName string) string { return fmt.Sprintf("%s%s.tar", ImagePath, imageName) }
 
+// GetImageConfig 镜像的元数据文件，保存 commit 时指定的标签等信息
+func GetImageConfig(imageName string) string { return fmt.Sprintf("%s%s.json", ImagePath, imageName) }
+
 func GetLower(containerID string) string {
 	return fmt.Sprintf(lowerDirFormat, containerID)
 }